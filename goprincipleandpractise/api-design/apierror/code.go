@@ -0,0 +1,165 @@
+// Package apierror 是 REST 和 gRPC 共用的错误模型。
+//
+// Code 是面向客户端的错误码，同时映射到 HTTP 状态码和 gRPC 状态码；
+// restful.ErrCode 是 Code 的别名，gRPC 服务用 New 构造带 errdetails 的状态错误。
+package apierror
+
+import (
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Code 是标准化错误码，横跨 HTTP 和 gRPC 场景。
+type Code string
+
+const (
+	InvalidJSON      Code = "invalid_json"
+	InvalidQuery     Code = "invalid_query"
+	InvalidArgument  Code = "invalid_argument"
+	ValidationFailed Code = "validation_failed"
+	InvalidPatch     Code = "invalid_patch"
+	UnsupportedMedia Code = "unsupported_media_type"
+	TooLarge         Code = "request_too_large"
+	NotAcceptable    Code = "not_acceptable"
+	Unauthorized     Code = "unauthorized"
+	Forbidden        Code = "forbidden"
+	NotFound         Code = "not_found"
+	Conflict         Code = "conflict"
+	IdempotencyReuse Code = "idempotency_key_reused"
+	Precondition     Code = "precondition_failed"
+	PreconditionReq  Code = "precondition_required"
+	BatchAborted     Code = "batch_aborted"
+	RateLimited      Code = "rate_limited"
+	Canceled         Code = "canceled"
+	DeadlineExceeded Code = "deadline_exceeded"
+	InternalError    Code = "internal_error"
+	NotImplemented   Code = "not_implemented"
+	Unavailable      Code = "unavailable"
+)
+
+// StatusClientClosedRequest 是客户端在响应前断开连接时记录的状态码（nginx 约定），对应 codes.Canceled。
+const StatusClientClosedRequest = 499
+
+// HTTPStatusCode 将 Code 映射到 HTTP 状态码。
+func (c Code) HTTPStatusCode() int {
+	switch c {
+	case InvalidJSON, InvalidQuery, InvalidArgument:
+		return http.StatusBadRequest
+	case ValidationFailed, InvalidPatch, IdempotencyReuse:
+		return http.StatusUnprocessableEntity
+	case UnsupportedMedia:
+		return http.StatusUnsupportedMediaType
+	case TooLarge:
+		return http.StatusRequestEntityTooLarge
+	case NotAcceptable:
+		return http.StatusNotAcceptable
+	case Unauthorized:
+		return http.StatusUnauthorized
+	case Forbidden:
+		return http.StatusForbidden
+	case NotFound:
+		return http.StatusNotFound
+	case Conflict:
+		return http.StatusConflict
+	case Precondition:
+		return http.StatusPreconditionFailed
+	case PreconditionReq:
+		return http.StatusPreconditionRequired
+	case BatchAborted:
+		return http.StatusFailedDependency
+	case RateLimited:
+		return http.StatusTooManyRequests
+	case Canceled:
+		return StatusClientClosedRequest
+	case DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case InternalError:
+		return http.StatusInternalServerError
+	case NotImplemented:
+		return http.StatusNotImplemented
+	case Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// GRPCCode 将 Code 映射到 gRPC 状态码。
+// HTTP 特有的错误（媒体类型、内容协商）归入 InvalidArgument，未知的 Code 映射为 Unknown。
+func (c Code) GRPCCode() codes.Code {
+	switch c {
+	case InvalidJSON, InvalidQuery, InvalidArgument, ValidationFailed, InvalidPatch,
+		UnsupportedMedia, NotAcceptable, IdempotencyReuse:
+		return codes.InvalidArgument
+	case TooLarge, RateLimited:
+		return codes.ResourceExhausted
+	case Unauthorized:
+		return codes.Unauthenticated
+	case Forbidden:
+		return codes.PermissionDenied
+	case NotFound:
+		return codes.NotFound
+	case Conflict:
+		return codes.AlreadyExists
+	case Precondition, PreconditionReq:
+		return codes.FailedPrecondition
+	case BatchAborted:
+		return codes.Aborted
+	case Canceled:
+		return codes.Canceled
+	case DeadlineExceeded:
+		return codes.DeadlineExceeded
+	case InternalError:
+		return codes.Internal
+	case NotImplemented:
+		return codes.Unimplemented
+	case Unavailable:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
+
+// FromGRPCCode 是 GRPCCode 的逆映射。
+// 多个 Code 对应同一个 gRPC 状态码时返回最通用的那个；需要精确的 Code 时用 FromStatus 读取 ErrorInfo。
+// codes.OK 不是错误，返回空 Code。
+func FromGRPCCode(c codes.Code) Code {
+	switch c {
+	case codes.OK:
+		return ""
+	case codes.Canceled:
+		return Canceled
+	case codes.InvalidArgument, codes.OutOfRange:
+		return InvalidArgument
+	case codes.DeadlineExceeded:
+		return DeadlineExceeded
+	case codes.NotFound:
+		return NotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return Conflict
+	case codes.PermissionDenied:
+		return Forbidden
+	case codes.ResourceExhausted:
+		return RateLimited
+	case codes.FailedPrecondition:
+		return Precondition
+	case codes.Unimplemented:
+		return NotImplemented
+	case codes.Unavailable:
+		return Unavailable
+	case codes.Unauthenticated:
+		return Unauthorized
+	default: // Unknown、Internal、DataLoss
+		return InternalError
+	}
+}
+
+// HTTPStatusFromGRPC 将 gRPC 状态码映射到 HTTP 状态码，覆盖全部 17 个状态码。
+// 经由 FromGRPCCode 得到 Code 再取其 HTTP 状态码，保证与 REST 接口返回的状态码一致。
+func HTTPStatusFromGRPC(c codes.Code) int {
+	if c == codes.OK {
+		return http.StatusOK
+	}
+	return FromGRPCCode(c).HTTPStatusCode()
+}
@@ -0,0 +1,71 @@
+package apierror
+
+import (
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestHTTPStatusFromGRPC(t *testing.T) {
+	want := map[codes.Code]int{
+		codes.OK:                 http.StatusOK,
+		codes.Canceled:           StatusClientClosedRequest,
+		codes.Unknown:            http.StatusInternalServerError,
+		codes.InvalidArgument:    http.StatusBadRequest,
+		codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+		codes.NotFound:           http.StatusNotFound,
+		codes.AlreadyExists:      http.StatusConflict,
+		codes.PermissionDenied:   http.StatusForbidden,
+		codes.ResourceExhausted:  http.StatusTooManyRequests,
+		codes.FailedPrecondition: http.StatusPreconditionFailed,
+		codes.Aborted:            http.StatusConflict,
+		codes.OutOfRange:         http.StatusBadRequest,
+		codes.Unimplemented:      http.StatusNotImplemented,
+		codes.Internal:           http.StatusInternalServerError,
+		codes.Unavailable:        http.StatusServiceUnavailable,
+		codes.DataLoss:           http.StatusInternalServerError,
+		codes.Unauthenticated:    http.StatusUnauthorized,
+	}
+	// 覆盖全部状态码: 新增状态码时这里会失败，提醒补充映射。
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		wantHTTP, ok := want[c]
+		if !ok {
+			t.Fatalf("no expectation for %v", c)
+		}
+		if got := HTTPStatusFromGRPC(c); got != wantHTTP {
+			t.Errorf("HTTPStatusFromGRPC(%v) = %d, want %d", c, got, wantHTTP)
+		}
+	}
+}
+
+func TestCodeRoundTrip(t *testing.T) {
+	all := []Code{
+		InvalidJSON, InvalidQuery, InvalidArgument, ValidationFailed, InvalidPatch, UnsupportedMedia,
+		TooLarge, NotAcceptable, Unauthorized, Forbidden, NotFound, Conflict, IdempotencyReuse,
+		Precondition, PreconditionReq, BatchAborted, RateLimited, Canceled, DeadlineExceeded,
+		InternalError, NotImplemented, Unavailable,
+	}
+	for _, code := range all {
+		if code.GRPCCode() == codes.Unknown {
+			t.Errorf("%s has no gRPC mapping", code)
+		}
+	}
+
+	// 这些状态码没有专属的 Code，逆映射后归入更通用的状态码。
+	merged := map[codes.Code]codes.Code{
+		codes.Unknown:    codes.Internal,
+		codes.OutOfRange: codes.InvalidArgument,
+		codes.Aborted:    codes.AlreadyExists,
+		codes.DataLoss:   codes.Internal,
+	}
+	for c := codes.Canceled; c <= codes.Unauthenticated; c++ {
+		want, ok := merged[c]
+		if !ok {
+			want = c
+		}
+		if got := FromGRPCCode(c).GRPCCode(); got != want {
+			t.Errorf("FromGRPCCode(%v).GRPCCode() = %v, want %v", c, got, want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package apierror
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Domain 是 ErrorInfo 的 domain，标识 reason 由本服务定义（AIP-193）。
+const Domain = "apidesign.example.com"
+
+// Reason 返回 Code 在 ErrorInfo 中的 reason，即大写形式，例如 NOT_FOUND。
+func (c Code) Reason() string { return strings.ToUpper(string(c)) }
+
+// ── 构造 ────────────────────────────────────────────
+
+// New 创建 gRPC 状态错误，等价于 Status(code, message, details...).Err()。
+func New(code Code, message string, details ...protoadapt.MessageV1) error {
+	return Status(code, message, details...).Err()
+}
+
+// Status 创建 gRPC 状态，状态码由 code.GRPCCode 决定。
+// details 中没有 ErrorInfo 时自动附加一个，客户端据此还原精确的 Code，而不只是 gRPC 状态码。
+func Status(code Code, message string, details ...protoadapt.MessageV1) *status.Status {
+	st := status.New(code.GRPCCode(), message)
+	if !slices.ContainsFunc(details, func(d protoadapt.MessageV1) bool { _, ok := d.(*errdetails.ErrorInfo); return ok }) {
+		details = append([]protoadapt.MessageV1{ErrorInfo(code, nil)}, details...)
+	}
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		return withDetails
+	}
+	return st
+}
+
+// ErrorInfo 返回 code 对应的 ErrorInfo，metadata 可以为 nil。
+func ErrorInfo(code Code, metadata map[string]string) *errdetails.ErrorInfo {
+	return &errdetails.ErrorInfo{Reason: code.Reason(), Domain: Domain, Metadata: metadata}
+}
+
+// BadRequest 把字段→描述的映射转换为 BadRequest，字段按名称排序保证输出稳定。
+func BadRequest(fields map[string]string) *errdetails.BadRequest {
+	br := &errdetails.BadRequest{}
+	for _, field := range slices.Sorted(maps.Keys(fields)) {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: fields[field],
+		})
+	}
+	return br
+}
+
+// RetryInfo 返回建议客户端等待 d 后重试的 RetryInfo，对应 HTTP 的 Retry-After。
+func RetryInfo(d time.Duration) *errdetails.RetryInfo {
+	return &errdetails.RetryInfo{RetryDelay: durationpb.New(d)}
+}
+
+// ── 解析 ────────────────────────────────────────────
+
+// Details 是从 gRPC 状态中解析出的错误信息。
+type Details struct {
+	Code       Code
+	Message    string
+	Fields     map[string]string // BadRequest 的字段违规，字段→描述
+	RetryAfter time.Duration     // RetryInfo，0 表示未指定
+	Metadata   map[string]string // ErrorInfo 的 metadata
+}
+
+// FromStatus 解析 gRPC 状态。
+// 带本服务 ErrorInfo 时 Code 取自 reason，否则由状态码经 FromGRPCCode 推断；无法解码的 detail 被忽略。
+func FromStatus(st *status.Status) Details {
+	d := Details{Code: FromGRPCCode(st.Code()), Message: st.Message()}
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			if detail.Domain == Domain && detail.Reason != "" {
+				d.Code = Code(strings.ToLower(detail.Reason))
+				d.Metadata = detail.Metadata
+			}
+		case *errdetails.BadRequest:
+			for _, v := range detail.FieldViolations {
+				if d.Fields == nil {
+					d.Fields = make(map[string]string)
+				}
+				d.Fields[v.Field] = v.Description
+			}
+		case *errdetails.RetryInfo:
+			d.RetryAfter = detail.RetryDelay.AsDuration()
+		}
+	}
+	return d
+}
@@ -0,0 +1,64 @@
+package apierror
+
+import (
+	"maps"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatusDetailsRoundTrip(t *testing.T) {
+	err := New(ValidationFailed, "invalid user",
+		BadRequest(map[string]string{"name": "is required", "email": "must be a valid email address"}),
+		RetryInfo(3*time.Second))
+
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.InvalidArgument {
+		t.Fatalf("status = %v, want InvalidArgument", st)
+	}
+	// ErrorInfo 在最前，BadRequest 的字段按名称排序。
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	if !ok || info.Reason != "VALIDATION_FAILED" || info.Domain != Domain {
+		t.Errorf("first detail = %v, want ErrorInfo", st.Details()[0])
+	}
+	if br := st.Details()[1].(*errdetails.BadRequest); br.FieldViolations[0].Field != "email" {
+		t.Errorf("field violations = %v, want sorted", br.FieldViolations)
+	}
+
+	d := FromStatus(st)
+	want := map[string]string{"name": "is required", "email": "must be a valid email address"}
+	if d.Code != ValidationFailed || d.Message != "invalid user" || d.RetryAfter != 3*time.Second || !maps.Equal(d.Fields, want) {
+		t.Errorf("FromStatus = %+v", d)
+	}
+}
+
+func TestFromStatus(t *testing.T) {
+	foreign, _ := status.New(codes.AlreadyExists, "exists").WithDetails(&errdetails.ErrorInfo{Reason: "DUPLICATE", Domain: "other.example.com"})
+	custom := Status(Forbidden, "denied", ErrorInfo(Forbidden, map[string]string{"scope": "users:write"}))
+
+	tests := []struct {
+		name     string
+		st       *status.Status
+		wantCode Code
+		wantMeta map[string]string
+	}{
+		{"no details", status.New(codes.NotFound, "missing"), NotFound, nil},
+		{"foreign domain", foreign, Conflict, nil},
+		{"precise code", Status(IdempotencyReuse, "reused"), IdempotencyReuse, nil},
+		{"caller ErrorInfo", custom, Forbidden, map[string]string{"scope": "users:write"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := FromStatus(tt.st)
+			if d.Code != tt.wantCode || !maps.Equal(d.Metadata, tt.wantMeta) {
+				t.Errorf("FromStatus = %+v, want code %s metadata %v", d, tt.wantCode, tt.wantMeta)
+			}
+		})
+	}
+	if n := len(custom.Details()); n != 1 {
+		t.Errorf("caller ErrorInfo: %d details, want 1", n)
+	}
+}
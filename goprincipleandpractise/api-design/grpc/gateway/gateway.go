@@ -0,0 +1,219 @@
+// Package gateway 把 gRPC UserService 转码为 HTTP/JSON 接口（类似 grpc-gateway，但手写且在进程内运行）。
+//
+//	GET    /v1/users        → ListUsers    查询参数 page_size、page_token、filter、order_by
+//	GET    /v1/users/{id}   → GetUser
+//	POST   /v1/users        → CreateUser   请求体为 CreateUserRequest 的 JSON
+//	DELETE /v1/users/{id}   → DeleteUser
+//
+// 成功响应是 proto 消息的 JSON（字段名使用 proto 中的 snake_case），错误响应是 restful.ErrorResponse 信封。
+// 业务逻辑只在 UserService 中实现一次，REST 和 gRPC 客户端看到相同的校验和错误码。
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	pb "go-notes/goprincipleandpractise/api-design/grpc/pb"
+	"go-notes/goprincipleandpractise/api-design/restful"
+)
+
+// MetadataHeaderPrefix 是与 gRPC metadata 互相转换的 HTTP 头部前缀（与 grpc-gateway 相同）:
+// 请求头 Grpc-Metadata-Tenant: a 转发为 metadata tenant: a，响应中的 metadata 同样加上前缀写回。
+const MetadataHeaderPrefix = "Grpc-Metadata-"
+
+// maxBodyBytes 限制请求体大小，与 gRPC 默认的 4MB 接收上限相比留有余量。
+const maxBodyBytes = 1 << 20
+
+var (
+	marshaler   = protojson.MarshalOptions{UseProtoNames: true}
+	unmarshaler = protojson.UnmarshalOptions{}
+)
+
+// Gateway 是转码 HTTP handler。
+type Gateway struct {
+	client pb.UserServiceClient
+	mux    *http.ServeMux
+}
+
+// New 创建 Gateway。client 通常由 DialInProcess 得到，请求经过 gRPC 服务端的全部拦截器（认证、日志、恢复）。
+func New(client pb.UserServiceClient) *Gateway {
+	g := &Gateway{client: client, mux: http.NewServeMux()}
+	g.mux.HandleFunc("GET /v1/users", g.listUsers)
+	g.mux.HandleFunc("POST /v1/users", g.createUser)
+	g.mux.HandleFunc("GET /v1/users/{id}", g.getUser)
+	g.mux.HandleFunc("DELETE /v1/users/{id}", g.deleteUser)
+	return g
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+// DialInProcess 在内存 listener 上启动 srv，返回连接到它的 ClientConn。
+// 不占用端口，但请求仍经过 HTTP/2 传输和 protobuf 编解码，与远程调用行为一致。
+// 调用方负责关闭 ClientConn 并停止 srv。
+func DialInProcess(srv *grpc.Server) (*grpc.ClientConn, error) {
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	return grpc.NewClient("passthrough:///inprocess",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+}
+
+// ── handler ─────────────────────────────────────────
+
+func (g *Gateway) listUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := &pb.ListUsersRequest{
+		PageToken: q.Get("page_token"),
+		Filter:    q.Get("filter"),
+		OrderBy:   q.Get("order_by"),
+	}
+	if s := q.Get("page_size"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			restful.WriteError(w, r, restful.NewAppError(restful.ErrInvalidQuery, "page_size must be an integer", err))
+			return
+		}
+		req.PageSize = int32(n)
+	}
+	invoke(w, r, http.StatusOK, func(ctx context.Context, opts ...grpc.CallOption) (proto.Message, error) {
+		return g.client.ListUsers(ctx, req, opts...)
+	})
+}
+
+func (g *Gateway) getUser(w http.ResponseWriter, r *http.Request) {
+	req := &pb.GetUserRequest{Id: r.PathValue("id")}
+	invoke(w, r, http.StatusOK, func(ctx context.Context, opts ...grpc.CallOption) (proto.Message, error) {
+		return g.client.GetUser(ctx, req, opts...)
+	})
+}
+
+func (g *Gateway) createUser(w http.ResponseWriter, r *http.Request) {
+	req := &pb.CreateUserRequest{}
+	if !decodeBody(w, r, req) {
+		return
+	}
+	invoke(w, r, http.StatusCreated, func(ctx context.Context, opts ...grpc.CallOption) (proto.Message, error) {
+		user, err := g.client.CreateUser(ctx, req, opts...)
+		if err == nil {
+			w.Header().Set("Location", "/v1/users/"+user.Id)
+		}
+		return user, err
+	})
+}
+
+func (g *Gateway) deleteUser(w http.ResponseWriter, r *http.Request) {
+	req := &pb.DeleteUserRequest{Id: r.PathValue("id")}
+	invoke(w, r, http.StatusNoContent, func(ctx context.Context, opts ...grpc.CallOption) (proto.Message, error) {
+		return g.client.DeleteUser(ctx, req, opts...)
+	})
+}
+
+// decodeBody 把 JSON 请求体解码到 msg，失败时写入错误响应并返回 false。
+// 未知字段视为错误，与 restful 的严格解码一致。
+func decodeBody(w http.ResponseWriter, r *http.Request, msg proto.Message) bool {
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
+		restful.WriteError(w, r, restful.NewAppError(restful.ErrUnsupportedMedia,
+			fmt.Sprintf("unsupported Content-Type %q, expected application/json", ct), nil))
+		return false
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+			restful.WriteError(w, r, restful.NewAppError(restful.ErrTooLarge,
+				fmt.Sprintf("request body exceeds %d bytes", maxErr.Limit), err))
+		} else {
+			restful.WriteError(w, r, restful.NewAppError(restful.ErrInvalidJSON, restful.ErrInvalidBody.Message, err))
+		}
+		return false
+	}
+	if err := unmarshaler.Unmarshal(body, msg); err != nil {
+		restful.WriteError(w, r, restful.NewAppError(restful.ErrInvalidJSON, restful.ErrInvalidBody.Message, err).
+			WithDetail(err.Error()))
+		return false
+	}
+	return true
+}
+
+// invoke 转发请求头为 metadata 后调用 call，把响应 metadata 写回头部，再写入响应体或错误。
+// gRPC 状态错误由 restful.WriteError 转换，BadRequest、RetryInfo 分别成为 fields 和 Retry-After。
+func invoke(w http.ResponseWriter, r *http.Request, status int,
+	call func(context.Context, ...grpc.CallOption) (proto.Message, error)) {
+	var header, trailer metadata.MD
+	ctx := metadata.NewOutgoingContext(r.Context(), outgoingMetadata(r))
+	resp, err := call(ctx, grpc.Header(&header), grpc.Trailer(&trailer))
+
+	writeMetadata(w, header)
+	writeMetadata(w, trailer)
+	if err != nil {
+		restful.WriteError(w, r, err)
+		return
+	}
+	if status == http.StatusNoContent {
+		restful.WriteNoContent(w)
+		return
+	}
+	body, err := marshaler.Marshal(resp)
+	if err != nil {
+		restful.WriteError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// ── metadata ────────────────────────────────────────
+
+// outgoingMetadata 把 HTTP 请求头转换为 gRPC metadata。
+// 只转发 Authorization、请求 ID 和 Grpc-Metadata-* 头部（gRPC 保留的 grpc- 前缀除外）；
+// Cookie、hop-by-hop 头部等不属于 RPC 语义的头部不转发。
+func outgoingMetadata(r *http.Request) metadata.MD {
+	md := metadata.MD{}
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		md.Set("authorization", auth)
+	}
+	// 经过 restful.RequestID 中间件时使用它分配的 ID，否则转发客户端传入的值。
+	if id := restful.RequestIDFromContext(r.Context()); id != "" {
+		md.Set("x-request-id", id)
+	} else if id := r.Header.Get(restful.RequestIDHeader); id != "" {
+		md.Set("x-request-id", id)
+	}
+	for name, values := range r.Header {
+		key, ok := strings.CutPrefix(name, MetadataHeaderPrefix)
+		if key = strings.ToLower(key); ok && key != "" && !strings.HasPrefix(key, "grpc-") {
+			md.Append(key, values...)
+		}
+	}
+	return md
+}
+
+// writeMetadata 把 gRPC 响应 metadata 写为 Grpc-Metadata-* 响应头。
+// gRPC 协议自身的伪头部（content-type 和 grpc- 前缀）不写回。
+func writeMetadata(w http.ResponseWriter, md metadata.MD) {
+	for key, values := range md {
+		if key == "content-type" || strings.HasPrefix(key, "grpc-") {
+			continue
+		}
+		for _, v := range values {
+			w.Header().Add(MetadataHeaderPrefix+key, v)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	apidesigngrpc "go-notes/goprincipleandpractise/api-design/grpc"
+	pb "go-notes/goprincipleandpractise/api-design/grpc/pb"
+	"go-notes/goprincipleandpractise/api-design/restful"
+)
+
+const testToken = "test-token"
+
+// newGateway 启动进程内 gRPC 服务端，返回挂在其上的 Gateway。
+func newGateway(t *testing.T, svc pb.UserServiceServer) http.Handler {
+	t.Helper()
+	srv := apidesigngrpc.NewGRPCServer(testToken, svc)
+	t.Cleanup(srv.Stop)
+	conn, err := DialInProcess(srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return New(pb.NewUserServiceClient(conn))
+}
+
+func do(t *testing.T, h http.Handler, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGatewayCRUD(t *testing.T) {
+	h := newGateway(t, apidesigngrpc.NewUserService())
+
+	rec := do(t, h, http.MethodPost, "/v1/users", `{"name":"Alice","email":"alice@example.com","age":30}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var user map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	id, _ := user["id"].(string)
+	// 字段名与 proto 一致（snake_case），Timestamp 编码为 RFC 3339 字符串。
+	if _, ok := user["create_time"].(string); !ok || id == "" || rec.Header().Get("Location") != "/v1/users/"+id {
+		t.Errorf("create: body = %s, Location = %q", rec.Body, rec.Header().Get("Location"))
+	}
+
+	if rec := do(t, h, http.MethodGet, "/v1/users/"+id, ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "alice@example.com") {
+		t.Errorf("get: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	do(t, h, http.MethodPost, "/v1/users", `{"name":"Bob","email":"bob@example.com","age":17}`)
+
+	rec = do(t, h, http.MethodGet, "/v1/users?page_size=1&filter=age%20%3E%3D%2018", "")
+	var list pb.ListUsersResponse
+	if err := unmarshaler.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("list: %v (%s)", err, rec.Body)
+	}
+	if len(list.Users) != 1 || list.Users[0].Id != id || list.NextPageToken != "" {
+		t.Errorf("list = %v", &list)
+	}
+
+	if rec := do(t, h, http.MethodDelete, "/v1/users/"+id, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: status = %d", rec.Code)
+	}
+	if rec := do(t, h, http.MethodGet, "/v1/users/"+id, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get after delete: status = %d", rec.Code)
+	}
+}
+
+func TestGatewayErrors(t *testing.T) {
+	h := newGateway(t, apidesigngrpc.NewUserService())
+	do(t, h, http.MethodPost, "/v1/users", `{"name":"Alice","email":"alice@example.com"}`)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		header     []string
+		wantStatus int
+		wantCode   restful.ErrCode
+		wantFields []string
+	}{
+		{"email taken", http.MethodPost, "/v1/users", `{"name":"A2","email":"alice@example.com"}`, nil,
+			http.StatusConflict, restful.ErrConflict, nil},
+		{"missing fields", http.MethodPost, "/v1/users", `{}`, nil,
+			http.StatusUnprocessableEntity, restful.ErrValidationFailed, []string{"name", "email"}},
+		{"unknown field", http.MethodPost, "/v1/users", `{"name":"B","email":"b@example.com","role":"admin"}`, nil,
+			http.StatusBadRequest, restful.ErrInvalidJSON, nil},
+		{"wrong media type", http.MethodPost, "/v1/users", `name=B`, []string{"Content-Type", "application/x-www-form-urlencoded"},
+			http.StatusUnsupportedMediaType, restful.ErrUnsupportedMedia, nil},
+		{"not found", http.MethodGet, "/v1/users/usr_404", "", nil,
+			http.StatusNotFound, restful.ErrNotFound, nil},
+		{"bad page size", http.MethodGet, "/v1/users?page_size=ten", "", nil,
+			http.StatusBadRequest, restful.ErrInvalidQuery, nil},
+		{"bad order_by", http.MethodGet, "/v1/users?order_by=password", "", nil,
+			http.StatusBadRequest, restful.ErrInvalidArgument, []string{"order_by"}},
+		{"bad token", http.MethodGet, "/v1/users", "", []string{"Authorization", "Bearer wrong"},
+			http.StatusUnauthorized, restful.ErrUnauthorized, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, h, tt.method, tt.target, tt.body, tt.header...)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var resp restful.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("body = %s: %v", rec.Body, err)
+			}
+			if resp.Error.Code != tt.wantCode {
+				t.Errorf("code = %s, want %s", resp.Error.Code, tt.wantCode)
+			}
+			for _, field := range tt.wantFields {
+				if _, ok := resp.Error.Fields[field]; !ok {
+					t.Errorf("fields = %v, missing %s", resp.Error.Fields, field)
+				}
+			}
+		})
+	}
+}
+
+// metadataService 记录收到的 metadata，并通过 header 和 trailer 回传。
+type metadataService struct {
+	*apidesigngrpc.UserService
+	got chan metadata.MD
+}
+
+func (s metadataService) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.got <- md
+	_ = grpc.SetHeader(ctx, metadata.Pairs("x-served-by", "replica-1"))
+	_ = grpc.SetTrailer(ctx, metadata.Pairs("x-cost", "3"))
+	return &pb.User{Id: req.Id}, nil
+}
+
+func TestGatewayMetadata(t *testing.T) {
+	svc := metadataService{UserService: apidesigngrpc.NewUserService(), got: make(chan metadata.MD, 1)}
+	h := restful.RequestID(newGateway(t, svc))
+
+	rec := do(t, h, http.MethodGet, "/v1/users/usr_1", "",
+		"X-Request-ID", "req-123",
+		"Grpc-Metadata-Tenant", "acme",
+		"Grpc-Metadata-Grpc-Timeout", "1S",
+		"Cookie", "session=secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	md := <-svc.got
+	for key, want := range map[string]string{"authorization": "Bearer " + testToken, "x-request-id": "req-123", "tenant": "acme"} {
+		if got := md.Get(key); len(got) != 1 || got[0] != want {
+			t.Errorf("metadata %s = %v, want %q", key, got, want)
+		}
+	}
+	if len(md.Get("cookie")) != 0 || len(md.Get("grpc-timeout")) != 0 {
+		t.Errorf("unexpected metadata forwarded: %v", md)
+	}
+
+	if got := rec.Header().Get("Grpc-Metadata-X-Served-By"); got != "replica-1" {
+		t.Errorf("header metadata = %q", got)
+	}
+	if got := rec.Header().Get("Grpc-Metadata-X-Cost"); got != "3" {
+		t.Errorf("trailer metadata = %q", got)
+	}
+}
@@ -0,0 +1,422 @@
+package apidesigngrpc
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go-notes/goprincipleandpractise/api-design/apierror"
+	pb "go-notes/goprincipleandpractise/api-design/grpc/pb"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+// ── 字段 ────────────────────────────────────────────
+
+// userField 描述一个可过滤、可排序的字段。值只有 string 和 int64 两种类型，时间统一转为 Unix 纳秒。
+type userField struct {
+	numeric bool
+	value   func(*pb.User) any
+	// parse 把过滤条件中的字面量转换为与 value 相同类型的值。
+	parse func(string) (any, error)
+}
+
+func parseString(s string) (any, error) { return s, nil }
+
+func parseInt(s string) (any, error) {
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func parseTime(s string) (any, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not an RFC 3339 time", s)
+	}
+	return t.UnixNano(), nil
+}
+
+var userFields = map[string]userField{
+	"id":          {value: func(u *pb.User) any { return u.Id }, parse: parseString},
+	"name":        {value: func(u *pb.User) any { return u.Name }, parse: parseString},
+	"email":       {value: func(u *pb.User) any { return u.Email }, parse: parseString},
+	"age":         {numeric: true, value: func(u *pb.User) any { return int64(u.Age) }, parse: parseInt},
+	"create_time": {numeric: true, value: func(u *pb.User) any { return u.CreateTime.AsTime().UnixNano() }, parse: parseTime},
+}
+
+func compareValues(a, b any) int {
+	if av, ok := a.(int64); ok {
+		return cmp.Compare(av, b.(int64))
+	}
+	return cmp.Compare(a.(string), b.(string))
+}
+
+// ── order_by（AIP-132）──────────────────────────────
+
+type orderKey struct {
+	field string
+	desc  bool
+}
+
+// parseOrderBy 解析 "name desc, create_time"。id 唯一，总是作为最后的决胜键，
+// 这样任意排序下的顺序都是确定的，page token 才不会跳过或重复记录。
+func parseOrderBy(s string) ([]orderKey, error) {
+	var keys []orderKey
+	seen := make(map[string]bool)
+	if strings.TrimSpace(s) != "" {
+		for part := range strings.SplitSeq(s, ",") {
+			words := strings.Fields(part)
+			if len(words) == 0 || len(words) > 2 || (len(words) == 2 && words[1] != "desc" && words[1] != "asc") {
+				return nil, invalidArgument("order_by", "malformed clause %q", strings.TrimSpace(part))
+			}
+			key := orderKey{field: words[0], desc: len(words) == 2 && words[1] == "desc"}
+			if _, ok := userFields[key.field]; !ok {
+				return nil, invalidArgument("order_by", "unknown field %q", key.field)
+			}
+			if seen[key.field] {
+				return nil, invalidArgument("order_by", "duplicate field %q", key.field)
+			}
+			seen[key.field] = true
+			keys = append(keys, key)
+		}
+	}
+	if !seen["id"] {
+		keys = append(keys, orderKey{field: "id"})
+	}
+	return keys, nil
+}
+
+func formatOrderBy(keys []orderKey) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k.field
+		if k.desc {
+			parts[i] += " desc"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sortValues(u *pb.User, keys []orderKey) []any {
+	vals := make([]any, len(keys))
+	for i, k := range keys {
+		vals[i] = userFields[k.field].value(u)
+	}
+	return vals
+}
+
+func compareSortValues(a, b []any, keys []orderKey) int {
+	for i, k := range keys {
+		c := compareValues(a[i], b[i])
+		if k.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// ── filter（AIP-160 子集）───────────────────────────
+//
+//	filter     = comparison { "AND" comparison }
+//	comparison = field op value
+//	op         = "=" | "!=" | "<" | "<=" | ">" | ">="
+//	value      = "quoted string" | bare-word
+//
+// 不支持 OR、NOT、括号和 ":"（has）运算符，出现时返回 InvalidArgument 而不是忽略。
+
+type condition struct {
+	field string
+	op    string
+	raw   string // 字面量，用于规范化
+	value any
+}
+
+// parseFilter 解析 filter，返回条件列表。
+func parseFilter(s string) ([]condition, error) {
+	toks, err := lexFilter(s)
+	if err != nil {
+		return nil, err
+	}
+	var conds []condition
+	for i := 0; i < len(toks); {
+		if len(conds) > 0 {
+			if toks[i].text != "AND" || toks[i].quoted {
+				return nil, invalidArgument("filter", "expected AND, got %q", toks[i].text)
+			}
+			i++
+		}
+		if i+3 > len(toks) {
+			return nil, invalidArgument("filter", "incomplete comparison at end of filter")
+		}
+		fieldTok, opTok, valueTok := toks[i], toks[i+1], toks[i+2]
+		i += 3
+
+		field, ok := userFields[fieldTok.text]
+		if !ok || fieldTok.quoted {
+			return nil, invalidArgument("filter", "unknown field %q", fieldTok.text)
+		}
+		if !slices.Contains([]string{"=", "!=", "<", "<=", ">", ">="}, opTok.text) || opTok.quoted {
+			return nil, invalidArgument("filter", "unsupported operator %q", opTok.text)
+		}
+		c := condition{field: fieldTok.text, op: opTok.text, raw: valueTok.text}
+		if isWildcard(c.raw) {
+			if field.numeric || (c.op != "=" && c.op != "!=") {
+				return nil, invalidArgument("filter", "wildcard is only allowed in = and != on string fields")
+			}
+			c.value = c.raw
+		} else if c.value, err = field.parse(c.raw); err != nil {
+			return nil, invalidArgument("filter", "invalid value for %s: %v", c.field, err)
+		}
+		conds = append(conds, c)
+	}
+	return conds, nil
+}
+
+// isWildcard 判断字符串字面量是否为前缀（"Al*"）或后缀（"*@example.com"）通配。
+func isWildcard(s string) bool {
+	return len(s) > 1 && strings.Count(s, "*") == 1 && (s[0] == '*' || s[len(s)-1] == '*')
+}
+
+func (c condition) matches(u *pb.User) bool {
+	v := userFields[c.field].value(u)
+	if isWildcard(c.raw) && !userFields[c.field].numeric {
+		s, match := v.(string), false
+		if prefix, ok := strings.CutSuffix(c.raw, "*"); ok {
+			match = strings.HasPrefix(s, prefix)
+		} else {
+			match = strings.HasSuffix(s, c.raw[1:])
+		}
+		return match == (c.op == "=")
+	}
+	r := compareValues(v, c.value)
+	switch c.op {
+	case "=":
+		return r == 0
+	case "!=":
+		return r != 0
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	case ">":
+		return r > 0
+	default: // ">="
+		return r >= 0
+	}
+}
+
+func formatFilter(conds []condition) string {
+	parts := make([]string, len(conds))
+	for i, c := range conds {
+		parts[i] = c.field + " " + c.op + " " + strconv.Quote(c.raw)
+	}
+	return strings.Join(parts, " AND ")
+}
+
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+func lexFilter(s string) ([]filterToken, error) {
+	var toks []filterToken
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, invalidArgument("filter", "unterminated string")
+			}
+			text, err := strconv.Unquote(s[i : end+1])
+			if err != nil {
+				return nil, invalidArgument("filter", "malformed string %s", s[i:end+1])
+			}
+			toks = append(toks, filterToken{text: text, quoted: true})
+			i = end + 1
+		case strings.IndexByte("=!<>:", c) >= 0:
+			end := i + 1
+			if end < len(s) && s[end] == '=' {
+				end++
+			}
+			toks = append(toks, filterToken{text: s[i:end]})
+			i = end
+		default:
+			end := i
+			for end < len(s) && !unicode.IsSpace(rune(s[end])) && strings.IndexByte(`=!<>:"()`, s[end]) < 0 {
+				end++
+			}
+			if end == i {
+				return nil, invalidArgument("filter", "unexpected %q", string(c))
+			}
+			toks = append(toks, filterToken{text: s[i:end]})
+			i = end
+		}
+	}
+	return toks, nil
+}
+
+// ── page token（AIP-158）────────────────────────────
+
+// pageTokenVersion 是 page token 的格式版本。格式变化时递增，旧版本的 token 返回 InvalidArgument，
+// 客户端从第一页重新开始。
+const pageTokenVersion = 1
+
+// pageToken 是 page token 的内部结构，编码为 base64(JSON) + "." + base64(HMAC)。
+// 签名防止客户端伪造排序键读取任意位置；Query 让服务端拒绝在改变 filter/order_by 后继续使用旧 token。
+type pageToken struct {
+	Version int      `json:"v"`
+	Query   string   `json:"q"` // 规范化的 filter 和 order_by 的摘要
+	After   []string `json:"a"` // 上一页最后一条记录的排序键值
+}
+
+// listQuery 是解析后的 ListUsers 请求。
+type listQuery struct {
+	filter  []condition
+	orderBy []orderKey
+	after   []any // 非 nil 时只返回排在 after 之后的记录
+}
+
+func (q listQuery) digest() string {
+	sum := sha256.Sum256([]byte(formatFilter(q.filter) + "\n" + formatOrderBy(q.orderBy)))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (s *UserService) signPageToken(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.pageTokenKey)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+func (s *UserService) encodePageToken(last *pb.User, q listQuery) string {
+	t := pageToken{Version: pageTokenVersion, Query: q.digest()}
+	for _, v := range sortValues(last, q.orderBy) {
+		if n, ok := v.(int64); ok {
+			t.After = append(t.After, strconv.FormatInt(n, 10))
+		} else {
+			t.After = append(t.After, v.(string))
+		}
+	}
+	payload, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(s.signPageToken(payload))
+}
+
+// decodePageToken 校验签名、版本和查询条件，返回 token 中的排序键值。
+func (s *UserService) decodePageToken(token string, q listQuery) ([]any, error) {
+	errInvalid := invalidArgument("page_token", "page token is malformed or was not issued by this service")
+
+	encPayload, encSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errInvalid
+	}
+	payload, err1 := base64.RawURLEncoding.DecodeString(encPayload)
+	sig, err2 := base64.RawURLEncoding.DecodeString(encSig)
+	if err1 != nil || err2 != nil || !hmac.Equal(sig, s.signPageToken(payload)) {
+		return nil, errInvalid
+	}
+	var t pageToken
+	if err := json.Unmarshal(payload, &t); err != nil {
+		return nil, errInvalid
+	}
+	if t.Version != pageTokenVersion {
+		return nil, invalidArgument("page_token", "page token version %d is not supported, restart from the first page", t.Version)
+	}
+	if t.Query != q.digest() || len(t.After) != len(q.orderBy) {
+		return nil, invalidArgument("page_token", "filter and order_by must not change between pages")
+	}
+
+	after := make([]any, len(q.orderBy))
+	for i, k := range q.orderBy {
+		if !userFields[k.field].numeric {
+			after[i] = t.After[i]
+			continue
+		}
+		n, err := strconv.ParseInt(t.After[i], 10, 64)
+		if err != nil {
+			return nil, errInvalid
+		}
+		after[i] = n
+	}
+	return after, nil
+}
+
+// ── ListUsers ───────────────────────────────────────
+
+func (s *UserService) parseListRequest(req *pb.ListUsersRequest) (listQuery, int, error) {
+	pageSize := int(req.PageSize)
+	switch {
+	case pageSize < 0:
+		return listQuery{}, 0, invalidArgument("page_size", "must not be negative")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	var q listQuery
+	var err error
+	if q.filter, err = parseFilter(req.Filter); err != nil {
+		return listQuery{}, 0, err
+	}
+	if q.orderBy, err = parseOrderBy(req.OrderBy); err != nil {
+		return listQuery{}, 0, err
+	}
+	if req.PageToken != "" {
+		if q.after, err = s.decodePageToken(req.PageToken, q); err != nil {
+			return listQuery{}, 0, err
+		}
+	}
+	return q, pageSize, nil
+}
+
+// page 返回 users 中满足 q 的一页，以及是否还有下一页。
+// 翻页基于排序键（keyset）而不是下标: 上一页的最后一条记录被删除后，下一页仍从它原来的位置继续。
+func (q listQuery) page(users []*pb.User, pageSize int) ([]*pb.User, bool) {
+	matched := slices.DeleteFunc(users, func(u *pb.User) bool {
+		return slices.ContainsFunc(q.filter, func(c condition) bool { return !c.matches(u) })
+	})
+	slices.SortFunc(matched, func(a, b *pb.User) int {
+		return compareSortValues(sortValues(a, q.orderBy), sortValues(b, q.orderBy), q.orderBy)
+	})
+	if q.after != nil {
+		i, _ := slices.BinarySearchFunc(matched, q.after, func(u *pb.User, after []any) int {
+			if compareSortValues(sortValues(u, q.orderBy), after, q.orderBy) <= 0 {
+				return -1
+			}
+			return 1
+		})
+		matched = matched[i:]
+	}
+	if len(matched) > pageSize {
+		return matched[:pageSize], true
+	}
+	return matched, false
+}
+
+// invalidArgument 返回带 BadRequest 字段违规的 InvalidArgument 错误。
+func invalidArgument(field, format string, args ...any) error {
+	desc := fmt.Sprintf(format, args...)
+	return apierror.New(apierror.InvalidArgument, "invalid "+field+": "+desc, apierror.BadRequest(map[string]string{field: desc}))
+}
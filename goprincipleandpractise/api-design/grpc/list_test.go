@@ -0,0 +1,211 @@
+package apidesigngrpc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "go-notes/goprincipleandpractise/api-design/grpc/pb"
+)
+
+// seedUsers 创建 n 个用户，年龄依次为 20、21、…，名字为 User00、User01、…。
+func seedUsers(t *testing.T, svc *UserService, n int) []*pb.User {
+	t.Helper()
+	users := make([]*pb.User, n)
+	for i := range n {
+		u, err := svc.create(&pb.CreateUserRequest{
+			Name:  fmt.Sprintf("User%02d", i),
+			Email: fmt.Sprintf("user%02d@example.com", i),
+			Age:   int32(20 + i),
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		users[i] = u
+	}
+	return users
+}
+
+func userIDs(users []*pb.User) []string {
+	ids := make([]string, len(users))
+	for i, u := range users {
+		ids[i] = u.Id
+	}
+	return ids
+}
+
+// listAll 翻完所有页，返回按顺序拿到的用户 ID。
+func listAll(t *testing.T, client pb.UserServiceClient, req *pb.ListUsersRequest) []string {
+	t.Helper()
+	ctx := authContext(t, testToken)
+	var ids []string
+	for {
+		resp, err := client.ListUsers(ctx, req)
+		if err != nil {
+			t.Fatalf("ListUsers: %v", err)
+		}
+		ids = append(ids, userIDs(resp.Users)...)
+		if resp.NextPageToken == "" {
+			return ids
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+func TestListUsersPagination(t *testing.T) {
+	svc := NewUserService()
+	users := seedUsers(t, svc, 7)
+	client := dialUserService(t, svc)
+	ctx := authContext(t, testToken)
+
+	if got, want := listAll(t, client, &pb.ListUsersRequest{PageSize: 3}), userIDs(users); !slices.Equal(got, want) {
+		t.Errorf("ids = %v, want %v", got, want)
+	}
+
+	// 上一页最后一条被删除后，下一页从它原来的位置继续，不跳过也不重复。
+	first, err := client.ListUsers(ctx, &pb.ListUsersRequest{PageSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DeleteUser(ctx, &pb.DeleteUserRequest{Id: users[2].Id}); err != nil {
+		t.Fatal(err)
+	}
+	next, err := client.ListUsers(ctx, &pb.ListUsersRequest{PageSize: 3, PageToken: first.NextPageToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := userIDs(next.Users), userIDs(users[3:6]); !slices.Equal(got, want) {
+		t.Errorf("page after delete = %v, want %v", got, want)
+	}
+}
+
+func TestListUsersFilterAndOrder(t *testing.T) {
+	svc := NewUserService()
+	users := seedUsers(t, svc, 5)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, u := range users {
+		u.CreateTime = timestamppb.New(base.Add(time.Duration(i) * time.Hour))
+	}
+	users[3].Age = 21 // 与 users[1] 同龄，检验 id 决胜键
+	client := dialUserService(t, svc)
+	ids := func(idx ...int) []string {
+		out := make([]string, len(idx))
+		for i, j := range idx {
+			out[i] = users[j].Id
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		filter  string
+		orderBy string
+		want    []string
+	}{
+		{"default order", "", "", ids(0, 1, 2, 3, 4)},
+		{"desc", "", "age desc", ids(4, 2, 1, 3, 0)},
+		{"tiebreak desc", "", "age desc, id desc", ids(4, 2, 3, 1, 0)},
+		{"numeric range", "age >= 21 AND age < 24", "", ids(1, 2, 3)},
+		{"string equal", `name = "User02"`, "", ids(2)},
+		{"prefix wildcard", `email = "user0*" AND age != 21`, "name desc", ids(4, 2, 0)},
+		{"suffix wildcard", `name != "*3"`, "", ids(0, 1, 2, 4)},
+		{"time", `create_time >= "2026-01-01T02:00:00Z"`, "create_time desc", ids(4, 3, 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := listAll(t, client, &pb.ListUsersRequest{PageSize: 2, Filter: tt.filter, OrderBy: tt.orderBy})
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ids = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListUsersInvalidArgument(t *testing.T) {
+	svc := NewUserService()
+	seedUsers(t, svc, 3)
+	client := dialUserService(t, svc)
+	ctx := authContext(t, testToken)
+
+	first, err := client.ListUsers(ctx, &pb.ListUsersRequest{PageSize: 1, Filter: "age > 0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := first.NextPageToken
+
+	// 用服务端的密钥重新签名一个未来版本的 token，模拟格式升级后客户端带来的旧 token。
+	payload, _ := json.Marshal(pageToken{Version: pageTokenVersion + 1})
+	otherVersion := base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(svc.signPageToken(payload))
+
+	// 篡改 payload 中的一个字符，签名不再匹配。
+	tampered := []byte(token)
+	tampered[len(tampered)/4] ^= 1
+
+	tests := []struct {
+		name string
+		req  *pb.ListUsersRequest
+	}{
+		{"negative page size", &pb.ListUsersRequest{PageSize: -1}},
+		{"unknown filter field", &pb.ListUsersRequest{Filter: "password = x"}},
+		{"unsupported operator", &pb.ListUsersRequest{Filter: "name : x"}},
+		{"or", &pb.ListUsersRequest{Filter: "age = 1 OR age = 2"}},
+		{"incomplete", &pb.ListUsersRequest{Filter: "age >="}},
+		{"bad number", &pb.ListUsersRequest{Filter: "age > old"}},
+		{"bad time", &pb.ListUsersRequest{Filter: "create_time > yesterday"}},
+		{"wildcard on number", &pb.ListUsersRequest{Filter: "age = 2*"}},
+		{"unterminated string", &pb.ListUsersRequest{Filter: `name = "Al`}},
+		{"unknown order field", &pb.ListUsersRequest{OrderBy: "password"}},
+		{"duplicate order field", &pb.ListUsersRequest{OrderBy: "name, name desc"}},
+		{"bad direction", &pb.ListUsersRequest{OrderBy: "name descending"}},
+		{"garbage token", &pb.ListUsersRequest{PageToken: "not-a-token"}},
+		{"tampered token", &pb.ListUsersRequest{Filter: "age > 0", PageToken: string(tampered)}},
+		{"token version", &pb.ListUsersRequest{PageToken: otherVersion}},
+		{"filter changed", &pb.ListUsersRequest{Filter: "age > 1", PageToken: token}},
+		{"order changed", &pb.ListUsersRequest{Filter: "age > 0", OrderBy: "name", PageToken: token}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.ListUsers(ctx, tt.req)
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("err = %v, want InvalidArgument", err)
+			}
+		})
+	}
+
+	// 规范化后相同的 filter 可以继续翻页。
+	_, err = client.ListUsers(ctx, &pb.ListUsersRequest{Filter: `age  >  "0"`, PageToken: token})
+	if err != nil {
+		t.Errorf("equivalent filter: %v", err)
+	}
+}
+
+func TestPageTokenKey(t *testing.T) {
+	key := []byte(strings.Repeat("k", 32))
+	a, b, c := NewUserService(WithPageTokenKey(key)), NewUserService(WithPageTokenKey(key)), NewUserService()
+	for _, svc := range []*UserService{a, b, c} {
+		seedUsers(t, svc, 2)
+	}
+	ctx := authContext(t, testToken)
+	first, err := dialUserService(t, a).ListUsers(ctx, &pb.ListUsersRequest{PageSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 共享密钥的副本接受彼此签发的 token，随机密钥的实例拒绝。
+	if _, err := dialUserService(t, b).ListUsers(ctx, &pb.ListUsersRequest{PageToken: first.NextPageToken}); err != nil {
+		t.Errorf("shared key: %v", err)
+	}
+	_, err = dialUserService(t, c).ListUsers(ctx, &pb.ListUsersRequest{PageToken: first.NextPageToken})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("other key: err = %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,8 @@
+// Package pb 是 user.proto 生成的消息类型和 gRPC 桩代码。
+//
+// 修改 user.proto 后重新生成（需要 protoc、protoc-gen-go 和 protoc-gen-go-grpc）:
+//
+//	go generate ./goprincipleandpractise/api-design/grpc/pb
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative user.proto
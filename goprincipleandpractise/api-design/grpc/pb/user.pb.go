@@ -0,0 +1,1075 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: user.proto
+
+package pb
+
+import (
+	status "google.golang.org/genproto/googleapis/rpc/status"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// EventType 是用户变更的类型。
+type EventType int32
+
+const (
+	EventType_EVENT_TYPE_UNSPECIFIED EventType = 0
+	EventType_EVENT_TYPE_CREATED     EventType = 1
+	EventType_EVENT_TYPE_DELETED     EventType = 2
+)
+
+// Enum value maps for EventType.
+var (
+	EventType_name = map[int32]string{
+		0: "EVENT_TYPE_UNSPECIFIED",
+		1: "EVENT_TYPE_CREATED",
+		2: "EVENT_TYPE_DELETED",
+	}
+	EventType_value = map[string]int32{
+		"EVENT_TYPE_UNSPECIFIED": 0,
+		"EVENT_TYPE_CREATED":     1,
+		"EVENT_TYPE_DELETED":     2,
+	}
+)
+
+func (x EventType) Enum() *EventType {
+	p := new(EventType)
+	*p = x
+	return p
+}
+
+func (x EventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_user_proto_enumTypes[0].Descriptor()
+}
+
+func (EventType) Type() protoreflect.EnumType {
+	return &file_user_proto_enumTypes[0]
+}
+
+func (x EventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EventType.Descriptor instead.
+func (EventType) EnumDescriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{0}
+}
+
+// User 是用户资源。
+type User struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Email         string                 `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
+	Age           int32                  `protobuf:"varint,4,opt,name=age,proto3" json:"age,omitempty"`
+	CreateTime    *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=create_time,json=createTime,proto3" json:"create_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *User) Reset() {
+	*x = User{}
+	mi := &file_user_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *User) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*User) ProtoMessage() {}
+
+func (x *User) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use User.ProtoReflect.Descriptor instead.
+func (*User) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *User) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *User) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *User) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *User) GetAge() int32 {
+	if x != nil {
+		return x.Age
+	}
+	return 0
+}
+
+func (x *User) GetCreateTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreateTime
+	}
+	return nil
+}
+
+// CreateUserRequest 是 CreateUser 的请求。
+type CreateUserRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Email         string                 `protobuf:"bytes,2,opt,name=email,proto3" json:"email,omitempty"`
+	Age           int32                  `protobuf:"varint,3,opt,name=age,proto3" json:"age,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateUserRequest) Reset() {
+	*x = CreateUserRequest{}
+	mi := &file_user_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateUserRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateUserRequest) ProtoMessage() {}
+
+func (x *CreateUserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateUserRequest.ProtoReflect.Descriptor instead.
+func (*CreateUserRequest) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateUserRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateUserRequest) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *CreateUserRequest) GetAge() int32 {
+	if x != nil {
+		return x.Age
+	}
+	return 0
+}
+
+// GetUserRequest 是 GetUser 的请求。
+type GetUserRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserRequest) Reset() {
+	*x = GetUserRequest{}
+	mi := &file_user_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserRequest) ProtoMessage() {}
+
+func (x *GetUserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserRequest.ProtoReflect.Descriptor instead.
+func (*GetUserRequest) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetUserRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+// ListUsersRequest 是 ListUsers 的请求。
+type ListUsersRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 每页最多返回的条数，0 表示使用默认值。
+	PageSize int32 `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// 上一页响应中的 next_page_token，为空表示第一页。
+	// 翻页时 filter 和 order_by 必须与第一页相同，page_size 可以改变。
+	PageToken string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	// AIP-160 风格的过滤条件，例如 `age >= 18 AND name = "Al*"`，为空表示不过滤。
+	// 支持的字段: id、name、email、age、create_time；支持 = != < <= > >=，条件之间用 AND 连接；
+	// 字符串值的 = 和 != 支持前缀或后缀通配符 *；create_time 的值为带引号的 RFC 3339 时间。
+	Filter string `protobuf:"bytes,3,opt,name=filter,proto3" json:"filter,omitempty"`
+	// AIP-132 风格的排序，例如 `name desc, create_time`，为空时按 id 升序。
+	// 总是以 id 作为最后的排序键，保证顺序确定。
+	OrderBy       string `protobuf:"bytes,4,opt,name=order_by,json=orderBy,proto3" json:"order_by,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListUsersRequest) Reset() {
+	*x = ListUsersRequest{}
+	mi := &file_user_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListUsersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListUsersRequest) ProtoMessage() {}
+
+func (x *ListUsersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListUsersRequest.ProtoReflect.Descriptor instead.
+func (*ListUsersRequest) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListUsersRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListUsersRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListUsersRequest) GetFilter() string {
+	if x != nil {
+		return x.Filter
+	}
+	return ""
+}
+
+func (x *ListUsersRequest) GetOrderBy() string {
+	if x != nil {
+		return x.OrderBy
+	}
+	return ""
+}
+
+// ListUsersResponse 是 ListUsers 的响应。
+type ListUsersResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Users []*User                `protobuf:"bytes,1,rep,name=users,proto3" json:"users,omitempty"`
+	// 下一页的 page_token，为空表示没有更多数据。
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListUsersResponse) Reset() {
+	*x = ListUsersResponse{}
+	mi := &file_user_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListUsersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListUsersResponse) ProtoMessage() {}
+
+func (x *ListUsersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListUsersResponse.ProtoReflect.Descriptor instead.
+func (*ListUsersResponse) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListUsersResponse) GetUsers() []*User {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+func (x *ListUsersResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+// DeleteUserRequest 是 DeleteUser 的请求。
+type DeleteUserRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteUserRequest) Reset() {
+	*x = DeleteUserRequest{}
+	mi := &file_user_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteUserRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteUserRequest) ProtoMessage() {}
+
+func (x *DeleteUserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteUserRequest.ProtoReflect.Descriptor instead.
+func (*DeleteUserRequest) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeleteUserRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+// UserEvent 是一次用户变更。
+type UserEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 服务内单调递增的事件序号。
+	Sequence uint64    `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Type     EventType `protobuf:"varint,2,opt,name=type,proto3,enum=apidesign.user.v1.EventType" json:"type,omitempty"`
+	// 变更后的用户；EVENT_TYPE_DELETED 时只有 id。
+	User          *User `protobuf:"bytes,3,opt,name=user,proto3" json:"user,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserEvent) Reset() {
+	*x = UserEvent{}
+	mi := &file_user_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserEvent) ProtoMessage() {}
+
+func (x *UserEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserEvent.ProtoReflect.Descriptor instead.
+func (*UserEvent) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *UserEvent) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *UserEvent) GetType() EventType {
+	if x != nil {
+		return x.Type
+	}
+	return EventType_EVENT_TYPE_UNSPECIFIED
+}
+
+func (x *UserEvent) GetUser() *User {
+	if x != nil {
+		return x.User
+	}
+	return nil
+}
+
+// WatchUsersRequest 是 WatchUsers 的请求。
+type WatchUsersRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 为 true 时先为每个现有用户发送一个 EVENT_TYPE_CREATED 事件（sequence 为 0），再推送后续变更，
+	// 快照与变更之间不重不漏。
+	InitialSnapshot bool `protobuf:"varint,1,opt,name=initial_snapshot,json=initialSnapshot,proto3" json:"initial_snapshot,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *WatchUsersRequest) Reset() {
+	*x = WatchUsersRequest{}
+	mi := &file_user_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchUsersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchUsersRequest) ProtoMessage() {}
+
+func (x *WatchUsersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchUsersRequest.ProtoReflect.Descriptor instead.
+func (*WatchUsersRequest) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WatchUsersRequest) GetInitialSnapshot() bool {
+	if x != nil {
+		return x.InitialSnapshot
+	}
+	return false
+}
+
+// BulkImportResult 是一个导入条目的结果，user 和 error 只有一个非空。
+type BulkImportResult struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 条目在请求流中的下标，从 0 开始。
+	Index         int32          `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	User          *User          `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	Error         *status.Status `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkImportResult) Reset() {
+	*x = BulkImportResult{}
+	mi := &file_user_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkImportResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkImportResult) ProtoMessage() {}
+
+func (x *BulkImportResult) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkImportResult.ProtoReflect.Descriptor instead.
+func (*BulkImportResult) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *BulkImportResult) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *BulkImportResult) GetUser() *User {
+	if x != nil {
+		return x.User
+	}
+	return nil
+}
+
+func (x *BulkImportResult) GetError() *status.Status {
+	if x != nil {
+		return x.Error
+	}
+	return nil
+}
+
+// BulkImportUsersResponse 是 BulkImportUsers 的响应。
+type BulkImportUsersResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 按请求顺序排列的结果。
+	Results       []*BulkImportResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	CreatedCount  int32               `protobuf:"varint,2,opt,name=created_count,json=createdCount,proto3" json:"created_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkImportUsersResponse) Reset() {
+	*x = BulkImportUsersResponse{}
+	mi := &file_user_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkImportUsersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkImportUsersResponse) ProtoMessage() {}
+
+func (x *BulkImportUsersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkImportUsersResponse.ProtoReflect.Descriptor instead.
+func (*BulkImportUsersResponse) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *BulkImportUsersResponse) GetResults() []*BulkImportResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *BulkImportUsersResponse) GetCreatedCount() int32 {
+	if x != nil {
+		return x.CreatedCount
+	}
+	return 0
+}
+
+// SyncUsersRequest 是 SyncUsers 中客户端发送的一个写操作。
+type SyncUsersRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 客户端生成的操作 ID，原样出现在对应的 SyncResult 中。
+	RequestId string `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	// Types that are valid to be assigned to Operation:
+	//
+	//	*SyncUsersRequest_Create
+	//	*SyncUsersRequest_Delete
+	Operation     isSyncUsersRequest_Operation `protobuf_oneof:"operation"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncUsersRequest) Reset() {
+	*x = SyncUsersRequest{}
+	mi := &file_user_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncUsersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncUsersRequest) ProtoMessage() {}
+
+func (x *SyncUsersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncUsersRequest.ProtoReflect.Descriptor instead.
+func (*SyncUsersRequest) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SyncUsersRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *SyncUsersRequest) GetOperation() isSyncUsersRequest_Operation {
+	if x != nil {
+		return x.Operation
+	}
+	return nil
+}
+
+func (x *SyncUsersRequest) GetCreate() *CreateUserRequest {
+	if x != nil {
+		if x, ok := x.Operation.(*SyncUsersRequest_Create); ok {
+			return x.Create
+		}
+	}
+	return nil
+}
+
+func (x *SyncUsersRequest) GetDelete() *DeleteUserRequest {
+	if x != nil {
+		if x, ok := x.Operation.(*SyncUsersRequest_Delete); ok {
+			return x.Delete
+		}
+	}
+	return nil
+}
+
+type isSyncUsersRequest_Operation interface {
+	isSyncUsersRequest_Operation()
+}
+
+type SyncUsersRequest_Create struct {
+	Create *CreateUserRequest `protobuf:"bytes,2,opt,name=create,proto3,oneof"`
+}
+
+type SyncUsersRequest_Delete struct {
+	Delete *DeleteUserRequest `protobuf:"bytes,3,opt,name=delete,proto3,oneof"`
+}
+
+func (*SyncUsersRequest_Create) isSyncUsersRequest_Operation() {}
+
+func (*SyncUsersRequest_Delete) isSyncUsersRequest_Operation() {}
+
+// SyncResult 是一个写操作的结果，user 和 error 只有一个非空（删除成功时都为空）。
+type SyncResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	User          *User                  `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	Error         *status.Status         `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncResult) Reset() {
+	*x = SyncResult{}
+	mi := &file_user_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncResult) ProtoMessage() {}
+
+func (x *SyncResult) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncResult.ProtoReflect.Descriptor instead.
+func (*SyncResult) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SyncResult) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *SyncResult) GetUser() *User {
+	if x != nil {
+		return x.User
+	}
+	return nil
+}
+
+func (x *SyncResult) GetError() *status.Status {
+	if x != nil {
+		return x.Error
+	}
+	return nil
+}
+
+// SyncUsersResponse 是 SyncUsers 中服务端发送的消息。
+type SyncUsersResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Response:
+	//
+	//	*SyncUsersResponse_Result
+	//	*SyncUsersResponse_Event
+	Response      isSyncUsersResponse_Response `protobuf_oneof:"response"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncUsersResponse) Reset() {
+	*x = SyncUsersResponse{}
+	mi := &file_user_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncUsersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncUsersResponse) ProtoMessage() {}
+
+func (x *SyncUsersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_user_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncUsersResponse.ProtoReflect.Descriptor instead.
+func (*SyncUsersResponse) Descriptor() ([]byte, []int) {
+	return file_user_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *SyncUsersResponse) GetResponse() isSyncUsersResponse_Response {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+func (x *SyncUsersResponse) GetResult() *SyncResult {
+	if x != nil {
+		if x, ok := x.Response.(*SyncUsersResponse_Result); ok {
+			return x.Result
+		}
+	}
+	return nil
+}
+
+func (x *SyncUsersResponse) GetEvent() *UserEvent {
+	if x != nil {
+		if x, ok := x.Response.(*SyncUsersResponse_Event); ok {
+			return x.Event
+		}
+	}
+	return nil
+}
+
+type isSyncUsersResponse_Response interface {
+	isSyncUsersResponse_Response()
+}
+
+type SyncUsersResponse_Result struct {
+	// 本流中一个写操作的结果。
+	Result *SyncResult `protobuf:"bytes,1,opt,name=result,proto3,oneof"`
+}
+
+type SyncUsersResponse_Event struct {
+	// 其他客户端造成的变更，本流自己的写操作不会作为事件回传。
+	Event *UserEvent `protobuf:"bytes,2,opt,name=event,proto3,oneof"`
+}
+
+func (*SyncUsersResponse_Result) isSyncUsersResponse_Response() {}
+
+func (*SyncUsersResponse_Event) isSyncUsersResponse_Response() {}
+
+var File_user_proto protoreflect.FileDescriptor
+
+const file_user_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"user.proto\x12\x11apidesign.user.v1\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x17google/rpc/status.proto\"\x8f\x01\n" +
+	"\x04User\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05email\x18\x03 \x01(\tR\x05email\x12\x10\n" +
+	"\x03age\x18\x04 \x01(\x05R\x03age\x12;\n" +
+	"\vcreate_time\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"createTime\"O\n" +
+	"\x11CreateUserRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05email\x18\x02 \x01(\tR\x05email\x12\x10\n" +
+	"\x03age\x18\x03 \x01(\x05R\x03age\" \n" +
+	"\x0eGetUserRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x81\x01\n" +
+	"\x10ListUsersRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\x12\x16\n" +
+	"\x06filter\x18\x03 \x01(\tR\x06filter\x12\x19\n" +
+	"\border_by\x18\x04 \x01(\tR\aorderBy\"j\n" +
+	"\x11ListUsersResponse\x12-\n" +
+	"\x05users\x18\x01 \x03(\v2\x17.apidesign.user.v1.UserR\x05users\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"#\n" +
+	"\x11DeleteUserRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x86\x01\n" +
+	"\tUserEvent\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\x04R\bsequence\x120\n" +
+	"\x04type\x18\x02 \x01(\x0e2\x1c.apidesign.user.v1.EventTypeR\x04type\x12+\n" +
+	"\x04user\x18\x03 \x01(\v2\x17.apidesign.user.v1.UserR\x04user\">\n" +
+	"\x11WatchUsersRequest\x12)\n" +
+	"\x10initial_snapshot\x18\x01 \x01(\bR\x0finitialSnapshot\"\x7f\n" +
+	"\x10BulkImportResult\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12+\n" +
+	"\x04user\x18\x02 \x01(\v2\x17.apidesign.user.v1.UserR\x04user\x12(\n" +
+	"\x05error\x18\x03 \x01(\v2\x12.google.rpc.StatusR\x05error\"}\n" +
+	"\x17BulkImportUsersResponse\x12=\n" +
+	"\aresults\x18\x01 \x03(\v2#.apidesign.user.v1.BulkImportResultR\aresults\x12#\n" +
+	"\rcreated_count\x18\x02 \x01(\x05R\fcreatedCount\"\xbe\x01\n" +
+	"\x10SyncUsersRequest\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12>\n" +
+	"\x06create\x18\x02 \x01(\v2$.apidesign.user.v1.CreateUserRequestH\x00R\x06create\x12>\n" +
+	"\x06delete\x18\x03 \x01(\v2$.apidesign.user.v1.DeleteUserRequestH\x00R\x06deleteB\v\n" +
+	"\toperation\"\x82\x01\n" +
+	"\n" +
+	"SyncResult\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12+\n" +
+	"\x04user\x18\x02 \x01(\v2\x17.apidesign.user.v1.UserR\x04user\x12(\n" +
+	"\x05error\x18\x03 \x01(\v2\x12.google.rpc.StatusR\x05error\"\x8e\x01\n" +
+	"\x11SyncUsersResponse\x127\n" +
+	"\x06result\x18\x01 \x01(\v2\x1d.apidesign.user.v1.SyncResultH\x00R\x06result\x124\n" +
+	"\x05event\x18\x02 \x01(\v2\x1c.apidesign.user.v1.UserEventH\x00R\x05eventB\n" +
+	"\n" +
+	"\bresponse*W\n" +
+	"\tEventType\x12\x1a\n" +
+	"\x16EVENT_TYPE_UNSPECIFIED\x10\x00\x12\x16\n" +
+	"\x12EVENT_TYPE_CREATED\x10\x01\x12\x16\n" +
+	"\x12EVENT_TYPE_DELETED\x10\x022\xdc\x04\n" +
+	"\vUserService\x12K\n" +
+	"\n" +
+	"CreateUser\x12$.apidesign.user.v1.CreateUserRequest\x1a\x17.apidesign.user.v1.User\x12E\n" +
+	"\aGetUser\x12!.apidesign.user.v1.GetUserRequest\x1a\x17.apidesign.user.v1.User\x12V\n" +
+	"\tListUsers\x12#.apidesign.user.v1.ListUsersRequest\x1a$.apidesign.user.v1.ListUsersResponse\x12J\n" +
+	"\n" +
+	"DeleteUser\x12$.apidesign.user.v1.DeleteUserRequest\x1a\x16.google.protobuf.Empty\x12R\n" +
+	"\n" +
+	"WatchUsers\x12$.apidesign.user.v1.WatchUsersRequest\x1a\x1c.apidesign.user.v1.UserEvent0\x01\x12e\n" +
+	"\x0fBulkImportUsers\x12$.apidesign.user.v1.CreateUserRequest\x1a*.apidesign.user.v1.BulkImportUsersResponse(\x01\x12Z\n" +
+	"\tSyncUsers\x12#.apidesign.user.v1.SyncUsersRequest\x1a$.apidesign.user.v1.SyncUsersResponse(\x010\x01B7Z5go-notes/goprincipleandpractise/api-design/grpc/pb;pbb\x06proto3"
+
+var (
+	file_user_proto_rawDescOnce sync.Once
+	file_user_proto_rawDescData []byte
+)
+
+func file_user_proto_rawDescGZIP() []byte {
+	file_user_proto_rawDescOnce.Do(func() {
+		file_user_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_user_proto_rawDesc), len(file_user_proto_rawDesc)))
+	})
+	return file_user_proto_rawDescData
+}
+
+var file_user_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_user_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_user_proto_goTypes = []any{
+	(EventType)(0),                  // 0: apidesign.user.v1.EventType
+	(*User)(nil),                    // 1: apidesign.user.v1.User
+	(*CreateUserRequest)(nil),       // 2: apidesign.user.v1.CreateUserRequest
+	(*GetUserRequest)(nil),          // 3: apidesign.user.v1.GetUserRequest
+	(*ListUsersRequest)(nil),        // 4: apidesign.user.v1.ListUsersRequest
+	(*ListUsersResponse)(nil),       // 5: apidesign.user.v1.ListUsersResponse
+	(*DeleteUserRequest)(nil),       // 6: apidesign.user.v1.DeleteUserRequest
+	(*UserEvent)(nil),               // 7: apidesign.user.v1.UserEvent
+	(*WatchUsersRequest)(nil),       // 8: apidesign.user.v1.WatchUsersRequest
+	(*BulkImportResult)(nil),        // 9: apidesign.user.v1.BulkImportResult
+	(*BulkImportUsersResponse)(nil), // 10: apidesign.user.v1.BulkImportUsersResponse
+	(*SyncUsersRequest)(nil),        // 11: apidesign.user.v1.SyncUsersRequest
+	(*SyncResult)(nil),              // 12: apidesign.user.v1.SyncResult
+	(*SyncUsersResponse)(nil),       // 13: apidesign.user.v1.SyncUsersResponse
+	(*timestamppb.Timestamp)(nil),   // 14: google.protobuf.Timestamp
+	(*status.Status)(nil),           // 15: google.rpc.Status
+	(*emptypb.Empty)(nil),           // 16: google.protobuf.Empty
+}
+var file_user_proto_depIdxs = []int32{
+	14, // 0: apidesign.user.v1.User.create_time:type_name -> google.protobuf.Timestamp
+	1,  // 1: apidesign.user.v1.ListUsersResponse.users:type_name -> apidesign.user.v1.User
+	0,  // 2: apidesign.user.v1.UserEvent.type:type_name -> apidesign.user.v1.EventType
+	1,  // 3: apidesign.user.v1.UserEvent.user:type_name -> apidesign.user.v1.User
+	1,  // 4: apidesign.user.v1.BulkImportResult.user:type_name -> apidesign.user.v1.User
+	15, // 5: apidesign.user.v1.BulkImportResult.error:type_name -> google.rpc.Status
+	9,  // 6: apidesign.user.v1.BulkImportUsersResponse.results:type_name -> apidesign.user.v1.BulkImportResult
+	2,  // 7: apidesign.user.v1.SyncUsersRequest.create:type_name -> apidesign.user.v1.CreateUserRequest
+	6,  // 8: apidesign.user.v1.SyncUsersRequest.delete:type_name -> apidesign.user.v1.DeleteUserRequest
+	1,  // 9: apidesign.user.v1.SyncResult.user:type_name -> apidesign.user.v1.User
+	15, // 10: apidesign.user.v1.SyncResult.error:type_name -> google.rpc.Status
+	12, // 11: apidesign.user.v1.SyncUsersResponse.result:type_name -> apidesign.user.v1.SyncResult
+	7,  // 12: apidesign.user.v1.SyncUsersResponse.event:type_name -> apidesign.user.v1.UserEvent
+	2,  // 13: apidesign.user.v1.UserService.CreateUser:input_type -> apidesign.user.v1.CreateUserRequest
+	3,  // 14: apidesign.user.v1.UserService.GetUser:input_type -> apidesign.user.v1.GetUserRequest
+	4,  // 15: apidesign.user.v1.UserService.ListUsers:input_type -> apidesign.user.v1.ListUsersRequest
+	6,  // 16: apidesign.user.v1.UserService.DeleteUser:input_type -> apidesign.user.v1.DeleteUserRequest
+	8,  // 17: apidesign.user.v1.UserService.WatchUsers:input_type -> apidesign.user.v1.WatchUsersRequest
+	2,  // 18: apidesign.user.v1.UserService.BulkImportUsers:input_type -> apidesign.user.v1.CreateUserRequest
+	11, // 19: apidesign.user.v1.UserService.SyncUsers:input_type -> apidesign.user.v1.SyncUsersRequest
+	1,  // 20: apidesign.user.v1.UserService.CreateUser:output_type -> apidesign.user.v1.User
+	1,  // 21: apidesign.user.v1.UserService.GetUser:output_type -> apidesign.user.v1.User
+	5,  // 22: apidesign.user.v1.UserService.ListUsers:output_type -> apidesign.user.v1.ListUsersResponse
+	16, // 23: apidesign.user.v1.UserService.DeleteUser:output_type -> google.protobuf.Empty
+	7,  // 24: apidesign.user.v1.UserService.WatchUsers:output_type -> apidesign.user.v1.UserEvent
+	10, // 25: apidesign.user.v1.UserService.BulkImportUsers:output_type -> apidesign.user.v1.BulkImportUsersResponse
+	13, // 26: apidesign.user.v1.UserService.SyncUsers:output_type -> apidesign.user.v1.SyncUsersResponse
+	20, // [20:27] is the sub-list for method output_type
+	13, // [13:20] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
+}
+
+func init() { file_user_proto_init() }
+func file_user_proto_init() {
+	if File_user_proto != nil {
+		return
+	}
+	file_user_proto_msgTypes[10].OneofWrappers = []any{
+		(*SyncUsersRequest_Create)(nil),
+		(*SyncUsersRequest_Delete)(nil),
+	}
+	file_user_proto_msgTypes[12].OneofWrappers = []any{
+		(*SyncUsersResponse_Result)(nil),
+		(*SyncUsersResponse_Event)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_user_proto_rawDesc), len(file_user_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_user_proto_goTypes,
+		DependencyIndexes: file_user_proto_depIdxs,
+		EnumInfos:         file_user_proto_enumTypes,
+		MessageInfos:      file_user_proto_msgTypes,
+	}.Build()
+	File_user_proto = out.File
+	file_user_proto_goTypes = nil
+	file_user_proto_depIdxs = nil
+}
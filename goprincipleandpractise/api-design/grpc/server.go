@@ -6,9 +6,11 @@ import (
 	"time"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+
+	"go-notes/goprincipleandpractise/api-design/apierror"
+	pb "go-notes/goprincipleandpractise/api-design/grpc/pb"
 )
 
 // ── 拦截器（Interceptor）────────────────────────────
@@ -35,22 +37,29 @@ func AuthInterceptor(validToken string) grpc.UnaryServerInterceptor {
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (any, error) {
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, status.Error(codes.Unauthenticated, "missing metadata")
+		if err := authorize(ctx, validToken); err != nil {
+			return nil, err
 		}
+		return handler(ctx, req)
+	}
+}
 
-		tokens := md.Get("authorization")
-		if len(tokens) == 0 {
-			return nil, status.Error(codes.Unauthenticated, "missing authorization token")
-		}
+// authorize 检查 metadata 中的 Bearer token，一元和流拦截器共用。
+func authorize(ctx context.Context, validToken string) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return apierror.New(apierror.Unauthorized, "missing metadata")
+	}
 
-		if tokens[0] != "Bearer "+validToken {
-			return nil, status.Error(codes.Unauthenticated, "invalid token")
-		}
+	tokens := md.Get("authorization")
+	if len(tokens) == 0 {
+		return apierror.New(apierror.Unauthorized, "missing authorization token")
+	}
 
-		return handler(ctx, req)
+	if tokens[0] != "Bearer "+validToken {
+		return apierror.New(apierror.Unauthorized, "invalid token")
 	}
+	return nil
 }
 
 // RecoveryInterceptor 捕获 handler panic，转换为 Internal 错误。
@@ -63,21 +72,78 @@ func RecoveryInterceptor(
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("[gRPC PANIC] %s: %v", info.FullMethod, r)
-			err = status.Errorf(codes.Internal, "internal server error")
+			err = apierror.New(apierror.InternalError, "internal server error")
 		}
 	}()
 	return handler(ctx, req)
 }
 
-// NewGRPCServer 创建配置好拦截器链的 gRPC 服务器。
+// ── 流拦截器（Stream Interceptor）────────────────────
 //
-// 拦截器执行顺序: Recovery → Logging → Auth
-func NewGRPCServer(authToken string) *grpc.Server {
-	return grpc.NewServer(
+// 流拦截器包住整个流的生命周期而不是单条消息: 认证只在建立流时做一次，
+// 日志记录的是流从建立到结束的总耗时。
+
+// StreamLoggingInterceptor 是 LoggingInterceptor 的流式版本。
+func StreamLoggingInterceptor(
+	srv any,
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	log.Printf("[gRPC] %s (stream) → %s (%s)", info.FullMethod, status.Code(err), time.Since(start))
+	return err
+}
+
+// StreamAuthInterceptor 是 AuthInterceptor 的流式版本，在 handler 收发任何消息之前验证 token。
+func StreamAuthInterceptor(validToken string) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := authorize(ss.Context(), validToken); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// StreamRecoveryInterceptor 是 RecoveryInterceptor 的流式版本。
+// 只能捕获 handler 所在 goroutine 的 panic，handler 自己启动的 goroutine 需要自行 recover。
+func StreamRecoveryInterceptor(
+	srv any,
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[gRPC PANIC] %s: %v", info.FullMethod, r)
+			err = apierror.New(apierror.InternalError, "internal server error")
+		}
+	}()
+	return handler(srv, ss)
+}
+
+// NewGRPCServer 创建配置好拦截器链的 gRPC 服务器，并注册 users。
+//
+// 一元和流式拦截器的执行顺序相同: Recovery → Logging → Auth
+func NewGRPCServer(authToken string, users pb.UserServiceServer) *grpc.Server {
+	srv := grpc.NewServer(
 		grpc.ChainUnaryInterceptor(
 			RecoveryInterceptor,
 			LoggingInterceptor,
 			AuthInterceptor(authToken),
 		),
+		grpc.ChainStreamInterceptor(
+			StreamRecoveryInterceptor,
+			StreamLoggingInterceptor,
+			StreamAuthInterceptor(authToken),
+		),
 	)
+	pb.RegisterUserServiceServer(srv, users)
+	return srv
 }
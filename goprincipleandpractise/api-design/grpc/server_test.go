@@ -2,20 +2,55 @@ package apidesigngrpc
 
 import (
 	"context"
+	"net"
 	"testing"
 
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 
+	"go-notes/goprincipleandpractise/api-design/apierror"
 	pb "go-notes/goprincipleandpractise/api-design/grpc/pb"
 )
 
+const testToken = "test-token"
+
+// dialUserService 在内存监听器（bufconn）上启动 NewGRPCServer 并注册 svc，
+// 返回的客户端走完整的 HTTP/2 传输、编解码和拦截器链。
+func dialUserService(t *testing.T, svc pb.UserServiceServer) pb.UserServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := NewGRPCServer(testToken, svc)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewUserServiceClient(conn)
+}
+
+// authContext 返回携带 authorization metadata 的 context。
+func authContext(t *testing.T, token string) context.Context {
+	return metadata.AppendToOutgoingContext(t.Context(), "authorization", "Bearer "+token)
+}
+
 func TestUserServiceCRUD(t *testing.T) {
-	svc := NewUserService()
-	ctx := context.Background()
+	client := dialUserService(t, NewUserService())
+	ctx := authContext(t, testToken)
 
 	// ── Create ───────────────────────────────────
-	user, err := svc.CreateUser(ctx, &pb.CreateUserRequest{
+	user, err := client.CreateUser(ctx, &pb.CreateUserRequest{
 		Name:  "Alice",
 		Email: "alice@example.com",
 		Age:   30,
@@ -23,7 +58,7 @@ func TestUserServiceCRUD(t *testing.T) {
 	if err != nil {
 		t.Fatalf("CreateUser: %v", err)
 	}
-	if user.ID == "" {
+	if user.Id == "" {
 		t.Fatal("CreateUser: empty ID")
 	}
 	if user.Name != "Alice" {
@@ -31,7 +66,7 @@ func TestUserServiceCRUD(t *testing.T) {
 	}
 
 	// ── Get ──────────────────────────────────────
-	got, err := svc.GetUser(ctx, &pb.GetUserRequest{ID: user.ID})
+	got, err := client.GetUser(ctx, &pb.GetUserRequest{Id: user.Id})
 	if err != nil {
 		t.Fatalf("GetUser: %v", err)
 	}
@@ -40,7 +75,7 @@ func TestUserServiceCRUD(t *testing.T) {
 	}
 
 	// ── List ─────────────────────────────────────
-	list, err := svc.ListUsers(ctx, &pb.ListUsersRequest{PageSize: 10})
+	list, err := client.ListUsers(ctx, &pb.ListUsersRequest{PageSize: 10})
 	if err != nil {
 		t.Fatalf("ListUsers: %v", err)
 	}
@@ -49,13 +84,13 @@ func TestUserServiceCRUD(t *testing.T) {
 	}
 
 	// ── Delete ───────────────────────────────────
-	_, err = svc.DeleteUser(ctx, &pb.DeleteUserRequest{ID: user.ID})
+	_, err = client.DeleteUser(ctx, &pb.DeleteUserRequest{Id: user.Id})
 	if err != nil {
 		t.Fatalf("DeleteUser: %v", err)
 	}
 
 	// ── Get after delete → NotFound ──────────────
-	_, err = svc.GetUser(ctx, &pb.GetUserRequest{ID: user.ID})
+	_, err = client.GetUser(ctx, &pb.GetUserRequest{Id: user.Id})
 	if err == nil {
 		t.Fatal("GetUser after delete: expected error, got nil")
 	}
@@ -65,8 +100,8 @@ func TestUserServiceCRUD(t *testing.T) {
 }
 
 func TestUserServiceErrors(t *testing.T) {
-	svc := NewUserService()
-	ctx := context.Background()
+	client := dialUserService(t, NewUserService())
+	ctx := authContext(t, testToken)
 
 	tests := []struct {
 		name     string
@@ -76,7 +111,7 @@ func TestUserServiceErrors(t *testing.T) {
 		{
 			name: "create: missing name",
 			fn: func() error {
-				_, err := svc.CreateUser(ctx, &pb.CreateUserRequest{Email: "a@b.com"})
+				_, err := client.CreateUser(ctx, &pb.CreateUserRequest{Email: "a@b.com"})
 				return err
 			},
 			wantCode: codes.InvalidArgument,
@@ -84,7 +119,7 @@ func TestUserServiceErrors(t *testing.T) {
 		{
 			name: "create: missing email",
 			fn: func() error {
-				_, err := svc.CreateUser(ctx, &pb.CreateUserRequest{Name: "Bob"})
+				_, err := client.CreateUser(ctx, &pb.CreateUserRequest{Name: "Bob"})
 				return err
 			},
 			wantCode: codes.InvalidArgument,
@@ -92,7 +127,7 @@ func TestUserServiceErrors(t *testing.T) {
 		{
 			name: "get: missing id",
 			fn: func() error {
-				_, err := svc.GetUser(ctx, &pb.GetUserRequest{})
+				_, err := client.GetUser(ctx, &pb.GetUserRequest{})
 				return err
 			},
 			wantCode: codes.InvalidArgument,
@@ -100,7 +135,7 @@ func TestUserServiceErrors(t *testing.T) {
 		{
 			name: "get: not found",
 			fn: func() error {
-				_, err := svc.GetUser(ctx, &pb.GetUserRequest{ID: "nonexistent"})
+				_, err := client.GetUser(ctx, &pb.GetUserRequest{Id: "nonexistent"})
 				return err
 			},
 			wantCode: codes.NotFound,
@@ -108,7 +143,7 @@ func TestUserServiceErrors(t *testing.T) {
 		{
 			name: "delete: not found",
 			fn: func() error {
-				_, err := svc.DeleteUser(ctx, &pb.DeleteUserRequest{ID: "nonexistent"})
+				_, err := client.DeleteUser(ctx, &pb.DeleteUserRequest{Id: "nonexistent"})
 				return err
 			},
 			wantCode: codes.NotFound,
@@ -133,17 +168,17 @@ func TestUserServiceErrors(t *testing.T) {
 }
 
 func TestDuplicateEmail(t *testing.T) {
-	svc := NewUserService()
-	ctx := context.Background()
+	client := dialUserService(t, NewUserService())
+	ctx := authContext(t, testToken)
 
-	_, err := svc.CreateUser(ctx, &pb.CreateUserRequest{
+	_, err := client.CreateUser(ctx, &pb.CreateUserRequest{
 		Name: "Alice", Email: "dup@example.com",
 	})
 	if err != nil {
 		t.Fatalf("first create: %v", err)
 	}
 
-	_, err = svc.CreateUser(ctx, &pb.CreateUserRequest{
+	_, err = client.CreateUser(ctx, &pb.CreateUserRequest{
 		Name: "Bob", Email: "dup@example.com",
 	})
 	if err == nil {
@@ -155,20 +190,74 @@ func TestDuplicateEmail(t *testing.T) {
 	}
 }
 
+func TestAuthInterceptor(t *testing.T) {
+	client := dialUserService(t, NewUserService())
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{"missing token", t.Context(), codes.Unauthenticated},
+		{"invalid token", authContext(t, "wrong"), codes.Unauthenticated},
+		{"valid token", authContext(t, testToken), codes.NotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.GetUser(tt.ctx, &pb.GetUserRequest{Id: "usr_000001"})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("code = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// panickingService 的 GetUser 总是 panic，其余方法未实现。
+type panickingService struct {
+	pb.UnimplementedUserServiceServer
+}
+
+func (panickingService) GetUser(context.Context, *pb.GetUserRequest) (*pb.User, error) {
+	panic("boom")
+}
+
+func TestRecoveryInterceptor(t *testing.T) {
+	client := dialUserService(t, panickingService{})
+	ctx := authContext(t, testToken)
+
+	// panic 被转换为 Internal，连接和服务器仍然可用。
+	for range 2 {
+		if _, err := client.GetUser(ctx, &pb.GetUserRequest{Id: "usr_000001"}); status.Code(err) != codes.Internal {
+			t.Fatalf("GetUser: %v, want Internal", err)
+		}
+	}
+	if _, err := client.ListUsers(ctx, &pb.ListUsersRequest{}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("ListUsers: %v, want Unimplemented", err)
+	}
+}
+
 func TestGRPCCodeToHTTP(t *testing.T) {
 	tests := []struct {
 		code     codes.Code
 		wantHTTP int
 	}{
 		{codes.OK, 200},
+		{codes.Canceled, 499},
+		{codes.Unknown, 500},
 		{codes.InvalidArgument, 400},
-		{codes.Unauthenticated, 401},
-		{codes.PermissionDenied, 403},
+		{codes.DeadlineExceeded, 504},
 		{codes.NotFound, 404},
 		{codes.AlreadyExists, 409},
+		{codes.PermissionDenied, 403},
 		{codes.ResourceExhausted, 429},
+		{codes.FailedPrecondition, 412},
+		{codes.Aborted, 409},
+		{codes.OutOfRange, 400},
+		{codes.Unimplemented, 501},
 		{codes.Internal, 500},
 		{codes.Unavailable, 503},
+		{codes.DataLoss, 500},
+		{codes.Unauthenticated, 401},
 	}
 
 	for _, tt := range tests {
@@ -180,3 +269,27 @@ func TestGRPCCodeToHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestErrorDetails(t *testing.T) {
+	client := dialUserService(t, NewUserService())
+	ctx := authContext(t, testToken)
+
+	// 客户端从 details 中还原精确的错误码、字段违规和 metadata。
+	_, err := client.CreateUser(ctx, &pb.CreateUserRequest{})
+	d := apierror.FromStatus(status.Convert(err))
+	if d.Code != apierror.ValidationFailed || d.Fields["name"] == "" || d.Fields["email"] == "" {
+		t.Errorf("CreateUser details = %+v", d)
+	}
+
+	_, err = client.GetUser(ctx, &pb.GetUserRequest{Id: "usr_404"})
+	d = apierror.FromStatus(status.Convert(err))
+	if d.Code != apierror.NotFound || d.Metadata["id"] != "usr_404" {
+		t.Errorf("GetUser details = %+v", d)
+	}
+
+	_, err = client.ListUsers(ctx, &pb.ListUsersRequest{OrderBy: "password"})
+	d = apierror.FromStatus(status.Convert(err))
+	if d.Code != apierror.InvalidArgument || d.Fields["order_by"] == "" {
+		t.Errorf("ListUsers details = %+v", d)
+	}
+}
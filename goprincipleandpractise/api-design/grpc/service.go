@@ -5,35 +5,70 @@ package apidesigngrpc
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"sync"
-	"time"
 
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"go-notes/goprincipleandpractise/api-design/apierror"
 	pb "go-notes/goprincipleandpractise/api-design/grpc/pb"
 )
 
-// UserService 实现用户管理 gRPC 服务。
+// UserService 实现 user.proto 中定义的 UserService。
+// 嵌入 UnimplementedUserServiceServer: proto 新增方法时未实现的方法返回 Unimplemented，而不是编译失败。
 type UserService struct {
-	mu    sync.RWMutex
-	users map[string]*pb.User
-	seq   int
+	pb.UnimplementedUserServiceServer
+
+	mu       sync.RWMutex
+	users    map[string]*pb.User
+	seq      int
+	eventSeq uint64
+	watchers map[*watcher]struct{} // 由 mu 保护，见 stream.go
+
+	pageTokenKey []byte // page token 的 HMAC 密钥，见 list.go
+}
+
+// UserServiceOption 配置 UserService。
+type UserServiceOption func(*UserService)
+
+// WithPageTokenKey 设置签名 page token 的密钥。
+// 默认每个实例随机生成，多副本部署时需要共享同一个密钥，否则翻页请求落到其他副本会返回 InvalidArgument。
+func WithPageTokenKey(key []byte) UserServiceOption {
+	return func(s *UserService) { s.pageTokenKey = key }
 }
 
 // NewUserService 创建 UserService。
-func NewUserService() *UserService {
-	return &UserService{users: make(map[string]*pb.User)}
+func NewUserService(opts ...UserServiceOption) *UserService {
+	s := &UserService{users: make(map[string]*pb.User), watchers: make(map[*watcher]struct{})}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if len(s.pageTokenKey) == 0 {
+		s.pageTokenKey = make([]byte, 32)
+		rand.Read(s.pageTokenKey)
+	}
+	return s
 }
 
 // CreateUser 创建新用户。
 func (s *UserService) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.User, error) {
+	return s.create(req, nil)
+}
+
+// create 创建用户并发布事件，origin 非空时该订阅者不会收到这个事件。
+func (s *UserService) create(req *pb.CreateUserRequest, origin *watcher) (*pb.User, error) {
+	violations := make(map[string]string)
 	if req.Name == "" {
-		return nil, status.Error(codes.InvalidArgument, "name is required")
+		violations["name"] = "is required"
 	}
 	if req.Email == "" {
-		return nil, status.Error(codes.InvalidArgument, "email is required")
+		violations["email"] = "is required"
+	}
+	if len(violations) > 0 {
+		return nil, apierror.New(apierror.ValidationFailed, "request validation failed", apierror.BadRequest(violations))
 	}
 
 	s.mu.Lock()
@@ -42,122 +77,97 @@ func (s *UserService) CreateUser(ctx context.Context, req *pb.CreateUserRequest)
 	// 检查 email 唯一性
 	for _, u := range s.users {
 		if u.Email == req.Email {
-			return nil, status.Errorf(codes.AlreadyExists, "user with email %q already exists", req.Email)
+			return nil, apierror.New(apierror.Conflict, fmt.Sprintf("user with email %q already exists", req.Email))
 		}
 	}
 
 	s.seq++
 	user := &pb.User{
-		ID:        fmt.Sprintf("usr_%06d", s.seq),
-		Name:      req.Name,
-		Email:     req.Email,
-		Age:       req.Age,
-		CreatedAt: time.Now().UTC(),
-	}
-	s.users[user.ID] = user
+		Id:         fmt.Sprintf("usr_%06d", s.seq),
+		Name:       req.Name,
+		Email:      req.Email,
+		Age:        req.Age,
+		CreateTime: timestamppb.Now(),
+	}
+	s.users[user.Id] = user
+	s.publishLocked(pb.EventType_EVENT_TYPE_CREATED, user, origin)
 	return user, nil
 }
 
 // GetUser 获取用户详情。
 func (s *UserService) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
-	if req.ID == "" {
-		return nil, status.Error(codes.InvalidArgument, "id is required")
+	if req.Id == "" {
+		return nil, errIDRequired
 	}
 
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	user, ok := s.users[req.ID]
+	user, ok := s.users[req.Id]
 	if !ok {
-		return nil, status.Errorf(codes.NotFound, "user %q not found", req.ID)
+		return nil, errUserNotFound(req.Id)
 	}
 	return user, nil
 }
 
-// ListUsers 分页列出用户。
+// ListUsers 分页列出用户，过滤、排序和 page token 的实现见 list.go。
 func (s *UserService) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	pageSize := int(req.PageSize)
-	if pageSize <= 0 {
-		pageSize = 10
-	}
-	if pageSize > 100 {
-		pageSize = 100
+	q, pageSize, err := s.parseListRequest(req)
+	if err != nil {
+		return nil, err
 	}
 
+	s.mu.RLock()
 	all := make([]*pb.User, 0, len(s.users))
 	for _, u := range s.users {
 		all = append(all, u)
 	}
+	s.mu.RUnlock()
 
-	// 简化分页: 用 offset 而非真正的 page token
-	start := 0
-	if req.PageToken != "" {
-		for i, u := range all {
-			if u.ID == req.PageToken {
-				start = i + 1
-				break
-			}
-		}
-	}
-
-	end := start + pageSize
-	if end > len(all) {
-		end = len(all)
-	}
-
-	resp := &pb.ListUsersResponse{
-		Users: all[start:end],
-	}
-	if end < len(all) {
-		resp.NextPageToken = all[end-1].ID
+	users, more := q.page(all, pageSize)
+	resp := &pb.ListUsersResponse{Users: users}
+	if more {
+		resp.NextPageToken = s.encodePageToken(users[len(users)-1], q)
 	}
 	return resp, nil
 }
 
 // DeleteUser 删除用户。
-func (s *UserService) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.Empty, error) {
-	if req.ID == "" {
-		return nil, status.Error(codes.InvalidArgument, "id is required")
+func (s *UserService) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*emptypb.Empty, error) {
+	if err := s.delete(req, nil); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// delete 删除用户并发布事件，origin 的含义与 create 相同。
+func (s *UserService) delete(req *pb.DeleteUserRequest, origin *watcher) error {
+	if req.Id == "" {
+		return errIDRequired
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, ok := s.users[req.ID]; !ok {
-		return nil, status.Errorf(codes.NotFound, "user %q not found", req.ID)
+	if _, ok := s.users[req.Id]; !ok {
+		return errUserNotFound(req.Id)
 	}
-	delete(s.users, req.ID)
-	return &pb.Empty{}, nil
+	delete(s.users, req.Id)
+	s.publishLocked(pb.EventType_EVENT_TYPE_DELETED, &pb.User{Id: req.Id}, origin)
+	return nil
 }
 
-// GRPCCodeToHTTP 将 gRPC Status Code 映射到 HTTP 状态码。
-// 展示两种协议间的映射关系。
+// errIDRequired 是 GetUser/DeleteUser 缺少 id 时返回的错误。
+var errIDRequired = apierror.New(apierror.InvalidArgument, "id is required", apierror.BadRequest(map[string]string{"id": "is required"}))
+
+// errUserNotFound 返回用户不存在的错误，ErrorInfo 的 metadata 中带上 id。
+func errUserNotFound(id string) error {
+	return apierror.New(apierror.NotFound, fmt.Sprintf("user %q not found", id),
+		apierror.ErrorInfo(apierror.NotFound, map[string]string{"id": id}))
+}
+
+// GRPCCodeToHTTP 将 gRPC Status Code 映射到 HTTP 状态码，覆盖全部状态码。
+// 映射定义在 apierror 中，与 REST 接口对同一错误返回的状态码一致。
 func GRPCCodeToHTTP(code codes.Code) int {
-	switch code {
-	case codes.OK:
-		return 200
-	case codes.InvalidArgument:
-		return 400
-	case codes.Unauthenticated:
-		return 401
-	case codes.PermissionDenied:
-		return 403
-	case codes.NotFound:
-		return 404
-	case codes.AlreadyExists:
-		return 409
-	case codes.FailedPrecondition:
-		return 412
-	case codes.ResourceExhausted:
-		return 429
-	case codes.Internal:
-		return 500
-	case codes.Unavailable:
-		return 503
-	default:
-		return 500
-	}
+	return apierror.HTTPStatusFromGRPC(code)
 }
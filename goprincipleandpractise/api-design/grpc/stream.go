@@ -0,0 +1,218 @@
+package apidesigngrpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-notes/goprincipleandpractise/api-design/apierror"
+	pb "go-notes/goprincipleandpractise/api-design/grpc/pb"
+)
+
+// ── 变更订阅 ────────────────────────────────────────
+
+// watcherBuffer 是每个订阅者的事件缓冲。写满说明订阅者跟不上，直接断开它，
+// 而不是让 CreateUser/DeleteUser 阻塞等待。
+const watcherBuffer = 64
+
+// watcher 是一个变更订阅。ch 被关闭表示订阅结束，lagged 表示因消费太慢被断开。
+type watcher struct {
+	ch     chan *pb.UserEvent
+	lagged bool // 由 UserService.mu 保护
+}
+
+// watchLocked 注册订阅者，调用方持有 s.mu 的写锁。
+func (s *UserService) watchLocked() *watcher {
+	w := &watcher{ch: make(chan *pb.UserEvent, watcherBuffer)}
+	s.watchers[w] = struct{}{}
+	return w
+}
+
+func (s *UserService) unwatch(w *watcher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeWatcherLocked(w)
+}
+
+func (s *UserService) removeWatcherLocked(w *watcher) {
+	if _, ok := s.watchers[w]; ok {
+		delete(s.watchers, w)
+		close(w.ch)
+	}
+}
+
+// publishLocked 分配事件序号并分发给除 origin 之外的订阅者，调用方持有 s.mu 的写锁。
+func (s *UserService) publishLocked(typ pb.EventType, user *pb.User, origin *watcher) {
+	s.eventSeq++
+	ev := &pb.UserEvent{Sequence: s.eventSeq, Type: typ, User: user}
+	for w := range s.watchers {
+		if w == origin {
+			continue
+		}
+		select {
+		case w.ch <- ev:
+		default:
+			w.lagged = true
+			s.removeWatcherLocked(w)
+		}
+	}
+}
+
+// watchEnded 返回订阅通道被关闭时流应返回的错误。
+func (s *UserService) watchEnded(w *watcher) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if w.lagged {
+		return status.Error(codes.ResourceExhausted, "watcher fell behind, re-watch with initial_snapshot")
+	}
+	return status.Error(codes.Unavailable, "watch ended")
+}
+
+// ── 流式 RPC ────────────────────────────────────────
+
+// WatchUsers 推送用户变更事件（server streaming）。
+// 快照和订阅在同一把锁内完成，快照之后的每个变更都会作为事件送达。
+func (s *UserService) WatchUsers(req *pb.WatchUsersRequest, stream grpc.ServerStreamingServer[pb.UserEvent]) error {
+	s.mu.Lock()
+	w := s.watchLocked()
+	var snapshot []*pb.UserEvent
+	if req.InitialSnapshot {
+		for _, u := range s.users {
+			snapshot = append(snapshot, &pb.UserEvent{Type: pb.EventType_EVENT_TYPE_CREATED, User: u})
+		}
+	}
+	s.mu.Unlock()
+	defer s.unwatch(w)
+
+	slices.SortFunc(snapshot, func(a, b *pb.UserEvent) int { return strings.Compare(a.User.Id, b.User.Id) })
+	for _, ev := range snapshot {
+		if err := stream.Send(ev); err != nil {
+			return err
+		}
+	}
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case ev, ok := <-w.ch:
+			if !ok {
+				return s.watchEnded(w)
+			}
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// maxBulkImport 是一次 BulkImportUsers 最多接收的条目数，限制响应大小。
+const maxBulkImport = 1000
+
+// BulkImportUsers 逐条创建客户端流中的用户（client streaming），结束时返回全部结果。
+// 超过 maxBulkImport 条时停止接收并立即返回: 之前的条目已经创建，客户端需要知道它们的 ID，
+// 所以不能以错误结束调用，而是在结果末尾追加一条 ResourceExhausted，index 是第一个未处理的条目。
+func (s *UserService) BulkImportUsers(stream grpc.ClientStreamingServer[pb.CreateUserRequest, pb.BulkImportUsersResponse]) error {
+	resp := &pb.BulkImportUsersResponse{}
+	for index := int32(0); ; index++ {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			return err
+		}
+		if index == maxBulkImport {
+			st := apierror.Status(apierror.TooLarge, fmt.Sprintf("at most %d users per import", maxBulkImport))
+			resp.Results = append(resp.Results, &pb.BulkImportResult{Index: index, Error: st.Proto()})
+			return stream.SendAndClose(resp)
+		}
+
+		result := &pb.BulkImportResult{Index: index}
+		if user, err := s.create(req, nil); err != nil {
+			result.Error = status.Convert(err).Proto()
+		} else {
+			result.User = user
+			resp.CreatedCount++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+}
+
+// SyncUsers 在一个双向流上处理写操作并推送其他客户端的变更（bidirectional streaming）。
+//
+// Recv 在单独的 goroutine 中阻塞读取；写操作的执行和所有 Send 都在当前 goroutine，
+// 因为 grpc.ServerStream 不允许并发 Send。
+func (s *UserService) SyncUsers(stream grpc.BidiStreamingServer[pb.SyncUsersRequest, pb.SyncUsersResponse]) error {
+	s.mu.Lock()
+	w := s.watchLocked()
+	s.mu.Unlock()
+	defer s.unwatch(w)
+
+	ctx := stream.Context()
+	reqs := make(chan *pb.SyncUsersRequest)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		var resp *pb.SyncUsersResponse
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case req := <-reqs:
+			resp = &pb.SyncUsersResponse{Response: &pb.SyncUsersResponse_Result{Result: s.applySync(req, w)}}
+		case ev, ok := <-w.ch:
+			if !ok {
+				return s.watchEnded(w)
+			}
+			resp = &pb.SyncUsersResponse{Response: &pb.SyncUsersResponse_Event{Event: ev}}
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// applySync 执行一个同步写操作。w 作为事件来源，本流不会收到自己造成的事件。
+func (s *UserService) applySync(req *pb.SyncUsersRequest, w *watcher) *pb.SyncResult {
+	result := &pb.SyncResult{RequestId: req.RequestId}
+	var err error
+	switch op := req.Operation.(type) {
+	case *pb.SyncUsersRequest_Create:
+		result.User, err = s.create(op.Create, w)
+	case *pb.SyncUsersRequest_Delete:
+		err = s.delete(op.Delete, w)
+	default:
+		err = apierror.New(apierror.InvalidArgument, "operation is required",
+			apierror.BadRequest(map[string]string{"operation": "is required"}))
+	}
+	if err != nil {
+		result.Error = status.Convert(err).Proto()
+	}
+	return result
+}
@@ -0,0 +1,261 @@
+package apidesigngrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "go-notes/goprincipleandpractise/api-design/grpc/pb"
+)
+
+// recvEvent 从 WatchUsers 流读取下一个事件，并检查类型和用户 ID。
+func recvEvent(t *testing.T, stream grpc.ServerStreamingClient[pb.UserEvent], typ pb.EventType, id string) *pb.UserEvent {
+	t.Helper()
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if ev.Type != typ || ev.User.GetId() != id {
+		t.Fatalf("event = %v, want %v %s", ev, typ, id)
+	}
+	return ev
+}
+
+func TestWatchUsers(t *testing.T) {
+	client := dialUserService(t, NewUserService())
+	ctx := authContext(t, testToken)
+
+	alice, err := client.CreateUser(ctx, &pb.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := client.WatchUsers(ctx, &pb.WatchUsersRequest{InitialSnapshot: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 快照事件没有序号，之后的变更序号递增。
+	if ev := recvEvent(t, stream, pb.EventType_EVENT_TYPE_CREATED, alice.Id); ev.Sequence != 0 || ev.User.Email != alice.Email {
+		t.Errorf("snapshot event = %v", ev)
+	}
+	bob, err := client.CreateUser(ctx, &pb.CreateUserRequest{Name: "Bob", Email: "bob@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev := recvEvent(t, stream, pb.EventType_EVENT_TYPE_CREATED, bob.Id); ev.Sequence != 2 {
+		t.Errorf("sequence = %d, want 2", ev.Sequence)
+	}
+	if _, err := client.DeleteUser(ctx, &pb.DeleteUserRequest{Id: alice.Id}); err != nil {
+		t.Fatal(err)
+	}
+	if ev := recvEvent(t, stream, pb.EventType_EVENT_TYPE_DELETED, alice.Id); ev.Sequence != 3 || ev.User.Name != "" {
+		t.Errorf("delete event = %v", ev)
+	}
+}
+
+func TestWatcherLaggingIsDisconnected(t *testing.T) {
+	svc := NewUserService()
+	svc.mu.Lock()
+	slow := svc.watchLocked()
+	svc.mu.Unlock()
+
+	// 没有人消费 slow.ch: 缓冲写满后写操作不阻塞，订阅被断开。
+	for i := range watcherBuffer + 1 {
+		if _, err := svc.create(&pb.CreateUserRequest{Name: "U", Email: fmt.Sprintf("u%d@example.com", i)}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := 0
+	for range slow.ch {
+		n++
+	}
+	if n != watcherBuffer {
+		t.Errorf("received %d buffered events, want %d", n, watcherBuffer)
+	}
+	if err := svc.watchEnded(slow); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("watchEnded = %v, want ResourceExhausted", err)
+	}
+}
+
+func TestBulkImportUsers(t *testing.T) {
+	client := dialUserService(t, NewUserService())
+	stream, err := client.BulkImportUsers(authContext(t, testToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []*pb.CreateUserRequest{
+		{Name: "Alice", Email: "alice@example.com"},
+		{Email: "nameless@example.com"},
+		{Name: "Alice2", Email: "alice@example.com"},
+		{Name: "Bob", Email: "bob@example.com"},
+	} {
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("CloseAndRecv: %v", err)
+	}
+
+	want := []codes.Code{codes.OK, codes.InvalidArgument, codes.AlreadyExists, codes.OK}
+	if len(resp.Results) != len(want) || resp.CreatedCount != 2 {
+		t.Fatalf("response = %v", resp)
+	}
+	for i, res := range resp.Results {
+		got := codes.Code(res.Error.GetCode())
+		if res.Index != int32(i) || got != want[i] || (got == codes.OK) != (res.User != nil) {
+			t.Errorf("result %d = %v, want code %v", i, res, want[i])
+		}
+	}
+}
+
+func TestBulkImportUsersOverLimit(t *testing.T) {
+	client := dialUserService(t, NewUserService())
+	stream, err := client.BulkImportUsers(authContext(t, testToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range maxBulkImport + 10 {
+		err := stream.Send(&pb.CreateUserRequest{Name: fmt.Sprintf("User%04d", i), Email: fmt.Sprintf("user%04d@example.com", i)})
+		if errors.Is(err, io.EOF) {
+			break // 服务端已提前返回，结果由 CloseAndRecv 取得
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("CloseAndRecv: %v", err)
+	}
+
+	// 已创建的条目全部带回，末尾一条说明从哪里开始被拒绝。
+	if len(resp.Results) != maxBulkImport+1 || resp.CreatedCount != maxBulkImport {
+		t.Fatalf("got %d results, %d created", len(resp.Results), resp.CreatedCount)
+	}
+	if u := resp.Results[maxBulkImport-1].User; u == nil || u.Id == "" {
+		t.Errorf("last accepted result = %v, want a created user", resp.Results[maxBulkImport-1])
+	}
+	last := resp.Results[maxBulkImport]
+	if last.Index != maxBulkImport || codes.Code(last.Error.GetCode()) != codes.ResourceExhausted || last.User != nil {
+		t.Errorf("trailing result = %v, want ResourceExhausted at index %d", last, maxBulkImport)
+	}
+}
+
+func TestSyncUsers(t *testing.T) {
+	client := dialUserService(t, NewUserService())
+	ctx := authContext(t, testToken)
+	a, err := client.SyncUsers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := client.SyncUsers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := func(stream grpc.BidiStreamingClient[pb.SyncUsersRequest, pb.SyncUsersResponse]) *pb.SyncUsersResponse {
+		t.Helper()
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		return resp
+	}
+	// 两个流都已建立订阅后再写入: 用一次往返确认 b 的 handler 已在运行。
+	_ = b.Send(&pb.SyncUsersRequest{RequestId: "b0"})
+	recv(b)
+
+	// a 创建: a 收到结果，b 收到事件。
+	_ = a.Send(&pb.SyncUsersRequest{RequestId: "a1", Operation: &pb.SyncUsersRequest_Create{
+		Create: &pb.CreateUserRequest{Name: "Alice", Email: "alice@example.com"},
+	}})
+	res := recv(a).GetResult()
+	if res.GetRequestId() != "a1" || res.User.GetEmail() != "alice@example.com" || res.Error != nil {
+		t.Fatalf("a1 result = %v", res)
+	}
+	id := res.User.Id
+	if ev := recv(b).GetEvent(); ev.GetType() != pb.EventType_EVENT_TYPE_CREATED || ev.User.GetId() != id {
+		t.Fatalf("b event = %v", ev)
+	}
+
+	// b 删除: b 收到结果（无 user、无 error），a 收到事件。
+	_ = b.Send(&pb.SyncUsersRequest{RequestId: "b1", Operation: &pb.SyncUsersRequest_Delete{
+		Delete: &pb.DeleteUserRequest{Id: id},
+	}})
+	if res := recv(b).GetResult(); res.GetRequestId() != "b1" || res.User != nil || res.Error != nil {
+		t.Fatalf("b1 result = %v", res)
+	}
+	if ev := recv(a).GetEvent(); ev.GetType() != pb.EventType_EVENT_TYPE_DELETED || ev.User.GetId() != id {
+		t.Fatalf("a event = %v", ev)
+	}
+
+	// 失败的操作只影响自己的结果，流继续可用。
+	_ = a.Send(&pb.SyncUsersRequest{RequestId: "a2", Operation: &pb.SyncUsersRequest_Delete{
+		Delete: &pb.DeleteUserRequest{Id: id},
+	}})
+	if res := recv(a).GetResult(); codes.Code(res.Error.GetCode()) != codes.NotFound {
+		t.Fatalf("a2 result = %v", res)
+	}
+
+	// 客户端关闭发送后服务端正常结束流。
+	if err := a.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Recv(); !errors.Is(err, io.EOF) {
+		t.Errorf("Recv after CloseSend = %v, want EOF", err)
+	}
+}
+
+func (panickingService) WatchUsers(*pb.WatchUsersRequest, grpc.ServerStreamingServer[pb.UserEvent]) error {
+	panic("boom")
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	watch := func(ctx context.Context, client pb.UserServiceClient) error {
+		stream, err := client.WatchUsers(ctx, &pb.WatchUsersRequest{})
+		if err != nil {
+			return err
+		}
+		_, err = stream.Recv()
+		return err
+	}
+	sync := func(ctx context.Context, client pb.UserServiceClient) error {
+		stream, err := client.SyncUsers(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = stream.Recv()
+		return err
+	}
+
+	tests := []struct {
+		name string
+		svc  pb.UserServiceServer
+		auth bool
+		call func(context.Context, pb.UserServiceClient) error
+		want codes.Code
+	}{
+		{"missing token", NewUserService(), false, watch, codes.Unauthenticated},
+		{"panic", panickingService{}, true, watch, codes.Internal},
+		{"unimplemented", panickingService{}, true, sync, codes.Unimplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := t.Context()
+			if tt.auth {
+				ctx = authContext(t, testToken)
+			}
+			err := tt.call(ctx, dialUserService(t, tt.svc))
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("code = %v (%v), want %v", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package restful
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// 认证方式，记录在 Principal.Method 中。
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+	AuthMethodToken  = "token"
+)
+
+// 用户资源的 scope。写操作（POST/PUT/PATCH/DELETE）需要 ScopeUsersWrite。
+const (
+	ScopeUsersRead  = "users:read"
+	ScopeUsersWrite = "users:write"
+)
+
+// APIKeyHeader 是携带 API Key 的请求头。
+const APIKeyHeader = "X-API-Key"
+
+// ErrNoCredentials 表示请求没有携带该 Authenticator 能识别的凭证，
+// Authenticate 中间件会继续尝试下一个 Authenticator。
+var ErrNoCredentials = errors.New("no credentials")
+
+// 认证失败对应的 AppError。
+var (
+	ErrAuthRequired      = NewAppError(ErrUnauthorized, "missing or invalid Authorization header", nil)
+	ErrInsufficientScope = NewAppError(ErrForbidden, "insufficient scope", nil)
+)
+
+// Principal 是已认证的调用方。
+type Principal struct {
+	Subject string
+	Scopes  []string
+	Method  string // AuthMethodJWT、AuthMethodAPIKey 或 AuthMethodToken
+}
+
+// HasScope 报告 p 是否拥有 scope，"*" 表示拥有全部 scope。
+func (p *Principal) HasScope(scope string) bool {
+	return p != nil && (slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, "*"))
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext 返回 Authenticate 中间件放入 context 的调用方。
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// ── Authenticator ───────────────────────────────────
+
+// Authenticator 从请求中识别调用方。
+//
+// 返回值约定:
+//   - 没有可识别的凭证: ErrNoCredentials
+//   - 凭证无效（签名错误、过期、未知 key）: 包装 ErrInvalidCredentials 的错误，响应 401
+//   - 其他错误（例如存储故障）: 响应 500
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// AuthenticatorFunc 让普通函数实现 Authenticator。
+type AuthenticatorFunc func(r *http.Request) (*Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) { return f(r) }
+
+// StaticTokens 返回按固定 Bearer token 表认证的 Authenticator，仅用于示例和测试。
+func StaticTokens(tokens map[string]Principal) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		token, ok := bearerToken(r)
+		if !ok {
+			return nil, ErrNoCredentials
+		}
+		p, ok := tokens[token]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown token", ErrInvalidCredentials)
+		}
+		return &p, nil
+	})
+}
+
+// bearerToken 取出 Authorization: Bearer 中的 token，scheme 不区分大小写（RFC 7235 §2.1）。
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// ── API Key ─────────────────────────────────────────
+
+// APIKeyStore 按 API Key 查找调用方。未知的 key 返回包装 ErrInvalidCredentials 的错误。
+type APIKeyStore interface {
+	LookupAPIKey(ctx context.Context, key string) (*Principal, error)
+}
+
+// MemoryAPIKeyStore 是内存中的 APIKeyStore。
+// 只保存 key 的 SHA-256 摘要，即使内存被转储也拿不到明文 key。
+type MemoryAPIKeyStore struct {
+	mu   sync.RWMutex
+	keys map[[sha256.Size]byte]Principal
+}
+
+// NewMemoryAPIKeyStore 创建空的 API Key 存储。
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{keys: make(map[[sha256.Size]byte]Principal)}
+}
+
+// Add 登记 key 对应的调用方，Method 固定为 AuthMethodAPIKey。
+func (s *MemoryAPIKeyStore) Add(key string, p Principal) {
+	p.Method = AuthMethodAPIKey
+	p.Scopes = slices.Clone(p.Scopes)
+	s.mu.Lock()
+	s.keys[sha256.Sum256([]byte(key))] = p
+	s.mu.Unlock()
+}
+
+// Revoke 吊销 key。
+func (s *MemoryAPIKeyStore) Revoke(key string) {
+	s.mu.Lock()
+	delete(s.keys, sha256.Sum256([]byte(key)))
+	s.mu.Unlock()
+}
+
+func (s *MemoryAPIKeyStore) LookupAPIKey(_ context.Context, key string) (*Principal, error) {
+	s.mu.RLock()
+	p, ok := s.keys[sha256.Sum256([]byte(key))]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+	}
+	return &p, nil
+}
+
+// APIKeyAuthenticator 从 X-API-Key 头读取 key 并在 store 中查找。
+func APIKeyAuthenticator(store APIKeyStore) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		key := r.Header.Get(APIKeyHeader)
+		if key == "" {
+			return nil, ErrNoCredentials
+		}
+		return store.LookupAPIKey(r.Context(), key)
+	})
+}
+
+// ── 中间件 ──────────────────────────────────────────
+
+// Authenticate 依次尝试 authenticators，第一个识别出凭证的决定结果:
+// 成功时把 Principal 放入 context；凭证无效或全部没有凭证时返回 401，
+// 并按 RFC 6750 §3 设置 WWW-Authenticate。
+func Authenticate(authenticators ...Authenticator) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, a := range authenticators {
+				p, err := a.Authenticate(r)
+				switch {
+				case errors.Is(err, ErrNoCredentials):
+					continue
+				case errors.Is(err, ErrInvalidCredentials):
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					WriteError(w, r, NewAppError(ErrUnauthorized, err.Error(), nil))
+					return
+				case err != nil:
+					writeStoreError(w, r, err)
+					return
+				}
+				ctx := context.WithValue(r.Context(), principalKey{}, p)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			WriteError(w, r, ErrAuthRequired)
+		})
+	}
+}
+
+// RequireScopes 要求调用方拥有全部 scopes，必须放在 Authenticate 之后。
+// 未认证返回 401；已认证但缺少 scope 返回 403，重新登录也无法解决。
+func RequireScopes(scopes ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				WriteError(w, r, ErrAuthRequired)
+				return
+			}
+			for _, s := range scopes {
+				if !p.HasScope(s) {
+					w.Header().Set("WWW-Authenticate",
+						fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, strings.Join(scopes, " ")))
+					WriteError(w, r, ErrInsufficientScope.WithDetail("requires scope "+s))
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Auth 验证 Authorization 头部的 Bearer token。
+// tokenValidator 用于自定义 token 验证逻辑；为 nil 时使用内置的 demo token。
+// 通过验证的调用方拥有全部 scope，需要细粒度授权时使用 Authenticate 和 RequireScopes。
+func Auth(tokenValidator func(token string) bool) Middleware {
+	if tokenValidator == nil {
+		tokenValidator = func(token string) bool {
+			return token == "demo-token" // 仅用于示例
+		}
+	}
+	return Authenticate(AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		token, ok := bearerToken(r)
+		if !ok {
+			return nil, ErrNoCredentials
+		}
+		if !tokenValidator(token) {
+			return nil, fmt.Errorf("%w: invalid token", ErrInvalidCredentials)
+		}
+		return &Principal{Subject: token, Scopes: []string{"*"}, Method: AuthMethodToken}, nil
+	}))
+}
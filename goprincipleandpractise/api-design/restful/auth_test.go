@@ -0,0 +1,278 @@
+package restful
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testHMACSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// signJWT 以 alg 签名 claims，key 为 []byte（HS256）或 *rsa.PrivateKey（RS256），"none" 不签名。
+func signJWT(t *testing.T, alg string, key any, claims any) string {
+	t.Helper()
+	enc := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	input := enc(map[string]string{"alg": alg, "typ": "JWT"}) + "." + enc(claims)
+
+	var sig []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case "RS256":
+		digest := sha256.Sum256([]byte(input))
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	valid := func() JWTClaims {
+		return JWTClaims{
+			Issuer: "https://issuer.example", Subject: "usr_1", Audience: Audience{"users-api"},
+			ExpiresAt: NewNumericDate(now.Add(time.Hour)), Scope: "users:read users:write",
+		}
+	}
+	with := func(edit func(*JWTClaims)) JWTClaims {
+		c := valid()
+		edit(&c)
+		return c
+	}
+
+	hs, err := NewJWTVerifier(JWTConfig{Issuer: "https://issuer.example", Audience: "users-api", HMACSecret: testHMACSecret, Leeway: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := NewJWTVerifier(JWTConfig{Issuer: "https://issuer.example", Audience: "users-api", RSAPublicKey: &rsaKey.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs.now = func() time.Time { return now }
+	rs.now = hs.now
+
+	tests := []struct {
+		name     string
+		verifier *JWTVerifier
+		token    string
+		wantErr  error
+	}{
+		{"HS256 valid", hs, signJWT(t, "HS256", testHMACSecret, valid()), nil},
+		{"RS256 valid", rs, signJWT(t, "RS256", rsaKey, valid()), nil},
+		{"aud as string", hs, signJWT(t, "HS256", testHMACSecret, map[string]any{
+			"iss": "https://issuer.example", "aud": "users-api", "exp": now.Add(time.Hour).Unix(),
+		}), nil},
+		{"expired within leeway", hs, signJWT(t, "HS256", testHMACSecret, with(func(c *JWTClaims) {
+			c.ExpiresAt = NewNumericDate(now.Add(-30 * time.Second))
+		})), nil},
+		{"expired", hs, signJWT(t, "HS256", testHMACSecret, with(func(c *JWTClaims) {
+			c.ExpiresAt = NewNumericDate(now.Add(-2 * time.Minute))
+		})), ErrTokenExpired},
+		{"missing exp", hs, signJWT(t, "HS256", testHMACSecret, with(func(c *JWTClaims) { c.ExpiresAt = nil })), ErrTokenExpired},
+		{"not yet valid", hs, signJWT(t, "HS256", testHMACSecret, with(func(c *JWTClaims) {
+			c.NotBefore = NewNumericDate(now.Add(10 * time.Minute))
+		})), ErrTokenNotYetValid},
+		{"wrong issuer", hs, signJWT(t, "HS256", testHMACSecret, with(func(c *JWTClaims) { c.Issuer = "https://evil.example" })), ErrTokenIssuer},
+		{"wrong audience", hs, signJWT(t, "HS256", testHMACSecret, with(func(c *JWTClaims) { c.Audience = Audience{"billing"} })), ErrTokenAudience},
+		{"wrong secret", hs, signJWT(t, "HS256", []byte("ffffffffffffffffffffffffffffffff"), valid()), ErrTokenSignature},
+		{"alg none", hs, signJWT(t, "none", nil, valid()), ErrTokenAlgorithm},
+		// 算法混淆: 用 RSA 公钥当 HMAC 密钥签名，RS256 校验器不能接受 HS256。
+		{"alg confusion", rs, signJWT(t, "HS256", rsaKey.PublicKey.N.Bytes(), valid()), ErrTokenAlgorithm},
+		// 换上另一个 token 的 payload（scope 提升为 *），签名不再匹配。
+		{"tampered payload", hs, func() string {
+			orig := strings.Split(signJWT(t, "HS256", testHMACSecret, valid()), ".")
+			forged := strings.Split(signJWT(t, "HS256", testHMACSecret, with(func(c *JWTClaims) { c.Scope = "*" })), ".")
+			return orig[0] + "." + forged[1] + "." + orig[2]
+		}(), ErrTokenSignature},
+		{"malformed", hs, "not-a-jwt", ErrTokenMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.verifier.Verify(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("%v does not wrap ErrInvalidCredentials", err)
+			}
+			if err == nil && claims.Audience[0] != "users-api" {
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+
+	if _, err := NewJWTVerifier(JWTConfig{HMACSecret: []byte("short")}); err == nil {
+		t.Error("short HMAC secret accepted")
+	}
+}
+
+func TestMemoryAPIKeyStore(t *testing.T) {
+	store := NewMemoryAPIKeyStore()
+	store.Add("sk_live_1", Principal{Subject: "svc-report", Scopes: []string{ScopeUsersRead}})
+
+	p, err := store.LookupAPIKey(t.Context(), "sk_live_1")
+	if err != nil || p.Subject != "svc-report" || p.Method != AuthMethodAPIKey {
+		t.Fatalf("lookup = %+v, %v", p, err)
+	}
+	if _, ok := store.keys[sha256.Sum256([]byte("sk_live_1"))]; !ok || len(store.keys) != 1 {
+		t.Error("API key should be indexed by its SHA-256 digest only")
+	}
+
+	store.Revoke("sk_live_1")
+	if _, err := store.LookupAPIKey(t.Context(), "sk_live_1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("revoked key: err = %v", err)
+	}
+}
+
+// newAuthServer 返回同时接受 HS256 JWT 和 API Key 的服务器。
+func newAuthServer(t *testing.T) http.Handler {
+	t.Helper()
+	jwt, err := NewJWTVerifier(JWTConfig{Issuer: "https://issuer.example", HMACSecret: testHMACSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := NewMemoryAPIKeyStore()
+	keys.Add("reader-key", Principal{Subject: "reader", Scopes: []string{ScopeUsersRead}})
+	keys.Add("writer-key", Principal{Subject: "writer", Scopes: []string{ScopeUsersRead, ScopeUsersWrite}})
+	return NewServer(WithAuthenticators(jwt, APIKeyAuthenticator(keys)))
+}
+
+func TestAuthenticate401vs403(t *testing.T) {
+	srv := newAuthServer(t)
+	token := func(scope string, exp time.Duration) string {
+		return "Bearer " + signJWT(t, "HS256", testHMACSecret, JWTClaims{
+			Issuer: "https://issuer.example", Subject: "usr_1",
+			ExpiresAt: NewNumericDate(time.Now().Add(exp)), Scope: scope,
+		})
+	}
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+		wantChall  string // WWW-Authenticate 前缀
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized, "Bearer"},
+		{"unknown scheme", "Authorization", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, "Bearer"},
+		{"expired JWT", "Authorization", token(ScopeUsersWrite, -time.Hour), http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"unknown API key", APIKeyHeader, "nope", http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"JWT without write scope", "Authorization", token(ScopeUsersRead, time.Hour), http.StatusForbidden, `Bearer error="insufficient_scope"`},
+		{"API key without write scope", APIKeyHeader, "reader-key", http.StatusForbidden, `Bearer error="insufficient_scope"`},
+		{"JWT with write scope", "Authorization", token("users:read users:write", time.Hour), http.StatusCreated, ""},
+		{"API key with write scope", APIKeyHeader, "writer-key", http.StatusCreated, ""},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"name":"Alice","email":"alice` + string(rune('a'+i)) + `@example.com","age":30}`
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(body))
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); !strings.HasPrefix(got, tt.wantChall) || (tt.wantChall == "") != (got == "") {
+				t.Errorf("WWW-Authenticate = %q, want prefix %q", got, tt.wantChall)
+			}
+			if tt.wantStatus >= 400 {
+				var resp ErrorResponse
+				_ = json.NewDecoder(rec.Body).Decode(&resp)
+				want := ErrUnauthorized
+				if tt.wantStatus == http.StatusForbidden {
+					want = ErrForbidden
+				}
+				if resp.Error.Code != want {
+					t.Errorf("code = %q, want %q", resp.Error.Code, want)
+				}
+			}
+		})
+	}
+}
+
+func TestScopedRoutesRequireScope(t *testing.T) {
+	srv := newAuthServer(t)
+	rt := srv.(*Router)
+
+	scoped := 0
+	for _, route := range rt.Routes() {
+		if len(route.Scopes) == 0 {
+			continue
+		}
+		scoped++
+		url := strings.ReplaceAll(route.Pattern, "{id}", "usr_000001")
+		req := httptest.NewRequest(route.Method, url, strings.NewReader(`{}`))
+		req.Header.Set(APIKeyHeader, "reader-key")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s with read-only key: status %d, want 403", route.Method, route.Pattern, rec.Code)
+		}
+	}
+	if scoped != 5 {
+		t.Errorf("%d routes declare scopes, want 5 (POST, PUT, PATCH, DELETE, POST :batch)", scoped)
+	}
+
+	// 读接口保持公开。
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/users", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /api/v1/users: status %d, want 200", rec.Code)
+	}
+}
+
+func TestPrincipalInContext(t *testing.T) {
+	keys := NewMemoryAPIKeyStore()
+	keys.Add("k1", Principal{Subject: "svc", Scopes: []string{"*"}})
+
+	var got *Principal
+	h := Authenticate(APIKeyAuthenticator(keys))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PrincipalFromContext(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(APIKeyHeader, "k1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got == nil || got.Subject != "svc" || !got.HasScope(ScopeUsersWrite) {
+		t.Errorf("principal = %+v", got)
+	}
+}
+
+func TestAuthenticateStoreFailureIs500(t *testing.T) {
+	failing := AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		return nil, errors.New("connection refused")
+	})
+	rec := httptest.NewRecorder()
+	Authenticate(failing)(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
@@ -0,0 +1,228 @@
+package restful
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// DefaultMaxBatchSize 是批量接口默认允许的最大操作数。
+const DefaultMaxBatchSize = 100
+
+// maxBatchOpBytes 是批量请求中每个操作允许的平均字节数，请求体上限为 maxBatch × maxBatchOpBytes。
+// 单个用户的 JSON 远小于它，只用来在解码之前拒绝超大的请求体。
+const maxBatchOpBytes = 4 << 10
+
+// WithMaxBatchSize 设置 POST /api/v1/users:batch 允许的最大操作数，超出返回 413。
+func WithMaxBatchSize(n int) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.maxBatch = n
+	}
+}
+
+// 批量操作类型。
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// BatchRequest 是 POST /api/v1/users:batch 的请求体。
+type BatchRequest struct {
+	// Atomic 为 true 时所有操作要么全部生效，要么全部不生效，需要存储实现 TxUserStore。
+	Atomic     bool      `json:"atomic,omitempty"`
+	Operations []BatchOp `json:"operations" validate:"required,min=1"`
+}
+
+// BatchOp 是批量请求中的一个操作，语义与对应的单条接口一致:
+// create 同 POST，update 同 PUT（全量替换），delete 同 DELETE。
+type BatchOp struct {
+	Op      string          `json:"op" validate:"required,oneof=create update delete"`
+	ID      string          `json:"id,omitempty"`      // update 和 delete 必填
+	Version int64           `json:"version,omitempty"` // 期望的当前版本，非 0 时等价于 If-Match
+	Body    json.RawMessage `json:"body,omitempty"`    // create 为 CreateUserRequest，update 为 UpdateUserRequest
+}
+
+// batchTarget 校验 update 和 delete 的目标 ID。
+type batchTarget struct {
+	ID string `json:"id" validate:"required"`
+}
+
+// BatchResult 是单个操作的结果，与请求中的操作按下标一一对应。
+// Status 是该操作单独调用时的 HTTP 状态码，成功时 Data 为写入后的用户（delete 除外），失败时 Error 与错误响应的 error 字段相同。
+type BatchResult struct {
+	Status int        `json:"status"`
+	Data   *User      `json:"data,omitempty"`
+	Error  *ErrorBody `json:"error,omitempty"`
+}
+
+func (res BatchResult) failed() bool { return res.Error != nil }
+
+// errBatchRollback 让 InTx 回滚，失败原因已记录在对应操作的结果中。
+var errBatchRollback = errors.New("batch operation failed")
+
+// BatchUsers POST /api/v1/users:batch
+//
+// 每个操作独立校验并返回自己的状态码和错误，整个响应为 207 Multi-Status:
+//   - 默认模式: 逐个执行，失败的操作不影响其他操作
+//   - atomic 模式: 在一个事务中执行，任一操作失败（包括校验失败）则全部回滚，
+//     失败的操作带自己的错误，其余操作为 424 batch_aborted
+//
+// 请求本身不合法（不是 JSON、没有操作、超过上限）时整体返回 4xx，不执行任何操作。
+// 请求体超过 maxBatch × maxBatchOpBytes 时在解码过程中就返回 413，不会把整个请求体读进内存。
+func (h *UserHandler) BatchUsers(w http.ResponseWriter, r *http.Request) {
+	var req BatchRequest
+	limit := int64(max(h.maxBatch, 1)) * maxBatchOpBytes
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit)).Decode(&req); err != nil {
+		if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+			WriteError(w, r, NewAppError(ErrTooLarge, "request body too large", err).
+				WithDetail("at most "+strconv.FormatInt(maxErr.Limit, 10)+" bytes per batch request"))
+			return
+		}
+		WriteError(w, r, ErrInvalidBody)
+		return
+	}
+	if errs := Validate(req); len(errs) > 0 {
+		WriteValidationError(w, r, errs)
+		return
+	}
+	if len(req.Operations) > h.maxBatch {
+		WriteError(w, r, NewAppError(ErrTooLarge, "too many operations in batch", nil).
+			WithDetail("at most "+strconv.Itoa(h.maxBatch)+" operations per batch"))
+		return
+	}
+	var txStore TxUserStore
+	if req.Atomic {
+		var ok bool
+		if txStore, ok = h.store.(TxUserStore); !ok {
+			WriteError(w, r, NewAppError(ErrNotImplemented, "atomic batches are not supported by this store", nil))
+			return
+		}
+	}
+
+	// 先校验全部操作，atomic 模式下有任何一个不合法就不必开启事务。
+	results := make([]BatchResult, len(req.Operations))
+	writes := make([]func(UserStore) BatchResult, len(req.Operations))
+	invalid := false
+	for i, op := range req.Operations {
+		writes[i], results[i] = h.prepareBatchOp(r, op)
+		invalid = invalid || results[i].failed()
+	}
+
+	switch {
+	case !req.Atomic:
+		for i, write := range writes {
+			if write != nil {
+				results[i] = write(h.store)
+			}
+		}
+	case invalid:
+		abortBatch(r, results)
+	default:
+		err := txStore.InTx(r.Context(), func(tx UserStore) error {
+			for i, write := range writes {
+				if results[i] = write(tx); results[i].failed() {
+					return errBatchRollback
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, errBatchRollback) {
+			abortBatch(r, results)
+		} else if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+	}
+	WriteSuccess(w, r, http.StatusMultiStatus, results)
+}
+
+// prepareBatchOp 校验 op 并返回执行它的函数；校验失败时返回 nil 和失败结果。
+func (h *UserHandler) prepareBatchOp(r *http.Request, op BatchOp) (func(UserStore) BatchResult, BatchResult) {
+	if errs := Validate(op); len(errs) > 0 {
+		return nil, validationResult(r, errs)
+	}
+	if op.Op != BatchCreate {
+		if errs := Validate(batchTarget{ID: op.ID}); len(errs) > 0 {
+			return nil, validationResult(r, errs)
+		}
+	}
+
+	switch op.Op {
+	case BatchCreate:
+		var body CreateUserRequest
+		if res, ok := decodeBatchBody(r, op.Body, &body); !ok {
+			return nil, res
+		}
+		return func(store UserStore) BatchResult {
+			user, err := store.Create(r.Context(), User{Name: body.Name, Email: body.Email, Age: body.Age})
+			return batchResult(r, http.StatusCreated, user, err)
+		}, BatchResult{}
+	case BatchUpdate:
+		var body UpdateUserRequest
+		if res, ok := decodeBatchBody(r, op.Body, &body); !ok {
+			return nil, res
+		}
+		return func(store UserStore) BatchResult {
+			user, err := store.Update(r.Context(), op.ID, User{
+				Name: body.Name, Email: body.Email, Age: body.Age, Version: op.Version,
+			})
+			return batchResult(r, http.StatusOK, user, err)
+		}, BatchResult{}
+	default: // BatchDelete
+		return func(store UserStore) BatchResult {
+			err := store.Delete(r.Context(), op.ID, op.Version)
+			return batchResult(r, http.StatusNoContent, User{}, err)
+		}, BatchResult{}
+	}
+}
+
+// decodeBatchBody 解析并校验操作的 body。
+func decodeBatchBody(r *http.Request, raw json.RawMessage, v any) (BatchResult, bool) {
+	if len(raw) == 0 || json.Unmarshal(raw, v) != nil {
+		return errorResult(r, ErrInvalidBody, nil), false
+	}
+	if errs := Validate(v); len(errs) > 0 {
+		return validationResult(r, errs), false
+	}
+	return BatchResult{}, true
+}
+
+// batchResult 把存储层的返回值转换为操作结果，错误处理与 writeStoreError 一致。
+func batchResult(r *http.Request, status int, user User, err error) BatchResult {
+	if err != nil {
+		var appErr *AppError
+		if !errors.As(err, &appErr) {
+			slog.ErrorContext(r.Context(), "store error",
+				"request_id", RequestIDFromContext(r.Context()), "error", err)
+			appErr = ErrServerFailure
+		}
+		return errorResult(r, appErr, nil)
+	}
+	if status == http.StatusNoContent {
+		return BatchResult{Status: status}
+	}
+	return BatchResult{Status: status, Data: &user}
+}
+
+func errorResult(r *http.Request, appErr *AppError, fields ValidationErrors) BatchResult {
+	return BatchResult{Status: appErr.Code.HTTPStatusCode(), Error: newErrorBody(r, appErr, fields)}
+}
+
+func validationResult(r *http.Request, errs ValidationErrors) BatchResult {
+	return errorResult(r, NewAppError(ErrValidationFailed, "request validation failed", nil), errs)
+}
+
+// errBatchAborted 标记 atomic 批量中因其他操作失败而未生效的操作。
+var errBatchAborted = NewAppError(ErrBatchAborted, "operation rolled back because another operation in the batch failed", nil)
+
+// abortBatch 把 atomic 批量中除失败操作之外的结果都改为 424，包括已执行后被回滚的操作。
+func abortBatch(r *http.Request, results []BatchResult) {
+	for i := range results {
+		if !results[i].failed() {
+			results[i] = errorResult(r, errBatchAborted, nil)
+		}
+	}
+}
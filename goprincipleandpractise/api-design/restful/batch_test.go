@@ -0,0 +1,252 @@
+package restful
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func postBatch(t *testing.T, srv http.Handler, body string) (int, []BatchResult) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users:batch", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer demo-token")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMultiStatus {
+		return rec.Code, nil
+	}
+	var resp Response[[]BatchResult]
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v; body: %s", err, rec.Body.String())
+	}
+	return rec.Code, resp.Data
+}
+
+func resultStatuses(results []BatchResult) []int {
+	statuses := make([]int, len(results))
+	for i, res := range results {
+		statuses[i] = res.Status
+	}
+	return statuses
+}
+
+// seededStore 返回已有 usr_000001（alice@example.com）的存储。
+func seededStore(t *testing.T, newStore func(t *testing.T) UserStore) UserStore {
+	t.Helper()
+	store := newStore(t)
+	if _, err := store.Create(context.Background(), User{Name: "Alice", Email: "alice@example.com", Age: 30}); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+const mixedBatch = `{%s"operations":[
+	{"op":"create","body":{"name":"Bob","email":"bob@example.com","age":20}},
+	{"op":"create","body":{"name":"B","email":"not-an-email"}},
+	{"op":"update","id":"usr_000001","version":1,"body":{"name":"Alice2","email":"alice@example.com","age":31}},
+	{"op":"create","body":{"name":"Carol","email":"alice@example.com"}},
+	{"op":"delete","id":"usr_999999"},
+	{"op":"upsert","id":"usr_000001"}
+]}`
+
+func TestBatchPartial(t *testing.T) {
+	for name, newStore := range userStoreFactories {
+		t.Run(name, func(t *testing.T) {
+			store := seededStore(t, newStore)
+			srv := NewServer(WithUserStore(store))
+
+			code, results := postBatch(t, srv, strings.Replace(mixedBatch, "%s", "", 1))
+			if code != http.StatusMultiStatus {
+				t.Fatalf("status = %d, want 207", code)
+			}
+			want := []int{201, 422, 200, 409, 404, 422}
+			if got := resultStatuses(results); !slices.Equal(got, want) {
+				t.Fatalf("statuses = %v, want %v", got, want)
+			}
+			if results[0].Data == nil || results[0].Data.Email != "bob@example.com" {
+				t.Errorf("create result data = %+v", results[0].Data)
+			}
+			if e := results[1].Error; e == nil || e.Code != ErrValidationFailed || e.Fields["email"].Rule != "email" || e.Fields["name"].Rule != "min" {
+				t.Errorf("validation result error = %+v", e)
+			}
+			if results[2].Data == nil || results[2].Data.Version != 2 {
+				t.Errorf("update result data = %+v", results[2].Data)
+			}
+			if e := results[5].Error; e == nil || e.Fields["op"].Rule != "oneof" {
+				t.Errorf("unknown op error = %+v", e)
+			}
+
+			// 成功的操作已生效。
+			if u, err := store.Get(context.Background(), "usr_000001"); err != nil || u.Name != "Alice2" {
+				t.Errorf("usr_000001 = %+v, %v", u, err)
+			}
+		})
+	}
+}
+
+func TestBatchAtomicRollback(t *testing.T) {
+	for name, newStore := range userStoreFactories {
+		t.Run(name, func(t *testing.T) {
+			store := seededStore(t, newStore)
+			srv := NewServer(WithUserStore(store))
+
+			// 第三个操作因 email 冲突失败，之前的创建和更新都要回滚。
+			code, results := postBatch(t, srv, `{"atomic":true,"operations":[
+				{"op":"create","body":{"name":"Bob","email":"bob@example.com"}},
+				{"op":"update","id":"usr_000001","body":{"name":"Alice2","email":"alice@example.com"}},
+				{"op":"create","body":{"name":"Carol","email":"bob@example.com"}},
+				{"op":"delete","id":"usr_000001"}
+			]}`)
+			if code != http.StatusMultiStatus {
+				t.Fatalf("status = %d, want 207", code)
+			}
+			want := []int{424, 424, 409, 424}
+			if got := resultStatuses(results); !slices.Equal(got, want) {
+				t.Fatalf("statuses = %v, want %v", got, want)
+			}
+			if results[0].Error.Code != ErrBatchAborted || results[0].Data != nil {
+				t.Errorf("aborted result = %+v", results[0])
+			}
+
+			page, err := store.List(context.Background(), ListQuery{Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Total != 1 || page.Users[0].Name != "Alice" || page.Users[0].Version != 1 {
+				t.Errorf("store changed after rollback: %+v", page.Users)
+			}
+
+			// 全部成功时一起提交。
+			code, results = postBatch(t, srv, `{"atomic":true,"operations":[
+				{"op":"create","body":{"name":"Bob","email":"bob@example.com"}},
+				{"op":"delete","id":"usr_000001","version":1}
+			]}`)
+			if got := resultStatuses(results); code != http.StatusMultiStatus || !slices.Equal(got, []int{201, 204}) {
+				t.Fatalf("commit: status %d, results %v", code, got)
+			}
+			if _, err := store.Get(context.Background(), "usr_000001"); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("usr_000001 still exists: %v", err)
+			}
+		})
+	}
+}
+
+func TestBatchAtomicInvalidSkipsStore(t *testing.T) {
+	store := seededStore(t, userStoreFactories["memory"])
+	srv := NewServer(WithUserStore(store))
+
+	_, results := postBatch(t, srv, strings.Replace(mixedBatch, "%s", `"atomic":true,`, 1))
+	want := []int{424, 422, 424, 424, 424, 422}
+	if got := resultStatuses(results); !slices.Equal(got, want) {
+		t.Fatalf("statuses = %v, want %v", got, want)
+	}
+	if page, _ := store.List(context.Background(), ListQuery{Limit: 10}); page.Total != 1 {
+		t.Errorf("store has %d users, want 1", page.Total)
+	}
+}
+
+// plainStore 隐藏 InMemoryUserStore 的 InTx，模拟不支持事务的存储。
+type plainStore struct{ UserStore }
+
+func TestBatchRequestErrors(t *testing.T) {
+	ops := strings.Repeat(`{"op":"delete","id":"usr_000001"},`, 3)
+	tooMany := `{"operations":[` + strings.TrimSuffix(ops, ",") + `]}`
+	// 操作数没有超过上限，但请求体超过 2 × maxBatchOpBytes。
+	tooLarge := `{"operations":[{"op":"create","body":{"name":"` + strings.Repeat("a", 2*maxBatchOpBytes) + `"}}]}`
+
+	tests := []struct {
+		name     string
+		opts     []ServerOption
+		body     string
+		wantCode int
+		wantErr  ErrCode
+	}{
+		{"not JSON", nil, `{`, http.StatusBadRequest, ErrInvalidJSON},
+		{"no operations", nil, `{"operations":[]}`, http.StatusUnprocessableEntity, ErrValidationFailed},
+		{"too many", []ServerOption{WithUserHandlerOptions(WithMaxBatchSize(2))}, tooMany, http.StatusRequestEntityTooLarge, ErrTooLarge},
+		{"body too large", []ServerOption{WithUserHandlerOptions(WithMaxBatchSize(2))}, tooLarge, http.StatusRequestEntityTooLarge, ErrTooLarge},
+		{"atomic without transactions", []ServerOption{WithUserStore(plainStore{NewInMemoryUserStore()})},
+			`{"atomic":true,"operations":[{"op":"delete","id":"usr_000001"}]}`, http.StatusNotImplemented, ErrNotImplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(tt.opts...)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/users:batch", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer demo-token")
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d; body: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Error.Code != tt.wantErr {
+				t.Errorf("error code = %q, want %q", resp.Error.Code, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBatchLocalizedItemErrors(t *testing.T) {
+	srv := NewServer()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users:batch",
+		strings.NewReader(`{"operations":[{"op":"delete","id":"usr_000042"}]}`))
+	req.Header.Set("Authorization", "Bearer demo-token")
+	req.Header.Set("Accept-Language", "zh")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	var resp Response[[]BatchResult]
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if e := resp.Data[0].Error; e == nil || e.Message != "资源不存在" {
+		t.Errorf("item error = %+v", e)
+	}
+}
+
+func TestTxUserStoreContract(t *testing.T) {
+	for name, newStore := range userStoreFactories {
+		t.Run(name, func(t *testing.T) {
+			store, ok := newStore(t).(TxUserStore)
+			if !ok {
+				t.Skip("store does not support transactions")
+			}
+			ctx := context.Background()
+			boom := errors.New("boom")
+
+			err := store.InTx(ctx, func(tx UserStore) error {
+				if _, err := tx.Create(ctx, User{Name: "Alice", Email: "alice@example.com"}); err != nil {
+					return err
+				}
+				// 事务内可以读到自己的写入。
+				if _, err := tx.Get(ctx, "usr_000001"); err != nil {
+					return err
+				}
+				return boom
+			})
+			if !errors.Is(err, boom) {
+				t.Fatalf("InTx = %v, want %v", err, boom)
+			}
+			if _, err := store.Get(ctx, "usr_000001"); !errors.Is(err, ErrUserNotFound) {
+				t.Fatalf("rolled-back create is visible: %v", err)
+			}
+
+			if err := store.InTx(ctx, func(tx UserStore) error {
+				_, err := tx.Create(ctx, User{Name: "Bob", Email: "bob@example.com"})
+				return err
+			}); err != nil {
+				t.Fatalf("InTx: %v", err)
+			}
+			page, err := store.List(ctx, ListQuery{Limit: 10})
+			if err != nil || page.Total != 1 || page.Users[0].Email != "bob@example.com" {
+				t.Fatalf("after commit: %+v, %v", page.Users, err)
+			}
+		})
+	}
+}
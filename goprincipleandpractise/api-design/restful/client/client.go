@@ -0,0 +1,423 @@
+// Package client 是 restful 用户 API（/api/v1/users）的类型化 Go 客户端。
+//
+// 错误响应解码为 *restful.AppError；失败的请求按指数退避自动重试，并遵守 Retry-After。
+// 创建请求总是携带 Idempotency-Key，重试不会重复创建用户。
+package client
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go-notes/goprincipleandpractise/api-design/restful"
+)
+
+const usersPath = "/api/v1/users"
+
+// maxErrorBodyBytes 限制读取的错误响应体大小，防止异常响应（例如代理返回的大页面）占满内存。
+const maxErrorBodyBytes = 64 << 10
+
+// Client 是用户 API 的客户端，可以被多个 goroutine 并发使用。
+type Client struct {
+	baseURL *url.URL
+	hc      *http.Client
+	token   string
+	retry   RetryPolicy
+	sleep   func(ctx context.Context, d time.Duration) error // 便于测试注入
+}
+
+// Option 配置 Client 的可选项。
+type Option func(*Client)
+
+// WithHTTPClient 设置底层 http.Client，默认为 http.DefaultClient。
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.hc = hc
+	}
+}
+
+// WithBearerToken 为每个请求设置 Authorization: Bearer token，写接口需要。
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithRetryPolicy 设置重试策略，默认为 DefaultRetryPolicy。
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// New 创建访问 baseURL（例如 https://api.example.com）的客户端。
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("client: base URL %q must be http or https", baseURL)
+	}
+	c := &Client{baseURL: u, hc: http.DefaultClient, retry: DefaultRetryPolicy, sleep: sleepContext}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// ── 用户 API ────────────────────────────────────────
+
+// ListOptions 是 List 的查询条件，零值字段不发送，语义见 restful.ParseListQuery。
+type ListOptions struct {
+	Limit      int
+	Offset     int
+	Cursor     string // 上一页的 Page.Meta.NextCursor，与 Offset 互斥
+	Sort       string // 例如 "created_at,-name"
+	Email      string
+	NamePrefix string
+}
+
+func (o ListOptions) values() url.Values {
+	v := url.Values{}
+	if o.Limit > 0 {
+		v.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		v.Set("offset", strconv.Itoa(o.Offset))
+	}
+	for key, s := range map[string]string{"cursor": o.Cursor, "sort": o.Sort, "email": o.Email, "name_prefix": o.NamePrefix} {
+		if s != "" {
+			v.Set(key, s)
+		}
+	}
+	return v
+}
+
+// Page 是 List 返回的一页用户。Meta.NextCursor 为空表示没有下一页。
+type Page struct {
+	Users []restful.User
+	Meta  restful.Meta
+}
+
+// List 调用 GET /api/v1/users。
+func (c *Client) List(ctx context.Context, opts ListOptions) (Page, error) {
+	var resp restful.Response[[]restful.User]
+	if err := c.do(ctx, http.MethodGet, usersPath, opts.values(), nil, nil, &resp); err != nil {
+		return Page{}, err
+	}
+	page := Page{Users: resp.Data}
+	if resp.Meta != nil {
+		page.Meta = *resp.Meta
+	}
+	return page, nil
+}
+
+// Get 调用 GET /api/v1/users/{id}。
+func (c *Client) Get(ctx context.Context, id string) (restful.User, error) {
+	var resp restful.Response[restful.User]
+	err := c.do(ctx, http.MethodGet, userPath(id), nil, nil, nil, &resp)
+	return resp.Data, err
+}
+
+// Create 调用 POST /api/v1/users。
+// 每次调用生成一个 Idempotency-Key，所有重试共用它: 第一次请求已经生效但响应丢失时，
+// 重试拿到的是服务端重放的原响应，而不是第二个用户或 409。
+func (c *Client) Create(ctx context.Context, req restful.CreateUserRequest) (restful.User, error) {
+	header := http.Header{"Idempotency-Key": {crand.Text()}}
+	var resp restful.Response[restful.User]
+	err := c.do(ctx, http.MethodPost, usersPath, nil, header, req, &resp)
+	return resp.Data, err
+}
+
+// Update 调用 PUT /api/v1/users/{id}（全量替换）。
+// version 非 0 时作为 If-Match 条件更新，用户已被他人修改时返回 precondition_failed。
+//
+// 条件更新的重试不是幂等的: 第一次请求已生效但响应丢失时，重试因版本已变而得到 412。
+// 此时若当前用户恰好是 version+1 且内容与 req 一致，视为第一次请求已成功，返回当前用户。
+func (c *Client) Update(ctx context.Context, id string, req restful.UpdateUserRequest, version int64) (restful.User, error) {
+	var resp restful.Response[restful.User]
+	maybeApplied, err := c.roundTrip(ctx, http.MethodPut, userPath(id), nil, ifMatch(version), req, &resp)
+	if version != 0 && maybeApplied && hasCode(err, restful.ErrPrecondition) {
+		if current, getErr := c.Get(ctx, id); getErr == nil && current.Version == version+1 &&
+			current.Name == req.Name && current.Email == req.Email && current.Age == req.Age {
+			return current, nil
+		}
+	}
+	return resp.Data, err
+}
+
+// Delete 调用 DELETE /api/v1/users/{id}，version 的含义与 Update 相同。
+// 第一次请求可能已生效时，重试得到的 404 视为删除成功。
+func (c *Client) Delete(ctx context.Context, id string, version int64) error {
+	maybeApplied, err := c.roundTrip(ctx, http.MethodDelete, userPath(id), nil, ifMatch(version), nil, nil)
+	if maybeApplied && hasCode(err, restful.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func hasCode(err error, code restful.ErrCode) bool {
+	var appErr *restful.AppError
+	return errors.As(err, &appErr) && appErr.Code == code
+}
+
+func userPath(id string) string {
+	return usersPath + "/" + url.PathEscape(id)
+}
+
+// ifMatch 返回 version 对应的 If-Match 头部。服务端的 ETag 由版本号派生，格式为 "v<version>"。
+func ifMatch(version int64) http.Header {
+	if version == 0 {
+		return nil
+	}
+	return http.Header{"If-Match": {`"v` + strconv.FormatInt(version, 10) + `"`}}
+}
+
+// ── 请求与重试 ──────────────────────────────────────
+
+// do 发送请求，失败时按重试策略重试，成功时把响应体解码到 out（out 为 nil 时丢弃）。
+// 请求体在第一次发送前序列化，每次重试发送相同的字节。
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body, out any) error {
+	_, err := c.roundTrip(ctx, method, path, query, header, body, out)
+	return err
+}
+
+// roundTrip 与 do 相同，另外报告之前失败的尝试是否可能已在服务端生效（maybeApplied）:
+// 网络错误和 5xx 时请求可能已经执行、只是响应丢失；4xx（包括 429）说明请求没有执行。
+func (c *Client) roundTrip(ctx context.Context, method, path string, query url.Values, header http.Header, body, out any) (maybeApplied bool, err error) {
+	var payload []byte
+	if body != nil {
+		if payload, err = json.Marshal(body); err != nil {
+			return false, fmt.Errorf("client: encode request: %w", err)
+		}
+	}
+	u := c.baseURL.JoinPath(path)
+	u.RawQuery = query.Encode()
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, method, u.String(), header, payload)
+		if err == nil && resp.StatusCode < http.StatusBadRequest {
+			return maybeApplied, decodeSuccess(resp, out)
+		}
+		retryAfter, hasRetryAfter := time.Duration(0), false
+		if err == nil {
+			retryAfter, hasRetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			err = decodeError(resp, retryAfter)
+		}
+		if ctx.Err() != nil || attempt >= c.retry.MaxAttempts || !retryable(resp, hasRetryAfter) {
+			return maybeApplied, err
+		}
+		maybeApplied = maybeApplied || resp == nil || resp.StatusCode >= http.StatusInternalServerError
+
+		delay := c.retry.backoff(attempt)
+		if hasRetryAfter {
+			// 服务端要求的等待超过上限时不再重试，把错误（带 RetryAfter）交给调用方决定。
+			if retryAfter > c.retry.MaxDelay {
+				return maybeApplied, err
+			}
+			delay = max(delay, retryAfter)
+		}
+		if err := c.sleep(ctx, delay); err != nil {
+			return maybeApplied, err
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, url string, header http.Header, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("client: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("client: %s %s: %w", method, url, err)
+	}
+	return resp, nil
+}
+
+// retryable 判断失败的请求是否值得重试: 网络错误（resp 为 nil）、429、500/502/503/504，
+// 以及任何带 Retry-After 的响应（例如同一 Idempotency-Key 的请求仍在处理时的 409）。
+// 所有请求都可以安全重试: GET 和无条件的 PUT 本身幂等，POST 带 Idempotency-Key；
+// 带 If-Match 的 PUT/DELETE 和 DELETE 的重试可能因第一次已生效而失败，由 Update 和 Delete 识别。
+func retryable(resp *http.Response, hasRetryAfter bool) bool {
+	if resp == nil || hasRetryAfter {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter 解析 Retry-After（RFC 9110 §10.2.3），支持秒数和 HTTP-date 两种形式。
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// RetryPolicy 是重试策略。
+type RetryPolicy struct {
+	MaxAttempts int           // 总尝试次数（含第一次），<= 1 表示不重试
+	BaseDelay   time.Duration // 第一次重试前的退避时间，之后每次翻倍
+	MaxDelay    time.Duration // 单次等待的上限；服务端 Retry-After 超过它时放弃重试
+}
+
+// DefaultRetryPolicy 最多尝试 4 次，退避 100ms、200ms、400ms（带抖动）。
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 4, BaseDelay: 100 * time.Millisecond, MaxDelay: 10 * time.Second}
+
+// backoff 返回第 attempt 次失败后的等待时间: BaseDelay·2^(attempt-1)，不超过 MaxDelay，
+// 并在 [d/2, d] 内随机抖动，避免大量客户端在同一时刻重试。
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MaxDelay
+	if shift := attempt - 1; shift < 32 && p.BaseDelay<<shift < p.MaxDelay {
+		d = p.BaseDelay << shift
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// ── 响应解码 ────────────────────────────────────────
+
+func decodeSuccess(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decode response: %w", err)
+	}
+	return nil
+}
+
+// ResponseError 是错误响应的 HTTP 层信息，作为 *restful.AppError 的底层错误，用 errors.As 取出。
+type ResponseError struct {
+	StatusCode int
+	RequestID  string                   // X-Request-ID，排查问题时提供给服务端
+	Fields     restful.ValidationErrors // validation_failed 的字段级错误
+	RetryAfter time.Duration            // 响应的 Retry-After，没有时为 0
+}
+
+func (e *ResponseError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("HTTP %d, request %s", e.StatusCode, e.RequestID)
+	}
+	return fmt.Sprintf("HTTP %d", e.StatusCode)
+}
+
+// decodeError 把错误响应解码为 *restful.AppError。ErrorResponse 信封和 Problem Details 都能识别；
+// 响应体不是二者之一（例如网关返回的 HTML）时，错误码由状态码推断。
+func decodeError(resp *http.Response, retryAfter time.Duration) error {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	respErr := &ResponseError{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get(restful.RequestIDHeader),
+		RetryAfter: retryAfter,
+	}
+	code, message, detail := codeForStatus(resp.StatusCode), http.StatusText(resp.StatusCode), ""
+
+	mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mt {
+	case restful.MediaTypeProblemJSON:
+		var p restful.Problem
+		if json.Unmarshal(body, &p) == nil && p.Code != "" {
+			code, message, detail, respErr.Fields = p.Code, p.Title, p.Detail, p.Fields
+		}
+	case "application/json":
+		var e restful.ErrorResponse
+		if json.Unmarshal(body, &e) == nil && e.Error.Code != "" {
+			code, message, detail, respErr.Fields = e.Error.Code, e.Error.Message, e.Error.Detail, e.Error.Fields
+		}
+	}
+
+	appErr := restful.NewAppError(code, message, respErr)
+	if detail != "" {
+		appErr = appErr.WithDetail(detail)
+	}
+	return appErr
+}
+
+// codeForStatus 是 ErrCode.HTTPStatusCode 的逆映射，只覆盖一个状态码对应唯一错误码的情况。
+func codeForStatus(status int) restful.ErrCode {
+	switch status {
+	case http.StatusUnauthorized:
+		return restful.ErrUnauthorized
+	case http.StatusForbidden:
+		return restful.ErrForbidden
+	case http.StatusNotFound:
+		return restful.ErrNotFound
+	case http.StatusNotAcceptable:
+		return restful.ErrNotAcceptable
+	case http.StatusConflict:
+		return restful.ErrConflict
+	case http.StatusPreconditionFailed:
+		return restful.ErrPrecondition
+	case http.StatusRequestEntityTooLarge:
+		return restful.ErrTooLarge
+	case http.StatusUnsupportedMediaType:
+		return restful.ErrUnsupportedMedia
+	case http.StatusPreconditionRequired:
+		return restful.ErrPreconditionReq
+	case http.StatusTooManyRequests:
+		return restful.ErrRateLimited
+	case http.StatusNotImplemented:
+		return restful.ErrNotImplemented
+	case http.StatusServiceUnavailable:
+		return restful.ErrUnavailable
+	case http.StatusGatewayTimeout:
+		return restful.ErrDeadlineExceeded
+	default:
+		return restful.ErrInternalError
+	}
+}
@@ -0,0 +1,407 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go-notes/goprincipleandpractise/api-design/restful"
+)
+
+// testClient 启动 restful.NewServer，wrap 非 nil 时用它包装服务端以注入故障。
+// 返回的 sleeps 记录客户端每次重试前的等待时间（不真正等待）。
+func testClient(t *testing.T, wrap func(http.Handler) http.Handler, opts ...restful.ServerOption) (*Client, *[]time.Duration) {
+	t.Helper()
+	opts = append([]restful.ServerOption{restful.WithLogger(slog.New(slog.DiscardHandler))}, opts...)
+	var h http.Handler = restful.NewServer(opts...)
+	if wrap != nil {
+		h = wrap(h)
+	}
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+
+	c, err := New(ts.URL, WithBearerToken("demo-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sleeps []time.Duration
+	c.sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return c, &sleeps
+}
+
+// countRequests 统计经过的请求数。
+func countRequests(n *int) func(http.Handler) http.Handler {
+	var mu sync.Mutex
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			*n++
+			mu.Unlock()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func asErrors(t *testing.T, err error) (*restful.AppError, *ResponseError) {
+	t.Helper()
+	var appErr *restful.AppError
+	var respErr *ResponseError
+	if !errors.As(err, &appErr) || !errors.As(err, &respErr) {
+		t.Fatalf("err = %v (%T), want *restful.AppError wrapping *ResponseError", err, err)
+	}
+	return appErr, respErr
+}
+
+func TestClientCRUD(t *testing.T) {
+	c, _ := testClient(t, nil)
+	ctx := t.Context()
+
+	alice, err := c.Create(ctx, restful.CreateUserRequest{Name: "Alice", Email: "alice@example.com", Age: 30})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := c.Create(ctx, restful.CreateUserRequest{Name: "Bob", Email: "bob@example.com"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	got, err := c.Get(ctx, alice.ID)
+	if err != nil || got != alice {
+		t.Fatalf("Get = %+v, %v; want %+v", got, err, alice)
+	}
+
+	page, err := c.List(ctx, ListOptions{Limit: 1, Sort: "name"})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(page.Users) != 1 || page.Users[0].Name != "Alice" || page.Meta.Total != 2 || page.Meta.NextCursor == "" {
+		t.Fatalf("first page = %+v", page)
+	}
+	page, err = c.List(ctx, ListOptions{Limit: 1, Sort: "name", Cursor: page.Meta.NextCursor})
+	if err != nil || len(page.Users) != 1 || page.Users[0].Name != "Bob" || page.Meta.NextCursor != "" {
+		t.Fatalf("second page = %+v, %v", page, err)
+	}
+
+	updated, err := c.Update(ctx, alice.ID, restful.UpdateUserRequest{Name: "Alice2", Email: "alice@example.com"}, alice.Version)
+	if err != nil || updated.Name != "Alice2" || updated.Version != alice.Version+1 {
+		t.Fatalf("Update = %+v, %v", updated, err)
+	}
+	if err := c.Delete(ctx, alice.ID, updated.Version); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := c.Get(ctx, alice.ID); err == nil {
+		t.Fatal("Get after Delete succeeded")
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	var requests int
+	c, sleeps := testClient(t, countRequests(&requests))
+	ctx := t.Context()
+	alice, err := c.Create(ctx, restful.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	anonymous, _ := New(c.baseURL.String())
+
+	tests := []struct {
+		name       string
+		call       func() error
+		wantCode   restful.ErrCode
+		wantStatus int
+	}{
+		{"not found", func() error {
+			_, err := c.Get(ctx, "usr_999999")
+			return err
+		}, restful.ErrNotFound, http.StatusNotFound},
+		{"validation", func() error {
+			_, err := c.Create(ctx, restful.CreateUserRequest{Name: "A", Email: "not-an-email"})
+			return err
+		}, restful.ErrValidationFailed, http.StatusUnprocessableEntity},
+		{"conflict", func() error {
+			_, err := c.Create(ctx, restful.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+			return err
+		}, restful.ErrConflict, http.StatusConflict},
+		{"stale version", func() error {
+			_, err := c.Update(ctx, alice.ID, restful.UpdateUserRequest{Name: "Alice", Email: "alice@example.com"}, alice.Version+1)
+			return err
+		}, restful.ErrPrecondition, http.StatusPreconditionFailed},
+		{"unauthenticated", func() error {
+			return anonymous.Delete(ctx, alice.ID, 0)
+		}, restful.ErrUnauthorized, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = 0
+			appErr, respErr := asErrors(t, tt.call())
+			if appErr.Code != tt.wantCode || appErr.Message == "" || respErr.StatusCode != tt.wantStatus {
+				t.Errorf("error = %v (status %d), want %s / %d", appErr, respErr.StatusCode, tt.wantCode, tt.wantStatus)
+			}
+			if respErr.RequestID == "" {
+				t.Error("missing request ID")
+			}
+			if requests != 1 {
+				t.Errorf("%d requests, want 1: client errors are not retried", requests)
+			}
+		})
+	}
+	if len(*sleeps) != 0 {
+		t.Errorf("client slept %v", *sleeps)
+	}
+
+	_, err = c.Create(ctx, restful.CreateUserRequest{Name: "A", Email: "not-an-email"})
+	_, respErr := asErrors(t, err)
+	if respErr.Fields["email"].Rule != "email" || respErr.Fields["name"].Rule != "min" {
+		t.Errorf("fields = %+v", respErr.Fields)
+	}
+}
+
+// TestClientCreateRetryIsIdempotent 模拟第一次创建已经生效但响应丢失: 重试带同一个
+// Idempotency-Key，服务端重放原响应，只创建一个用户。
+func TestClientCreateRetryIsIdempotent(t *testing.T) {
+	var keys []string
+	var replayed bool
+	lostFirst := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			if len(keys) == 1 {
+				next.ServeHTTP(httptest.NewRecorder(), r)
+				http.Error(w, "upstream connection reset", http.StatusBadGateway)
+				return
+			}
+			rec := httptest.NewRecorder()
+			next.ServeHTTP(rec, r)
+			replayed = rec.Header().Get("X-Idempotent-Replayed") == "true"
+			for k, v := range rec.Header() {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(rec.Code)
+			_, _ = w.Write(rec.Body.Bytes())
+		})
+	}
+	c, sleeps := testClient(t, lostFirst)
+
+	user, err := c.Create(t.Context(), restful.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Fatalf("Idempotency-Key per attempt = %q, want the same key twice", keys)
+	}
+	if !replayed || user.Email != "alice@example.com" || len(*sleeps) != 1 {
+		t.Errorf("replayed=%v user=%+v sleeps=%v", replayed, user, *sleeps)
+	}
+	if page, _ := c.List(t.Context(), ListOptions{}); page.Meta.Total != 1 {
+		t.Errorf("%d users created, want 1", page.Meta.Total)
+	}
+
+	// 每次调用使用新的 key。
+	if _, err := c.Create(t.Context(), restful.CreateUserRequest{Name: "Bob", Email: "bob@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if keys[2] == keys[0] {
+		t.Error("Idempotency-Key reused across calls")
+	}
+}
+
+// dropFirstResponse 让 method 的第一个请求在服务端执行，但把响应换成 502，模拟响应在途中丢失。
+func dropFirstResponse(method string) func(http.Handler) http.Handler {
+	var dropped bool
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != method || dropped {
+				next.ServeHTTP(w, r)
+				return
+			}
+			dropped = true
+			next.ServeHTTP(httptest.NewRecorder(), r)
+			http.Error(w, "upstream connection reset", http.StatusBadGateway)
+		})
+	}
+}
+
+func TestClientConditionalWriteRetryAfterLostResponse(t *testing.T) {
+	ctx := t.Context()
+
+	t.Run("update", func(t *testing.T) {
+		c, sleeps := testClient(t, dropFirstResponse(http.MethodPut))
+		user, err := c.Create(ctx, restful.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 第一次 PUT 已生效，重试得到 412，但当前用户正是这次写入的结果。
+		got, err := c.Update(ctx, user.ID, restful.UpdateUserRequest{Name: "Alice2", Email: "alice@example.com"}, user.Version)
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if got.Version != user.Version+1 || got.Name != "Alice2" || len(*sleeps) != 1 {
+			t.Errorf("user = %+v, sleeps = %v", got, *sleeps)
+		}
+	})
+
+	t.Run("update conflicting with another writer", func(t *testing.T) {
+		c, _ := testClient(t, dropFirstResponse(http.MethodPut))
+		user, err := c.Create(ctx, restful.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 另一个写入者抢先把版本推进到 version+1: 第一次 PUT 失败，响应同样丢失。
+		if _, err := c.Update(ctx, user.ID, restful.UpdateUserRequest{Name: "Mallory", Email: "alice@example.com"}, 0); err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.Update(ctx, user.ID, restful.UpdateUserRequest{Name: "Alice2", Email: "alice@example.com"}, user.Version)
+		if appErr, _ := asErrors(t, err); appErr.Code != restful.ErrPrecondition {
+			t.Errorf("err = %v, want precondition_failed", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		c, sleeps := testClient(t, dropFirstResponse(http.MethodDelete))
+		user, err := c.Create(ctx, restful.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Delete(ctx, user.ID, user.Version); err != nil || len(*sleeps) != 1 {
+			t.Fatalf("Delete: %v, sleeps = %v", err, *sleeps)
+		}
+		if _, err := c.Get(ctx, user.ID); err == nil {
+			t.Error("user still exists")
+		}
+	})
+
+	t.Run("delete missing user", func(t *testing.T) {
+		c, _ := testClient(t, nil)
+		// 没有失败的尝试时，404 照常返回。
+		if err := c.Delete(ctx, "usr_missing", 0); !hasCode(err, restful.ErrNotFound) {
+			t.Errorf("err = %v, want not_found", err)
+		}
+	})
+}
+
+func TestClientBackoff(t *testing.T) {
+	var requests int
+	down := func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			http.Error(w, "<html>bad gateway</html>", http.StatusBadGateway)
+		})
+	}
+	c, sleeps := testClient(t, down)
+
+	_, err := c.Get(t.Context(), "usr_000001")
+	appErr, respErr := asErrors(t, err)
+	if appErr.Code != restful.ErrInternalError || respErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("error = %v (status %d)", appErr, respErr.StatusCode)
+	}
+	if requests != DefaultRetryPolicy.MaxAttempts {
+		t.Errorf("%d requests, want %d", requests, DefaultRetryPolicy.MaxAttempts)
+	}
+	// 100ms、200ms、400ms，抖动在 [d/2, d] 内。
+	if len(*sleeps) != 3 {
+		t.Fatalf("sleeps = %v", *sleeps)
+	}
+	for i, d := range *sleeps {
+		ceiling := DefaultRetryPolicy.BaseDelay << i
+		if d < ceiling/2 || d > ceiling {
+			t.Errorf("sleep %d = %v, want within [%v, %v]", i, d, ceiling/2, ceiling)
+		}
+	}
+}
+
+func TestClientHonorsRetryAfter(t *testing.T) {
+	busyOnce := func(next http.Handler) http.Handler {
+		var served bool
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !served {
+				served = true
+				w.Header().Set("Retry-After", "2")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	c, sleeps := testClient(t, busyOnce)
+	if _, err := c.List(t.Context(), ListOptions{}); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if !slices.Equal(*sleeps, []time.Duration{2 * time.Second}) {
+		t.Errorf("sleeps = %v, want [2s]", *sleeps)
+	}
+
+	// 限流的 Retry-After（约 60s）超过 MaxDelay: 不等待，直接返回。
+	c, sleeps = testClient(t, nil, restful.WithRateLimiter(restful.NewRateLimiter(1, time.Minute)))
+	if _, err := c.List(t.Context(), ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.List(t.Context(), ListOptions{})
+	appErr, respErr := asErrors(t, err)
+	if appErr.Code != restful.ErrRateLimited || respErr.RetryAfter < 50*time.Second || len(*sleeps) != 0 {
+		t.Errorf("error = %v, RetryAfter %v, sleeps %v", appErr, respErr.RetryAfter, *sleeps)
+	}
+}
+
+func TestClientRetryStopsOnContextDone(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(ts.Close)
+	c, err := New(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.Get(ctx, "usr_000001"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Get returned after %v; backoff ignored the context", d)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"0", 0, true},
+		{"-1", 0, false},
+		{"Wed, 01 Jan 2025 00:00:30 GMT", 30 * time.Second, true},
+		{"Tue, 31 Dec 2024 23:59:00 GMT", 0, true}, // 已过去的时间
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNewRejectsInvalidBaseURL(t *testing.T) {
+	for _, base := range []string{"", "localhost:8080", "ftp://example.com", "http://[::1"} {
+		if _, err := New(base); err == nil {
+			t.Errorf("New(%q) succeeded", base)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package restful
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 内置编解码器的媒体类型。
+const (
+	MediaTypeCBOR    = "application/cbor"    // RFC 8949
+	MediaTypeMsgPack = "application/msgpack" // https://msgpack.org
+)
+
+// Codec 把成功响应体编码为某种媒体类型。
+type Codec interface {
+	MediaType() string
+	Encode(w io.Writer, v any) error
+}
+
+// Codecs 是按 Accept 选择的编解码器集合，JSON 始终可用且是默认值。
+type Codecs struct {
+	codecs []Codec
+	offers []string
+}
+
+// NewCodecs 创建编解码器集合，codecs 之外总是包含 JSON。
+func NewCodecs(codecs ...Codec) *Codecs {
+	cs := &Codecs{offers: []string{"application/json"}}
+	for _, c := range codecs {
+		cs.codecs = append(cs.codecs, c)
+		cs.offers = append(cs.offers, c.MediaType())
+	}
+	return cs
+}
+
+// DefaultCodecs 返回 JSON、CBOR 和 MessagePack。
+func DefaultCodecs() *Codecs {
+	return NewCodecs(CBORCodec{}, MsgPackCodec{})
+}
+
+type codecsKey struct{}
+
+// Middleware 把编解码器集合放入 context，WriteSuccess 据此按 Accept 选择响应格式。
+// 错误响应不受影响，始终是 JSON 信封或 problem+json。
+//
+// 非 JSON 表示的 ETag 带 -<codec> 后缀（见 representationETag）；If-Match 比较的是资源版本，
+// 在交给处理器之前去掉该后缀，用 CBOR 表示的 ETag 同样可以做条件写入。
+func (cs *Codecs) Middleware(next http.Handler) http.Handler {
+	suffixes := make([]string, len(cs.codecs))
+	for i, c := range cs.codecs {
+		suffixes[i] = codecETagSuffix(c)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		if v := r.Header.Get("If-Match"); v != "" {
+			r.Header.Set("If-Match", stripETagEncodings(v, suffixes))
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), codecsKey{}, cs)))
+	})
+}
+
+// codecETagSuffix 返回 c 的 ETag 后缀，即媒体类型的子类型，例如 application/cbor → cbor。
+func codecETagSuffix(c Codec) string {
+	mt, _, _ := strings.Cut(c.MediaType(), ";")
+	if _, sub, ok := strings.Cut(mt, "/"); ok {
+		return strings.TrimSpace(sub)
+	}
+	return mt
+}
+
+// codecFor 返回 r 协商出的非 JSON 编解码器；应使用 JSON 时返回 nil。
+// Accept 中没有可用的类型时退回 JSON，而不是 406: 版本化的 vnd 类型和 problem+json 都是 JSON。
+func codecFor(r *http.Request) Codec {
+	if r == nil {
+		return nil
+	}
+	cs, ok := r.Context().Value(codecsKey{}).(*Codecs)
+	if !ok || len(cs.codecs) == 0 {
+		return nil
+	}
+	mt := negotiate(r.Header.Get("Accept"), cs.offers...)
+	for _, c := range cs.codecs {
+		if c.MediaType() == mt {
+			return c
+		}
+	}
+	return nil
+}
+
+// ── 通用值树 ────────────────────────────────────────
+//
+// CBOR 和 MessagePack 编码器先把 v 序列化为 JSON，再把 JSON 转换为目标格式。
+// 这样 json tag、omitempty 和 time.Time 的格式与 JSON 响应完全一致，字段顺序也保持不变，
+// 代价是多一次序列化；需要极致性能时应换成原生实现的 Codec。
+
+// member 是对象的一个成员，用切片而不是 map 保留字段顺序。
+type member struct {
+	key   string
+	value any
+}
+
+// toTree 把 v 转换为由 nil、bool、string、json.Number、[]any 和 []member 组成的值树。
+func toTree(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return readTree(dec)
+}
+
+func readTree(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := []member{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			val, err := readTree(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, member{key: key.(string), value: val})
+		}
+		_, err = dec.Token() // }
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			val, err := readTree(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		_, err = dec.Token() // ]
+		return arr, err
+	default:
+		return tok, nil
+	}
+}
+
+// numberValue 把 json.Number 解析为 int64、uint64 或 float64。
+func numberValue(n json.Number) any {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return i
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return u
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+// ── CBOR ────────────────────────────────────────────
+
+// CBORCodec 以 CBOR（RFC 8949）编码响应，数组和对象使用定长形式，浮点数一律为 64 位。
+type CBORCodec struct{}
+
+func (CBORCodec) MediaType() string { return MediaTypeCBOR }
+
+func (CBORCodec) Encode(w io.Writer, v any) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	encodeCBOR(&buf, tree)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// cborHead 写出主类型 major 和参数 n（RFC 8949 §3）。
+func cborHead(buf *bytes.Buffer, major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		buf.Write([]byte{major | 24, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		buf.WriteByte(major | 27)
+		buf.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+}
+
+func encodeCBOR(buf *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xf6)
+	case bool:
+		if v {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	case string:
+		cborHead(buf, 3, uint64(len(v)))
+		buf.WriteString(v)
+	case json.Number:
+		switch n := numberValue(v).(type) {
+		case int64:
+			if n >= 0 {
+				cborHead(buf, 0, uint64(n))
+			} else {
+				cborHead(buf, 1, uint64(-1-n))
+			}
+		case uint64:
+			cborHead(buf, 0, n)
+		case float64:
+			buf.WriteByte(0xfb)
+			buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(n)))
+		}
+	case []any:
+		cborHead(buf, 4, uint64(len(v)))
+		for _, e := range v {
+			encodeCBOR(buf, e)
+		}
+	case []member:
+		cborHead(buf, 5, uint64(len(v)))
+		for _, m := range v {
+			encodeCBOR(buf, m.key)
+			encodeCBOR(buf, m.value)
+		}
+	}
+}
+
+// ── MessagePack ─────────────────────────────────────
+
+// MsgPackCodec 以 MessagePack 编码响应，整数使用最短表示，浮点数一律为 float 64。
+type MsgPackCodec struct{}
+
+func (MsgPackCodec) MediaType() string { return MediaTypeMsgPack }
+
+func (MsgPackCodec) Encode(w io.Writer, v any) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	encodeMsgPack(&buf, tree)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// msgpackLen 写出 str/array/map 的长度前缀: fix 形式、16 位或 32 位。
+func msgpackLen(buf *bytes.Buffer, n int, fix, fixMax, b16, b32 byte) {
+	switch {
+	case n <= int(fixMax):
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(b16)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(b32)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+func msgpackInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0:
+		msgpackUint(buf, uint64(n))
+	case n >= -32:
+		buf.WriteByte(byte(n))
+	case n >= math.MinInt8:
+		buf.Write([]byte{0xd0, byte(n)})
+	case n >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		buf.WriteByte(0xd3)
+		buf.Write(binary.BigEndian.AppendUint64(nil, uint64(n)))
+	}
+}
+
+func msgpackUint(buf *bytes.Buffer, n uint64) {
+	switch {
+	case n < 128:
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint8:
+		buf.Write([]byte{0xcc, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		buf.WriteByte(0xcf)
+		buf.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+}
+
+func encodeMsgPack(buf *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case string:
+		if len(v) <= math.MaxUint8 && len(v) > 31 {
+			buf.Write([]byte{0xd9, byte(len(v))})
+		} else {
+			msgpackLen(buf, len(v), 0xa0, 31, 0xda, 0xdb)
+		}
+		buf.WriteString(v)
+	case json.Number:
+		switch n := numberValue(v).(type) {
+		case int64:
+			msgpackInt(buf, n)
+		case uint64:
+			msgpackUint(buf, n)
+		case float64:
+			buf.WriteByte(0xcb)
+			buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(n)))
+		}
+	case []any:
+		msgpackLen(buf, len(v), 0x90, 15, 0xdc, 0xdd)
+		for _, e := range v {
+			encodeMsgPack(buf, e)
+		}
+	case []member:
+		msgpackLen(buf, len(v), 0x80, 15, 0xde, 0xdf)
+		for _, m := range v {
+			encodeMsgPack(buf, m.key)
+			encodeMsgPack(buf, m.value)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package restful
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCodecEncode(t *testing.T) {
+	type payload struct {
+		A int   `json:"a"`
+		B []any `json:"b"`
+	}
+	v := Response[payload]{Data: payload{A: 1, B: []any{true, nil, -1, 1.5, "x"}}}
+
+	tests := []struct {
+		codec Codec
+		want  string
+	}{
+		// {"data": {"a": 1, "b": [true, null, -1, 1.5, "x"]}}，字段顺序与 JSON 相同。
+		{CBORCodec{}, "a1" + "6464617461" + "a2" + "6161" + "01" + "6162" + "85" + "f5" + "f6" + "20" + "fb3ff8000000000000" + "6178"},
+		{MsgPackCodec{}, "81" + "a464617461" + "82" + "a161" + "01" + "a162" + "95" + "c3" + "c0" + "ff" + "cb3ff8000000000000" + "a178"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.codec.MediaType(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.codec.Encode(&buf, v); err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(buf.Bytes()); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodecIntegerWidths(t *testing.T) {
+	tests := []struct {
+		n           any
+		cbor, msgpk string
+	}{
+		{23, "17", "17"},
+		{24, "1818", "18"},
+		{200, "18c8", "ccc8"},
+		{1000, "1903e8", "cd03e8"},
+		{100000, "1a000186a0", "ce000186a0"},
+		{-33, "3820", "d0df"},
+		{-1000, "3903e7", "d1fc18"},
+		{uint64(1) << 63, "1b8000000000000000", "cf8000000000000000"},
+	}
+	for _, tt := range tests {
+		var c, m bytes.Buffer
+		if err := (CBORCodec{}).Encode(&c, tt.n); err != nil {
+			t.Fatal(err)
+		}
+		if err := (MsgPackCodec{}).Encode(&m, tt.n); err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(c.Bytes()); got != tt.cbor {
+			t.Errorf("CBOR(%v) = %s, want %s", tt.n, got, tt.cbor)
+		}
+		if got := hex.EncodeToString(m.Bytes()); got != tt.msgpk {
+			t.Errorf("MessagePack(%v) = %s, want %s", tt.n, got, tt.msgpk)
+		}
+	}
+}
+
+func TestCodecNegotiation(t *testing.T) {
+	srv := newVersionedServer()
+
+	tests := []struct {
+		name            string
+		path            string
+		accept          string
+		wantContentType string
+		wantPrefix      string // 响应体开头的十六进制
+	}{
+		{"cbor", "/api/v1/users/usr_000001", MediaTypeCBOR, MediaTypeCBOR, "a1" + "6464617461"},
+		{"msgpack", "/api/v1/users/usr_000001", MediaTypeMsgPack, MediaTypeMsgPack, "81" + "a464617461"},
+		{"q-values prefer json", "/api/v1/users/usr_000001", "application/cbor;q=0.5, application/json", "application/json", hex.EncodeToString([]byte(`{"data":`))},
+		{"unsupported falls back to json", "/api/v1/users/usr_000001", "application/xml", "application/json", hex.EncodeToString([]byte(`{"data":`))},
+		{"list with meta", "/api/v1/users", MediaTypeCBOR, MediaTypeCBOR, "a2" + "6464617461" + "81"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.wantContentType) {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.wantContentType)
+			}
+			if got := hex.EncodeToString(rec.Body.Bytes()); !strings.HasPrefix(got, tt.wantPrefix) {
+				t.Errorf("body = %s, want prefix %s", got, tt.wantPrefix)
+			}
+		})
+	}
+
+	// 错误响应不受 codec 影响。
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/missing", nil)
+	req.Header.Set("Accept", MediaTypeCBOR)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("error response: %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestCodecETags(t *testing.T) {
+	srv := newVersionedServer()
+	get := func(accept, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/usr_000001", nil)
+		req.Header.Set("Accept", accept)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// 逐字节不同的表示有不同的强 ETag。
+	for accept, want := range map[string]string{
+		"application/json": `"v1"`,
+		MediaTypeCBOR:      `"v1-cbor"`,
+		MediaTypeMsgPack:   `"v1-msgpack"`,
+	} {
+		if got := get(accept, "").Header().Get("ETag"); got != want {
+			t.Errorf("Accept %s: ETag = %s, want %s", accept, got, want)
+		}
+	}
+
+	// JSON 表示的 ETag 不能让 CBOR 请求得到 304。
+	if rec := get(MediaTypeCBOR, `"v1"`); rec.Code != http.StatusOK {
+		t.Errorf("CBOR GET with JSON ETag: status = %d, want 200", rec.Code)
+	}
+	if rec := get(MediaTypeCBOR, `"v1-cbor"`); rec.Code != http.StatusNotModified || rec.Header().Get("ETag") != `"v1-cbor"` {
+		t.Errorf("CBOR GET with CBOR ETag: %d ETag %s, want 304 \"v1-cbor\"", rec.Code, rec.Header().Get("ETag"))
+	}
+
+	// If-Match 比较的是资源版本，CBOR 表示的 ETag 同样可以做条件写入。
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/users/usr_000001",
+		strings.NewReader(`{"name":"Alice","email":"alice@example.com","age":31}`))
+	req.Header.Set("Authorization", "Bearer demo-token")
+	req.Header.Set("Accept", MediaTypeCBOR)
+	req.Header.Set("If-Match", `"v1-cbor"`)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"v2-cbor"` {
+		t.Errorf("PUT with CBOR If-Match: %d ETag %s, want 200 \"v2-cbor\"", rec.Code, rec.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,334 @@
+package restful
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Compressor 提供一种 Content-Encoding。gzip 和 deflate 内置；brotli 等需要第三方库的算法
+// 通过实现该接口接入，例如基于 github.com/andybalholm/brotli:
+//
+//	type brotliCompressor struct{}
+//
+//	func (brotliCompressor) Encoding() string { return "br" }
+//	func (brotliCompressor) NewWriter(w io.Writer) io.WriteCloser {
+//		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+//	}
+//
+// NewWriter 返回的 writer 若实现了 Reset(io.Writer)，会被放入池中复用。
+type Compressor interface {
+	Encoding() string
+	NewWriter(w io.Writer) io.WriteCloser
+}
+
+// GzipCompressor 是 gzip 编码，Level 为 0 时使用 gzip.DefaultCompression。
+type GzipCompressor struct{ Level int }
+
+func (GzipCompressor) Encoding() string { return "gzip" }
+
+func (c GzipCompressor) NewWriter(w io.Writer) io.WriteCloser {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	zw, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		zw = gzip.NewWriter(w)
+	}
+	return zw
+}
+
+// DeflateCompressor 是 deflate 编码。HTTP 的 deflate 指 zlib 格式（RFC 9110 §8.4.1.2，
+// 即 RFC 1950 包装的 RFC 1951 数据），而不是裸 deflate 流；浏览器和常见客户端都按 zlib 解码。
+// Level 为 0 时使用 zlib.DefaultCompression。
+type DeflateCompressor struct{ Level int }
+
+func (DeflateCompressor) Encoding() string { return "deflate" }
+
+func (c DeflateCompressor) NewWriter(w io.Writer) io.WriteCloser {
+	level := c.Level
+	if level == 0 {
+		level = zlib.DefaultCompression
+	}
+	zw, err := zlib.NewWriterLevel(w, level)
+	if err != nil {
+		zw = zlib.NewWriter(w)
+	}
+	return zw
+}
+
+// CompressConfig 配置 Compress。
+type CompressConfig struct {
+	// Compressors 按服务端偏好排序，Accept-Encoding 中 q 值相同时取靠前的。
+	// 为空时为 gzip、deflate。
+	Compressors []Compressor
+	// MinSize 是压缩的最小响应体字节数（1024）。小响应压缩后可能反而更大，还白白消耗 CPU。
+	MinSize int
+	// ContentTypes 是可压缩的 Content-Type 前缀，为空时为 DefaultCompressibleTypes。
+	ContentTypes []string
+}
+
+// DefaultCompressibleTypes 是默认压缩的响应类型。text/event-stream 不在其中:
+// 流式响应被压缩后，中间代理可能缓冲到压缩块结束才转发。
+var DefaultCompressibleTypes = []string{
+	"application/json",
+	"application/problem+json",
+	vendorMediaTypePrefix,
+	MediaTypeCBOR,
+	MediaTypeMsgPack,
+	"text/plain",
+	"text/html",
+}
+
+type compressorPool struct {
+	Compressor
+	pool sync.Pool
+}
+
+type resetter interface{ Reset(io.Writer) }
+
+func (p *compressorPool) get(w io.Writer) io.WriteCloser {
+	if zw, ok := p.pool.Get().(io.WriteCloser); ok {
+		zw.(resetter).Reset(w)
+		return zw
+	}
+	return p.NewWriter(w)
+}
+
+func (p *compressorPool) put(zw io.WriteCloser) {
+	if _, ok := zw.(resetter); ok {
+		p.pool.Put(zw)
+	}
+}
+
+// Compress 返回响应压缩中间件:
+//   - 按 Accept-Encoding 的 q 值选择编码（RFC 9110 §12.5.3），identity 或 q=0 时不压缩
+//   - 响应体小于 MinSize、类型不可压缩、已有 Content-Encoding、HEAD 请求、204/304 时不压缩
+//   - 所有响应都带 Vary: Accept-Encoding，缓存不会把压缩版本发给不支持的客户端
+//
+// 压缩后的表示逐字节不同，强 ETag 追加 -<encoding> 后缀（"v3" → "v3-gzip"）；
+// 请求中的 If-Match / If-None-Match 在交给处理器之前去掉该后缀，条件请求照常工作。
+func Compress(cfg CompressConfig) Middleware {
+	if len(cfg.Compressors) == 0 {
+		cfg.Compressors = []Compressor{GzipCompressor{}, DeflateCompressor{}}
+	}
+	if cfg.MinSize == 0 {
+		cfg.MinSize = 1024
+	}
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = DefaultCompressibleTypes
+	}
+	pools := make([]*compressorPool, len(cfg.Compressors))
+	offers := make([]string, len(cfg.Compressors))
+	for i, c := range cfg.Compressors {
+		pools[i] = &compressorPool{Compressor: c}
+		offers[i] = c.Encoding()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			var suffixed bool // 客户端持有的是压缩表示的 ETag
+			for _, name := range []string{"If-Match", "If-None-Match"} {
+				if v := r.Header.Get(name); v != "" {
+					stripped := stripETagEncodings(v, offers)
+					suffixed = suffixed || stripped != v
+					r.Header.Set(name, stripped)
+				}
+			}
+
+			enc := negotiateEncoding(r.Header.Get("Accept-Encoding"), offers)
+			if enc < 0 || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{
+				ResponseWriter: w,
+				pool:           pools[enc],
+				minSize:        cfg.MinSize,
+				types:          cfg.ContentTypes,
+				status:         http.StatusOK,
+				suffixed:       suffixed,
+			}
+			next.ServeHTTP(cw, r)
+			cw.close()
+		})
+	}
+}
+
+// negotiateEncoding 返回 offers 中被接受且 q 值最高的下标，没有时返回 -1。
+// 未列出的编码取 "*" 的 q 值，没有 "*" 时视为不接受。
+func negotiateEncoding(header string, offers []string) int {
+	if header == "" {
+		return -1
+	}
+	qs := make(map[string]float64)
+	for part := range strings.SplitSeq(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		qs[name] = q
+	}
+
+	best, bestQ := -1, 0.0
+	for i, offer := range offers {
+		q, ok := qs[offer]
+		if !ok {
+			q = qs["*"]
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	return best
+}
+
+func stripETagEncodings(header string, encodings []string) string {
+	for _, enc := range encodings {
+		header = strings.ReplaceAll(header, "-"+enc+`"`, `"`)
+	}
+	return header
+}
+
+// compressWriter 先缓冲 minSize 字节再决定是否压缩，决定之前不写出状态码。
+type compressWriter struct {
+	http.ResponseWriter
+	pool    *compressorPool
+	minSize int
+	types   []string
+	// suffixed 为 true 时 304 的 ETag 同样带编码后缀，与客户端缓存的 200 保持一致。
+	suffixed bool
+
+	status      int
+	wroteHeader bool // 处理器调用过 WriteHeader
+	decided     bool
+	buf         []byte
+	zw          io.WriteCloser // 非 nil 表示正在压缩
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader || cw.decided {
+		return
+	}
+	// 1xx 信息响应直接透传，不影响最终状态码。
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.status, cw.wroteHeader = code, true
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.zw != nil {
+		return cw.zw.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide 写出状态码和已缓冲的数据。large 表示响应体达到阈值（或大小未知的流式响应）。
+func (cw *compressWriter) decide(large bool) error {
+	cw.decided = true
+	h := cw.ResponseWriter.Header()
+	enc := cw.pool.Encoding()
+	if large && cw.compressible(h) {
+		h.Set("Content-Encoding", enc)
+		h.Del("Content-Length")
+		suffixETag(h, enc)
+		cw.zw = cw.pool.get(cw.ResponseWriter)
+	} else if cw.status == http.StatusNotModified && cw.suffixed {
+		suffixETag(h, enc)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.zw != nil {
+		_, err = cw.zw.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func suffixETag(h http.Header, enc string) {
+	if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		h.Set("ETag", strings.TrimSuffix(etag, `"`)+"-"+enc+`"`)
+	}
+}
+
+func (cw *compressWriter) compressible(h http.Header) bool {
+	if cw.status < 200 || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" {
+		return false
+	}
+	ct := h.Get("Content-Type")
+	for _, prefix := range cw.types {
+		if strings.HasPrefix(ct, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// close 在处理器返回后调用: 不足阈值的响应原样写出，压缩流写入尾部并归还到池中。
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		_ = cw.decide(false)
+	}
+	if cw.zw != nil {
+		_ = cw.zw.Close()
+		cw.pool.put(cw.zw)
+		cw.zw = nil
+	}
+}
+
+// Flush 把缓冲数据立即写出。流式响应大小未知，按达到阈值处理。
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		_ = cw.decide(true)
+	}
+	if f, ok := cw.zw.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Hijack 透传底层连接，已缓冲的数据被丢弃。
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	cw.decided = true
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+// Unwrap 让 http.ResponseController 找到底层 ResponseWriter。
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
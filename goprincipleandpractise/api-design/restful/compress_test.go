@@ -0,0 +1,194 @@
+package restful
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var largeJSON = `{"data":"` + strings.Repeat("a", 4096) + `"}`
+
+// jsonHandler 以给定状态码写出 body，Content-Type 为 JSON。
+func jsonHandler(status int, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	})
+}
+
+func serveCompressed(h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	Compress(CompressConfig{})(h).ServeHTTP(rec, req)
+	return rec
+}
+
+func decompress(t *testing.T, enc string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	switch enc {
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	case "deflate":
+		zr, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	default:
+		return string(body)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestCompressNegotiation(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		body           string
+		wantEncoding   string
+	}{
+		{"gzip", "gzip", largeJSON, "gzip"},
+		{"deflate", "deflate", largeJSON, "deflate"},
+		{"server preference breaks ties", "deflate, gzip", largeJSON, "gzip"},
+		{"q-values", "gzip;q=0.5, deflate", largeJSON, "deflate"},
+		{"wildcard", "*", largeJSON, "gzip"},
+		{"gzip refused", "gzip;q=0, *", largeJSON, "deflate"},
+		{"identity only", "identity", largeJSON, ""},
+		{"no Accept-Encoding", "", largeJSON, ""},
+		{"below threshold", "gzip", `{"data":1}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveCompressed(jsonHandler(http.StatusOK, tt.body), tt.acceptEncoding)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if got := decompress(t, tt.wantEncoding, rec.Body.Bytes()); got != tt.body {
+				t.Errorf("body mismatch after decoding: %d bytes", len(got))
+			}
+			if !strings.Contains(strings.Join(rec.Header().Values("Vary"), ","), "Accept-Encoding") {
+				t.Errorf("Vary = %q", rec.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestCompressSkips(t *testing.T) {
+	preEncoded := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "br")
+		_, _ = io.WriteString(w, largeJSON)
+	})
+	image := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = io.WriteString(w, largeJSON)
+	})
+	for name, h := range map[string]http.Handler{
+		"204":          jsonHandler(http.StatusNoContent, ""),
+		"pre-encoded":  preEncoded,
+		"not eligible": image,
+	} {
+		rec := serveCompressed(h, "gzip")
+		if enc := rec.Header().Get("Content-Encoding"); enc == "gzip" {
+			t.Errorf("%s: compressed", name)
+		}
+	}
+}
+
+// fakeBrotli 代表通过 Compressor 接口接入的第三方编码，这里只是不压缩的透传。
+type fakeBrotli struct{}
+
+func (fakeBrotli) Encoding() string { return "br" }
+
+func (fakeBrotli) NewWriter(w io.Writer) io.WriteCloser { return nopWriteCloser{w} }
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestCompressCustomCompressor(t *testing.T) {
+	mw := Compress(CompressConfig{Compressors: []Compressor{fakeBrotli{}, GzipCompressor{}}})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	rec := httptest.NewRecorder()
+	mw(jsonHandler(http.StatusOK, largeJSON)).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "br" {
+		t.Fatalf("Content-Encoding = %q, want br", got)
+	}
+	if rec.Body.String() != largeJSON {
+		t.Error("body altered by pass-through compressor")
+	}
+}
+
+func TestCompressFlushStreams(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, "chunk")
+		_ = http.NewResponseController(w).Flush()
+	})
+	rec := serveCompressed(h, "gzip")
+	if !rec.Flushed {
+		t.Error("Flush did not reach the underlying writer")
+	}
+	if got := decompress(t, "gzip", rec.Body.Bytes()); got != "chunk" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestCompressETag(t *testing.T) {
+	store := NewInMemoryUserStore()
+	srv := NewServer(WithUserStore(store), WithCompression(CompressConfig{MinSize: 1}))
+	u, err := store.Create(t.Context(), User{Name: "Alice", Email: "alice@example.com", Age: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/v1/users/" + u.ID
+
+	get := func(acceptEncoding, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	gz := get("gzip", "")
+	if gz.Header().Get("Content-Encoding") != "gzip" || gz.Header().Get("ETag") != `"v1-gzip"` {
+		t.Fatalf("Content-Encoding=%q ETag=%q", gz.Header().Get("Content-Encoding"), gz.Header().Get("ETag"))
+	}
+	if plain := get("identity", ""); plain.Header().Get("ETag") != `"v1"` {
+		t.Errorf("identity ETag = %q", plain.Header().Get("ETag"))
+	}
+
+	// 带编码后缀的 ETag 仍能命中条件请求，304 的 ETag 与缓存的 200 一致。
+	rec := get("gzip", `"v1-gzip"`)
+	if rec.Code != http.StatusNotModified || rec.Header().Get("ETag") != `"v1-gzip"` {
+		t.Errorf("conditional GET = %d ETag=%q, want 304 \"v1-gzip\"", rec.Code, rec.Header().Get("ETag"))
+	}
+}
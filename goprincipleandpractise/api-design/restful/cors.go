@@ -0,0 +1,225 @@
+package restful
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig 配置跨域资源共享（Fetch 标准 §3.2 CORS protocol）。
+type CORSConfig struct {
+	AllowedOrigins   []string                 // 序列化的来源，例如 https://admin.example.com；"*" 表示任意来源
+	AllowOriginFunc  func(origin string) bool // 可选的匹配器，与 AllowedOrigins 任一命中即允许
+	AllowedMethods   []string                 // 为空时允许路由上注册的全部方法
+	AllowedHeaders   []string                 // 预检中可以声明的请求头，不区分大小写；"*" 表示任意
+	ExposedHeaders   []string                 // 允许前端脚本读取的响应头
+	AllowCredentials bool                     // 允许携带 Cookie 和 Authorization，不能与 "*" 来源同时使用
+	MaxAge           time.Duration            // 预检结果的缓存时间，0 表示不发送 Access-Control-Max-Age
+}
+
+// DefaultCORSConfig 返回本服务的默认策略: 任意来源、不带凭证，
+// 允许 API 用到的请求头，暴露 ETag、分页和限流相关的响应头。
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedOrigins: []string{"*"},
+		AllowedHeaders: []string{"Content-Type", "Authorization", APIKeyHeader, "Idempotency-Key", "If-Match", "If-None-Match", RequestIDHeader},
+		ExposedHeaders: []string{"ETag", "Link", "Location", RequestIDHeader, "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"},
+		MaxAge:         10 * time.Minute,
+	}
+}
+
+// ErrCORSRejected 是预检被拒绝时的错误。浏览器拿不到 CORS 头就不会读取响应体，
+// 响应体只是方便用 curl 排查。
+var ErrCORSRejected = NewAppError(ErrForbidden, "CORS preflight rejected", nil)
+
+// CORSPolicy 是校验过的 CORS 策略。Middleware 处理实际请求，Preflight 处理 OPTIONS 预检。
+type CORSPolicy struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	originFunc  func(string) bool
+	methods     []string // nil 表示不限制
+	anyHeader   bool
+	headers     map[string]bool // 小写
+	exposed     string
+	credentials bool
+	maxAge      string
+}
+
+// NewCORS 校验配置并创建策略。以下配置返回错误:
+//   - "*" 来源与 AllowCredentials 同时使用（Fetch 标准禁止带凭证时返回 *）
+//   - 来源不是 scheme://host[:port] 形式，例如带了路径或结尾的斜杠
+//   - MaxAge 为负数
+func NewCORS(cfg CORSConfig) (*CORSPolicy, error) {
+	p := &CORSPolicy{
+		origins:     make(map[string]bool),
+		originFunc:  cfg.AllowOriginFunc,
+		headers:     make(map[string]bool),
+		exposed:     strings.Join(cfg.ExposedHeaders, ", "),
+		credentials: cfg.AllowCredentials,
+	}
+	for _, o := range cfg.AllowedOrigins {
+		if o == "*" {
+			p.anyOrigin = true
+			continue
+		}
+		if err := checkOrigin(o); err != nil {
+			return nil, err
+		}
+		p.origins[strings.ToLower(o)] = true
+	}
+	if p.anyOrigin && p.credentials {
+		return nil, errors.New("cors: wildcard origin cannot be combined with AllowCredentials")
+	}
+	if cfg.AllowedMethods != nil {
+		p.methods = slices.Clone(cfg.AllowedMethods)
+	}
+	for _, h := range cfg.AllowedHeaders {
+		if h == "*" {
+			p.anyHeader = true
+			continue
+		}
+		p.headers[strings.ToLower(h)] = true
+	}
+	if cfg.MaxAge < 0 {
+		return nil, errors.New("cors: negative MaxAge")
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+	return p, nil
+}
+
+// checkOrigin 要求 o 是序列化的来源（RFC 6454 §6.2），浏览器发送的 Origin 就是这个形式。
+func checkOrigin(o string) error {
+	if o == "null" {
+		return nil
+	}
+	u, err := url.Parse(o)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("cors: %q is not a serialized origin (scheme://host[:port])", o)
+	}
+	return nil
+}
+
+// allowOrigin 报告 origin 是否被允许。
+func (p *CORSPolicy) allowOrigin(origin string) bool {
+	if p.anyOrigin || p.origins[strings.ToLower(origin)] {
+		return true
+	}
+	return p.originFunc != nil && p.originFunc(origin)
+}
+
+// varyOrigin 报告响应是否因 Origin 而不同: 只有无凭证的 "*" 策略对所有来源返回相同的头。
+func (p *CORSPolicy) varyOrigin() bool {
+	return !p.anyOrigin || p.credentials
+}
+
+func (p *CORSPolicy) setAllowOrigin(h http.Header, origin string) {
+	if p.anyOrigin && !p.credentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Middleware 为实际请求（非预检）添加 CORS 响应头。来源不被允许时不添加任何 CORS 头，
+// 请求照常处理，由浏览器拒绝脚本读取响应。
+func (p *CORSPolicy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		if p.varyOrigin() {
+			h.Add("Vary", "Origin")
+		}
+		if origin := r.Header.Get("Origin"); origin != "" && p.allowOrigin(origin) {
+			p.setAllowOrigin(h, origin)
+			if p.exposed != "" {
+				h.Set("Access-Control-Expose-Headers", p.exposed)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Preflight 返回某个路径的 OPTIONS 处理器，methods 是该路径上注册的方法。
+//
+// 带 Origin 和 Access-Control-Request-Method 的是 CORS 预检: 来源、方法和
+// Access-Control-Request-Headers 全部通过时返回 204 和 Access-Control-Allow-*，否则 403。
+// 其他 OPTIONS 请求只返回 Allow 头。
+func (p *CORSPolicy) Preflight(methods ...string) http.Handler {
+	allowed := methods
+	if p.methods != nil {
+		allowed = slices.DeleteFunc(slices.Clone(methods), func(m string) bool { return !slices.Contains(p.methods, m) })
+	}
+	allow := strings.Join(append(slices.Clone(methods), http.MethodOptions), ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		origin := r.Header.Get("Origin")
+		reqMethod := r.Header.Get("Access-Control-Request-Method")
+		if origin == "" || reqMethod == "" {
+			h.Set("Allow", allow)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		h.Add("Vary", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+		if !p.allowOrigin(origin) {
+			WriteError(w, r, ErrCORSRejected.WithDetail("origin "+origin+" is not allowed"))
+			return
+		}
+		if !slices.Contains(allowed, reqMethod) {
+			WriteError(w, r, ErrCORSRejected.WithDetail("method "+reqMethod+" is not allowed"))
+			return
+		}
+		reqHeaders := parseHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+		for _, name := range reqHeaders {
+			if !p.anyHeader && !p.headers[name] {
+				WriteError(w, r, ErrCORSRejected.WithDetail("header "+name+" is not allowed"))
+				return
+			}
+		}
+
+		p.setAllowOrigin(h, origin)
+		h.Set("Access-Control-Allow-Methods", strings.Join(allowed, ", "))
+		if len(reqHeaders) > 0 {
+			h.Set("Access-Control-Allow-Headers", strings.Join(reqHeaders, ", "))
+		}
+		if p.maxAge != "" {
+			h.Set("Access-Control-Max-Age", p.maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// parseHeaderList 解析逗号分隔的头部名列表，统一为小写（浏览器发送的就是小写）。
+func parseHeaderList(v string) []string {
+	var names []string
+	for name := range strings.SplitSeq(v, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+var defaultCORS = func() *CORSPolicy {
+	p, err := NewCORS(DefaultCORSConfig())
+	if err != nil {
+		panic(err)
+	}
+	return p
+}()
+
+// CORS 使用 DefaultCORSConfig 为实际请求添加跨域头部。预检由 CORSPolicy.Preflight 处理，
+// NewServer 会为每个路径注册对应的 OPTIONS 路由。
+func CORS(next http.Handler) http.Handler {
+	return defaultCORS.Middleware(next)
+}
@@ -0,0 +1,199 @@
+package restful
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewCORSRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  CORSConfig
+	}{
+		{"wildcard with credentials", CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}},
+		{"origin with path", CORSConfig{AllowedOrigins: []string{"https://admin.example.com/"}}},
+		{"origin without scheme", CORSConfig{AllowedOrigins: []string{"admin.example.com"}}},
+		{"negative max-age", CORSConfig{MaxAge: -time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCORS(tt.cfg); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// TestCORSActualRequest 对照 Fetch 标准 §3.2.5 检查实际请求（非预检）的响应头。
+func TestCORSActualRequest(t *testing.T) {
+	admin, err := NewCORS(CORSConfig{
+		AllowedOrigins:   []string{"https://admin.example.com"},
+		AllowOriginFunc:  func(o string) bool { return strings.HasSuffix(o, ".preview.example.com") },
+		ExposedHeaders:   []string{"ETag", "X-Request-ID"},
+		AllowCredentials: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := NewCORS(CORSConfig{AllowedOrigins: []string{"*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		policy      *CORSPolicy
+		origin      string
+		wantOrigin  string
+		wantCreds   string
+		wantExposed string
+		wantVary    bool
+	}{
+		{"same-origin request has no Origin", admin, "", "", "", "", true},
+		{"listed origin is echoed", admin, "https://admin.example.com", "https://admin.example.com", "true", "ETag, X-Request-ID", true},
+		{"matcher origin is echoed", admin, "https://pr-42.preview.example.com", "https://pr-42.preview.example.com", "true", "ETag, X-Request-ID", true},
+		{"unlisted origin gets nothing", admin, "https://evil.example", "", "", "", true},
+		{"scheme is part of the origin", admin, "http://admin.example.com", "", "", "", true},
+		{"wildcard without credentials", public, "https://any.example", "*", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			h := tt.policy.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if !called {
+				t.Error("actual request must reach the handler; the browser enforces CORS")
+			}
+			got := rec.Header()
+			if got.Get("Access-Control-Allow-Origin") != tt.wantOrigin ||
+				got.Get("Access-Control-Allow-Credentials") != tt.wantCreds ||
+				got.Get("Access-Control-Expose-Headers") != tt.wantExposed {
+				t.Errorf("headers = %v", got)
+			}
+			if vary := strings.Contains(got.Get("Vary"), "Origin"); vary != tt.wantVary {
+				t.Errorf("Vary: Origin = %v, want %v", vary, tt.wantVary)
+			}
+		})
+	}
+}
+
+// TestCORSPreflight 对照 Fetch 标准 §4.8 CORS-preflight fetch 检查预检响应。
+func TestCORSPreflight(t *testing.T) {
+	policy, err := NewCORS(CORSConfig{
+		AllowedOrigins:   []string{"https://admin.example.com"},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPut, http.MethodPatch},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "If-Match"},
+		AllowCredentials: true,
+		MaxAge:           5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 该路径注册了 GET/PUT/PATCH/DELETE，DELETE 被策略排除。
+	h := policy.Preflight(http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
+
+	tests := []struct {
+		name        string
+		origin      string
+		method      string
+		headers     string
+		wantStatus  int
+		wantMethods string
+		wantHeaders string
+	}{
+		{"allowed", "https://admin.example.com", http.MethodPut, "Content-Type, If-Match", http.StatusNoContent, "GET, PUT, PATCH", "content-type, if-match"},
+		{"no request headers", "https://admin.example.com", http.MethodPatch, "", http.StatusNoContent, "GET, PUT, PATCH", ""},
+		{"header names are case-insensitive", "https://admin.example.com", http.MethodPut, "AUTHORIZATION", http.StatusNoContent, "GET, PUT, PATCH", "authorization"},
+		{"origin not allowed", "https://evil.example", http.MethodPut, "", http.StatusForbidden, "", ""},
+		{"method excluded by policy", "https://admin.example.com", http.MethodDelete, "", http.StatusForbidden, "", ""},
+		{"method not routed", "https://admin.example.com", http.MethodPost, "", http.StatusForbidden, "", ""},
+		{"header not allowed", "https://admin.example.com", http.MethodPut, "content-type, x-debug", http.StatusForbidden, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/api/v1/users/usr_1", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			got := rec.Header()
+			if !strings.Contains(got.Get("Vary"), "Access-Control-Request-Method") {
+				t.Errorf("Vary = %q", got.Get("Vary"))
+			}
+			if tt.wantStatus != http.StatusNoContent {
+				// 失败的预检不能带任何 Access-Control-Allow-* 头。
+				if got.Get("Access-Control-Allow-Origin") != "" || got.Get("Access-Control-Allow-Methods") != "" {
+					t.Errorf("rejected preflight leaked CORS headers: %v", got)
+				}
+				return
+			}
+			if got.Get("Access-Control-Allow-Origin") != tt.origin || got.Get("Access-Control-Allow-Credentials") != "true" {
+				t.Errorf("origin/credentials = %q/%q", got.Get("Access-Control-Allow-Origin"), got.Get("Access-Control-Allow-Credentials"))
+			}
+			if got.Get("Access-Control-Allow-Methods") != tt.wantMethods || got.Get("Access-Control-Allow-Headers") != tt.wantHeaders {
+				t.Errorf("methods/headers = %q/%q", got.Get("Access-Control-Allow-Methods"), got.Get("Access-Control-Allow-Headers"))
+			}
+			if got.Get("Access-Control-Max-Age") != "300" {
+				t.Errorf("Max-Age = %q", got.Get("Access-Control-Max-Age"))
+			}
+		})
+	}
+
+	// 不带 Access-Control-Request-Method 的 OPTIONS 不是预检，只返回 Allow。
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/users/usr_1", nil)
+	req.Header.Set("Origin", "https://admin.example.com")
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Allow") != "GET, PUT, PATCH, DELETE, OPTIONS" ||
+		rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("plain OPTIONS: %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestServerCORSPreflightSkipsAuth(t *testing.T) {
+	policy, err := NewCORS(CORSConfig{
+		AllowedOrigins:   []string{"https://admin.example.com"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(WithCORS(policy))
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/users", nil)
+	req.Header.Set("Origin", "https://admin.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "authorization,content-type")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Methods") != "GET, POST" {
+		t.Fatalf("preflight: %d %v", rec.Code, rec.Header())
+	}
+
+	// 带凭证的实际请求: 回显来源而不是 *。
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(`{}`))
+	req.Header.Set("Origin", "https://admin.example.com")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("Access-Control-Allow-Origin") != "https://admin.example.com" {
+		t.Errorf("actual request: %d %v", rec.Code, rec.Header())
+	}
+}
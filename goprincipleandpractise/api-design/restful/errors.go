@@ -2,22 +2,40 @@ package restful
 
 import (
 	"fmt"
-	"net/http"
+	"time"
+
+	"google.golang.org/grpc/status"
+
+	"go-notes/goprincipleandpractise/api-design/apierror"
 )
 
 // ErrCode 定义标准化错误码，横跨 HTTP 和 gRPC 场景。
-type ErrCode string
+// 它是 apierror.Code 的别名: HTTP 状态码、gRPC 状态码的映射都定义在 apierror 中，两种协议共用。
+type ErrCode = apierror.Code
 
 const (
-	ErrInvalidJSON      ErrCode = "invalid_json"
-	ErrValidationFailed ErrCode = "validation_failed"
-	ErrUnauthorized     ErrCode = "unauthorized"
-	ErrForbidden        ErrCode = "forbidden"
-	ErrNotFound         ErrCode = "not_found"
-	ErrConflict         ErrCode = "conflict"
-	ErrPrecondition     ErrCode = "precondition_failed"
-	ErrRateLimited      ErrCode = "rate_limited"
-	ErrInternalError    ErrCode = "internal_error"
+	ErrInvalidJSON      = apierror.InvalidJSON
+	ErrInvalidQuery     = apierror.InvalidQuery
+	ErrInvalidArgument  = apierror.InvalidArgument
+	ErrValidationFailed = apierror.ValidationFailed
+	ErrInvalidPatch     = apierror.InvalidPatch
+	ErrUnsupportedMedia = apierror.UnsupportedMedia
+	ErrTooLarge         = apierror.TooLarge
+	ErrNotAcceptable    = apierror.NotAcceptable
+	ErrUnauthorized     = apierror.Unauthorized
+	ErrForbidden        = apierror.Forbidden
+	ErrNotFound         = apierror.NotFound
+	ErrConflict         = apierror.Conflict
+	ErrIdempotencyReuse = apierror.IdempotencyReuse
+	ErrPrecondition     = apierror.Precondition
+	ErrPreconditionReq  = apierror.PreconditionReq
+	ErrBatchAborted     = apierror.BatchAborted
+	ErrRateLimited      = apierror.RateLimited
+	ErrCanceled         = apierror.Canceled
+	ErrDeadlineExceeded = apierror.DeadlineExceeded
+	ErrInternalError    = apierror.InternalError
+	ErrNotImplemented   = apierror.NotImplemented
+	ErrUnavailable      = apierror.Unavailable
 )
 
 // AppError 是应用层统一错误类型，同时携带面向用户的消息和内部调试信息。
@@ -56,30 +74,31 @@ func (e *AppError) WithDetail(detail string) *AppError {
 	}
 }
 
-// HTTPStatusCode 将 ErrCode 映射到 HTTP 状态码。
-func (c ErrCode) HTTPStatusCode() int {
-	switch c {
-	case ErrInvalidJSON:
-		return http.StatusBadRequest
-	case ErrValidationFailed:
-		return http.StatusUnprocessableEntity
-	case ErrUnauthorized:
-		return http.StatusUnauthorized
-	case ErrForbidden:
-		return http.StatusForbidden
-	case ErrNotFound:
-		return http.StatusNotFound
-	case ErrConflict:
-		return http.StatusConflict
-	case ErrPrecondition:
-		return http.StatusPreconditionFailed
-	case ErrRateLimited:
-		return http.StatusTooManyRequests
-	case ErrInternalError:
-		return http.StatusInternalServerError
-	default:
-		return http.StatusInternalServerError
+// GRPCStatus 把 AppError 转换为带 ErrorInfo 的 gRPC 状态，detail 放在 ErrorInfo 的 metadata 中。
+// status.FromError 和 status.Code 据此识别 AppError，gRPC handler 可以直接返回 AppError。
+func (e *AppError) GRPCStatus() *status.Status {
+	var metadata map[string]string
+	if e.Detail != "" {
+		metadata = map[string]string{"detail": e.Detail}
+	}
+	return apierror.Status(e.Code, e.Message, apierror.ErrorInfo(e.Code, metadata))
+}
+
+// statusError 把 gRPC 状态转换为 AppError，BadRequest 的字段违规转换为 ValidationErrors。
+func statusError(st *status.Status) (*AppError, ValidationErrors, time.Duration) {
+	d := apierror.FromStatus(st)
+	appErr := NewAppError(d.Code, d.Message, st.Err())
+	if detail := d.Metadata["detail"]; detail != "" {
+		appErr = appErr.WithDetail(detail)
+	}
+	var fields ValidationErrors
+	for path, desc := range d.Fields {
+		if fields == nil {
+			fields = make(ValidationErrors, len(d.Fields))
+		}
+		fields[path] = FieldError{Message: desc}
 	}
+	return appErr, fields, d.RetryAfter
 }
 
 // 预定义常用错误，避免重复创建。
@@ -88,4 +107,11 @@ var (
 	ErrInvalidBody   = NewAppError(ErrInvalidJSON, "request body is not valid JSON", nil)
 	ErrAccessDenied  = NewAppError(ErrForbidden, "access denied", nil)
 	ErrServerFailure = NewAppError(ErrInternalError, "internal server error", nil)
+
+	ErrEmailTaken      = NewAppError(ErrConflict, "email already in use", nil)
+	ErrStaleVersion    = NewAppError(ErrPrecondition, "resource has been modified", nil)
+	ErrIfMatchRequired = NewAppError(ErrPreconditionReq, "If-Match header is required", nil)
+
+	ErrIdempotencyConflict = NewAppError(ErrConflict, "a request with this Idempotency-Key is in progress", nil)
+	ErrIdempotencyMismatch = NewAppError(ErrIdempotencyReuse, "Idempotency-Key was already used with a different request", nil)
 )
@@ -0,0 +1,117 @@
+package restful
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-notes/goprincipleandpractise/api-design/apierror"
+)
+
+func TestWriteErrorFromGRPCStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantStatus     int
+		wantCode       ErrCode
+		wantMessage    string
+		wantFields     []string
+		wantRetryAfter string
+	}{
+		{
+			name:        "app error",
+			err:         ErrUserNotFound,
+			wantStatus:  http.StatusNotFound,
+			wantCode:    ErrNotFound,
+			wantMessage: "user not found",
+		},
+		{
+			name:        "wrapped app error",
+			err:         fmt.Errorf("lookup: %w", ErrEmailTaken),
+			wantStatus:  http.StatusConflict,
+			wantCode:    ErrConflict,
+			wantMessage: "email already in use",
+		},
+		{
+			name: "field violations",
+			err: apierror.New(apierror.ValidationFailed, "invalid user",
+				apierror.BadRequest(map[string]string{"name": "is required", "email": "is required"})),
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    ErrValidationFailed,
+			wantMessage: "request validation failed", // 目录中登记的消息优先
+			wantFields:  []string{"email", "name"},
+		},
+		{
+			name:           "retry info",
+			err:            apierror.New(apierror.Unavailable, "try later", apierror.RetryInfo(1500*time.Millisecond)),
+			wantStatus:     http.StatusServiceUnavailable,
+			wantCode:       ErrUnavailable,
+			wantMessage:    "try later",
+			wantRetryAfter: "2",
+		},
+		{
+			// 没有 ErrorInfo 的状态只能由 gRPC 状态码推断。
+			name:        "bare status",
+			err:         status.Error(codes.DeadlineExceeded, "too slow"),
+			wantStatus:  http.StatusGatewayTimeout,
+			wantCode:    ErrDeadlineExceeded,
+			wantMessage: "too slow",
+		},
+		{
+			name:        "plain error",
+			err:         errors.New("dial tcp 10.0.0.1:5432: connection refused"),
+			wantStatus:  http.StatusInternalServerError,
+			wantCode:    ErrInternalError,
+			wantMessage: "internal server error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			WriteError(rec, httptest.NewRequest(http.MethodGet, "/", nil), tt.err)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Error.Code != tt.wantCode || resp.Error.Message != tt.wantMessage {
+				t.Errorf("error = %+v, want %s %q", resp.Error, tt.wantCode, tt.wantMessage)
+			}
+			for _, field := range tt.wantFields {
+				if resp.Error.Fields[field].Message == "" {
+					t.Errorf("fields = %v, missing %s", resp.Error.Fields, field)
+				}
+			}
+			if strings.Contains(rec.Body.String(), "10.0.0.1") {
+				t.Errorf("internal error leaked: %s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestAppErrorGRPCStatus(t *testing.T) {
+	appErr := ErrIdempotencyMismatch.WithDetail("body hash differs")
+
+	// status.Code 通过 GRPCStatus 识别被包装的 AppError。
+	if got := status.Code(fmt.Errorf("update: %w", appErr)); got != codes.InvalidArgument {
+		t.Fatalf("status.Code = %v, want InvalidArgument", got)
+	}
+	back, _, _ := statusError(appErr.GRPCStatus())
+	if back.Code != ErrIdempotencyReuse || back.Message != appErr.Message || back.Detail != appErr.Detail {
+		t.Errorf("round trip = %+v", back)
+	}
+}
@@ -0,0 +1,112 @@
+package restful
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// userETag 从资源版本号派生强 ETag。版本号在每次写入时递增，
+// 因此同一 URL 下 ETag 相同即表示表示形式逐字节相同。
+func userETag(u User) string {
+	return `"v` + strconv.FormatInt(u.Version, 10) + `"`
+}
+
+// contentETag 对序列化后的内容做哈希得到强 ETag，用于没有单一版本号的集合资源。
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// representationETag 把协商出的表示编入强 ETag: 同一资源的 JSON 与 CBOR/MessagePack 表示
+// 逐字节不同，ETag 追加 -<codec> 后缀（"v3" → "v3-cbor"），与 Compress 的 -gzip 后缀同理。
+// 否则客户端拿 JSON 表示的 ETag 做 If-None-Match，会对 CBOR 请求错误地得到 304。
+// 改变了线上格式的 API 版本同样追加 ;<version> 后缀（"v3" → "v3;v2"），先于编解码器后缀。
+func representationETag(r *http.Request, etag string) string {
+	if v, ok := versionFrom(r); ok {
+		etag = appendETag(etag, v.etagSuffix())
+	}
+	if c := codecFor(r); c != nil {
+		etag = appendETag(etag, "-"+codecETagSuffix(c))
+	}
+	return etag
+}
+
+// appendETag 在强 ETag 的结束引号之前追加 suffix。
+func appendETag(etag, suffix string) string {
+	if !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + suffix + `"`
+}
+
+// setETag 设置协商出的表示对应的 ETag。
+func setETag(w http.ResponseWriter, r *http.Request, etag string) {
+	w.Header().Set("ETag", representationETag(r, etag))
+}
+
+// parseETags 解析 If-Match / If-None-Match 的实体标签列表（RFC 9110 §8.8.3）。
+// 返回值保留 W/ 前缀，以便调用方区分强弱比较。
+func parseETags(header string) []string {
+	var tags []string
+	for part := range strings.SplitSeq(header, ",") {
+		if tag := strings.TrimSpace(part); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ifMatch 判断 If-Match 条件是否满足。If-Match 使用强比较: 弱标签永不匹配。
+func ifMatch(header, current string) bool {
+	for _, tag := range parseETags(header) {
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// ifNoneMatch 判断 If-None-Match 是否命中（即应返回 304）。使用弱比较: 忽略 W/ 前缀。
+func ifNoneMatch(header, current string) bool {
+	current = strings.TrimPrefix(current, "W/")
+	for _, tag := range parseETags(header) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == current {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNotModified 处理条件 GET: 设置 ETag，若 If-None-Match 命中则写 304 并返回 true。
+// If-None-Match 与带表示后缀的 ETag 比较，其他表示的 ETag 不会命中。
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	etag = representationETag(r, etag)
+	w.Header().Set("ETag", etag)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && ifNoneMatch(inm, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// checkIfMatch 校验写请求的 If-Match 前置条件，失败时写出错误响应并返回 false:
+//   - 缺少 If-Match 且 required 为 true → 428 Precondition Required
+//   - If-Match 与当前 ETag 不匹配       → 412 Precondition Failed
+func checkIfMatch(w http.ResponseWriter, r *http.Request, current User, required bool) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if required {
+			WriteError(w, r, ErrIfMatchRequired)
+			return false
+		}
+		return true
+	}
+	if !ifMatch(header, userETag(current)) {
+		WriteError(w, r, ErrStaleVersion.WithDetail("current ETag is "+userETag(current)))
+		return false
+	}
+	return true
+}
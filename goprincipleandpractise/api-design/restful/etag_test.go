@@ -0,0 +1,136 @@
+package restful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIfMatchAndIfNoneMatchParsing(t *testing.T) {
+	tests := []struct {
+		header      string
+		current     string
+		match, none bool
+	}{
+		{`"v1"`, `"v1"`, true, true},
+		{`"v2"`, `"v1"`, false, false},
+		{`"v0", "v1"`, `"v1"`, true, true},
+		{`*`, `"v1"`, true, true},
+		{`W/"v1"`, `"v1"`, false, true}, // If-Match 强比较，If-None-Match 弱比较
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := ifMatch(tt.header, tt.current); got != tt.match {
+				t.Errorf("ifMatch(%s, %s) = %v, want %v", tt.header, tt.current, got, tt.match)
+			}
+			if got := ifNoneMatch(tt.header, tt.current); got != tt.none {
+				t.Errorf("ifNoneMatch(%s, %s) = %v, want %v", tt.header, tt.current, got, tt.none)
+			}
+		})
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	srv := NewServer()
+
+	do := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer demo-token")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/v1/users", `{"name":"Alice","email":"alice@example.com"}`, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d", rec.Code)
+	}
+	var created Response[User]
+	_ = json.NewDecoder(rec.Body).Decode(&created)
+	path := "/api/v1/users/" + created.Data.ID
+	etag := rec.Header().Get("ETag")
+	if etag != `"v1"` {
+		t.Fatalf("create: ETag = %q, want \"v1\"", etag)
+	}
+
+	// ── GET + If-None-Match → 304 ───────────────
+	rec = do(http.MethodGet, path, "", map[string]string{"If-None-Match": etag})
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("get: expected empty 304, got %d (%d bytes)", rec.Code, rec.Body.Len())
+	}
+
+	// ── 列表 ETag 在数据不变时稳定 ────────────────
+	list := do(http.MethodGet, "/api/v1/users", "", nil)
+	listETag := list.Header().Get("ETag")
+	if listETag == "" {
+		t.Fatal("list: missing ETag")
+	}
+	if rec = do(http.MethodGet, "/api/v1/users", "", map[string]string{"If-None-Match": listETag}); rec.Code != http.StatusNotModified {
+		t.Fatalf("list: expected 304, got %d", rec.Code)
+	}
+
+	// ── 两个编辑者基于同一版本修改: 后到者 412 ─────
+	body := `{"name":"Alice A","email":"alice@example.com"}`
+	rec = do(http.MethodPut, path, body, map[string]string{"If-Match": etag})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("first put: expected 200, got %d; body: %s", rec.Code, rec.Body.String())
+	}
+	newETag := rec.Header().Get("ETag")
+	if newETag != `"v2"` {
+		t.Fatalf("first put: ETag = %q, want \"v2\"", newETag)
+	}
+
+	rec = do(http.MethodPatch, path, `{"name":"Alice B"}`, map[string]string{
+		"If-Match": etag, "Content-Type": MediaTypeMergePatch,
+	})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale patch: expected 412, got %d", rec.Code)
+	}
+	if rec = do(http.MethodDelete, path, "", map[string]string{"If-Match": etag}); rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale delete: expected 412, got %d", rec.Code)
+	}
+
+	// 旧 ETag 不再命中 If-None-Match，客户端拿到新表示。
+	if rec = do(http.MethodGet, path, "", map[string]string{"If-None-Match": etag}); rec.Code != http.StatusOK {
+		t.Fatalf("get with stale etag: expected 200, got %d", rec.Code)
+	}
+	if rec = do(http.MethodGet, "/api/v1/users", "", map[string]string{"If-None-Match": listETag}); rec.Code != http.StatusOK {
+		t.Fatalf("list with stale etag: expected 200, got %d", rec.Code)
+	}
+
+	if rec = do(http.MethodDelete, path, "", map[string]string{"If-Match": newETag}); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", rec.Code)
+	}
+}
+
+func TestIfMatchRequired(t *testing.T) {
+	srv := NewServer(WithUserHandlerOptions(WithRequireIfMatch(true)))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users",
+		strings.NewReader(`{"name":"Alice","email":"alice@example.com"}`))
+	req.Header.Set("Authorization", "Bearer demo-token")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	var created Response[User]
+	_ = json.NewDecoder(rec.Body).Decode(&created)
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/users/"+created.Data.ID, nil)
+	req.Header.Set("Authorization", "Bearer demo-token")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPreconditionRequired {
+		t.Fatalf("delete without If-Match: expected 428, got %d", rec.Code)
+	}
+	var errResp ErrorResponse
+	_ = json.NewDecoder(rec.Body).Decode(&errResp)
+	if errResp.Error.Code != ErrPreconditionReq {
+		t.Errorf("error code: got %s, want %s", errResp.Error.Code, ErrPreconditionReq)
+	}
+}
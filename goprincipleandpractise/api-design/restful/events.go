@@ -0,0 +1,418 @@
+package restful
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType 是用户变更事件的类型，也是 SSE 的 event 字段。
+type EventType string
+
+const (
+	EventCreated EventType = "created"
+	EventUpdated EventType = "updated"
+	EventDeleted EventType = "deleted"
+	// EventReset 表示客户端错过了已被淘汰出日志的事件，应重新 List 之后再继续消费。
+	EventReset EventType = "reset"
+)
+
+// UserEvent 是一次用户变更。ID 在 EventBus 内单调递增，与 EventBus.Epoch 一起组成 SSE 的 id 字段。
+// deleted 事件的 User 只有 ID 和 Version，Version 为被删除时的版本 + 1，比该用户的任何更新都新。
+type UserEvent struct {
+	ID   uint64
+	Type EventType
+	User User
+}
+
+// EventID 是 SSE 的 id 字段，格式为 <epoch>-<seq>。
+// 序号在进程重启后从 1 重新开始，epoch 用来识别 Last-Event-ID 是否由当前 EventBus 签发。
+type EventID struct {
+	Epoch string
+	Seq   uint64
+}
+
+func (id EventID) String() string { return id.Epoch + "-" + strconv.FormatUint(id.Seq, 10) }
+
+// ParseEventID 解析 SSE 的 id。只有序号的 id（不带 epoch 的旧格式）解析为 Epoch 为空，不属于任何 EventBus。
+func ParseEventID(s string) (EventID, error) {
+	epoch, seq, ok := strings.Cut(s, "-")
+	if !ok {
+		epoch, seq = "", s
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return EventID{}, fmt.Errorf("invalid event id %q: %w", s, err)
+	}
+	return EventID{Epoch: epoch, Seq: n}, nil
+}
+
+// DefaultEventLogSize 是 EventBus 默认保留的最近事件数，决定断线重连最多能补发多少事件。
+const DefaultEventLogSize = 1024
+
+// EventBus 把用户变更分发给订阅者，并保留最近的事件供断线重连补发。
+//
+// Publish 从不阻塞: 订阅者的缓冲写满时直接断开它，而不是等待它消费。
+// 被断开的客户端带 Last-Event-ID 重连，从日志中补齐错过的事件。
+type EventBus struct {
+	epoch  string
+	mu     sync.Mutex
+	seq    uint64
+	log    []UserEvent // 最近的事件，按 ID 升序，最多 size 条
+	size   int
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// NewEventBus 创建保留最近 logSize 条事件的 EventBus，logSize <= 0 时为 DefaultEventLogSize。
+func NewEventBus(logSize int) *EventBus {
+	if logSize <= 0 {
+		logSize = DefaultEventLogSize
+	}
+	return &EventBus{epoch: newEpoch(), size: logSize, subs: make(map[*Subscription]struct{})}
+}
+
+// Epoch 返回创建 EventBus 时随机生成的标识，每个进程（每个 EventBus）不同。
+func (b *EventBus) Epoch() string { return b.epoch }
+
+func newEpoch() string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Subscription 是一个订阅。C 被关闭表示订阅结束: 总线已关闭，或订阅者消费太慢被断开（Lagged）。
+type Subscription struct {
+	C      <-chan UserEvent
+	ch     chan UserEvent
+	lagged bool // 由 EventBus.mu 保护
+}
+
+// Publish 分配事件 ID、写入日志并分发给所有订阅者。总线关闭后调用无效果。
+func (b *EventBus) Publish(typ EventType, u User) UserEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return UserEvent{}
+	}
+	b.seq++
+	ev := UserEvent{ID: b.seq, Type: typ, User: u}
+	if len(b.log) == b.size {
+		b.log = append(b.log[:0], b.log[1:]...)
+	}
+	b.log = append(b.log, ev)
+
+	for sub := range b.subs {
+		select {
+		case sub.ch <- ev:
+		default:
+			sub.lagged = true
+			b.removeLocked(sub)
+		}
+	}
+	return ev
+}
+
+// Subscribe 注册订阅者，buffer 是它的事件缓冲。
+//
+// last 不为 nil 时返回日志中序号大于 last.Seq 的事件供补发，补发与之后的实时事件之间不重不漏。
+// complete 为 false 表示 last 之后的部分事件已被淘汰，或 last 不是本 EventBus 签发的（epoch 不同，
+// 例如来自重启前的进程，此时序号不可比较），客户端需要重新同步全量数据。
+func (b *EventBus) Subscribe(last *EventID, buffer int) (sub *Subscription, replay []UserEvent, complete bool) {
+	ch := make(chan UserEvent, max(buffer, 1))
+	sub = &Subscription{C: ch, ch: ch}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return sub, nil, true
+	}
+	b.subs[sub] = struct{}{}
+	if last == nil {
+		return sub, nil, true
+	}
+	if last.Epoch != b.epoch || last.Seq > b.seq {
+		return sub, nil, false
+	}
+	lastID := last.Seq
+	oldest := b.seq + 1
+	if len(b.log) > 0 {
+		oldest = b.log[0].ID
+	}
+	for _, ev := range b.log {
+		if ev.ID > lastID {
+			replay = append(replay, ev)
+		}
+	}
+	return sub, replay, lastID+1 >= oldest
+}
+
+// Unsubscribe 取消订阅并关闭 sub.C，可以重复调用。
+func (b *EventBus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.removeLocked(sub)
+}
+
+// Lagged 报告订阅是否因消费太慢被断开。
+func (b *EventBus) Lagged(sub *Subscription) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return sub.lagged
+}
+
+// Close 关闭总线并结束所有订阅，用于服务关闭时结束长连接。
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		b.removeLocked(sub)
+	}
+}
+
+func (b *EventBus) removeLocked(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// ── 事件来源 ────────────────────────────────────────
+
+// PublishingStore 包装 store，每次写入成功后向 bus 发布事件。
+//
+// store 实现 TxUserStore 时返回值同样实现它: 事务中的事件在提交后才发布，回滚则丢弃。
+// 事件按发布顺序编号，并发写入的提交顺序与事件顺序可能不同，客户端应以 User.Version 判断新旧:
+// 版本不大于已知版本的事件是过时的，直接丢弃。deleted 事件同样带版本，晚到的 updated 不会让已删除的用户复活。
+func PublishingStore(store UserStore, bus *EventBus) UserStore {
+	ps := publishingStore{UserStore: store, publish: func(typ EventType, u User) { bus.Publish(typ, u) }}
+	if tx, ok := store.(TxUserStore); ok {
+		return txPublishingStore{publishingStore: ps, tx: tx}
+	}
+	return ps
+}
+
+type publishingStore struct {
+	UserStore
+	publish func(EventType, User)
+}
+
+func (s publishingStore) Create(ctx context.Context, user User) (User, error) {
+	u, err := s.UserStore.Create(ctx, user)
+	if err == nil {
+		s.publish(EventCreated, u)
+	}
+	return u, err
+}
+
+func (s publishingStore) Update(ctx context.Context, id string, user User) (User, error) {
+	u, err := s.UserStore.Update(ctx, id, user)
+	if err == nil {
+		s.publish(EventUpdated, u)
+	}
+	return u, err
+}
+
+// Delete 需要知道被删除的是哪个版本，deleted 事件才能带上版本号。
+// 无条件删除（version 为 0）时先读出当前版本再以它做 CAS，其间有并发写入则重新读取。
+func (s publishingStore) Delete(ctx context.Context, id string, version int64) error {
+	for {
+		expected := version
+		if expected == 0 {
+			current, err := s.UserStore.Get(ctx, id)
+			if err != nil {
+				return err
+			}
+			expected = current.Version
+		}
+		err := s.UserStore.Delete(ctx, id, expected)
+		if errors.Is(err, ErrStaleVersion) && version == 0 && ctx.Err() == nil {
+			continue
+		}
+		if err == nil {
+			s.publish(EventDeleted, User{ID: id, Version: expected + 1})
+		}
+		return err
+	}
+}
+
+type txPublishingStore struct {
+	publishingStore
+	tx TxUserStore
+}
+
+func (s txPublishingStore) InTx(ctx context.Context, fn func(tx UserStore) error) error {
+	type pendingEvent struct {
+		typ  EventType
+		user User
+	}
+	var pending []pendingEvent
+	err := s.tx.InTx(ctx, func(tx UserStore) error {
+		return fn(publishingStore{UserStore: tx, publish: func(typ EventType, u User) {
+			pending = append(pending, pendingEvent{typ, u})
+		}})
+	})
+	if err != nil {
+		return err
+	}
+	for _, p := range pending {
+		s.publish(p.typ, p.user)
+	}
+	return nil
+}
+
+// ── SSE ─────────────────────────────────────────────
+
+// EventsConfig 配置变更事件流，零值字段使用括号中的默认值。
+type EventsConfig struct {
+	// Heartbeat 是心跳间隔（15s）。空闲连接定期收到注释行，不会被代理或负载均衡器当作死连接断开。
+	Heartbeat time.Duration
+	// Buffer 是每个客户端的事件缓冲（64）。写满说明客户端跟不上，服务端断开它，由它带 Last-Event-ID 重连。
+	Buffer int
+	// WriteTimeout 是单次写入的期限（10s），代替 http.Server.WriteTimeout: 后者从请求开始计时，会切断长连接。
+	WriteTimeout time.Duration
+	// Retry 是建议客户端的重连间隔（3s），以 retry 字段发送。
+	Retry time.Duration
+}
+
+func (c *EventsConfig) setDefaults() {
+	if c.Heartbeat <= 0 {
+		c.Heartbeat = 15 * time.Second
+	}
+	if c.Buffer <= 0 {
+		c.Buffer = 64
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = 10 * time.Second
+	}
+	if c.Retry <= 0 {
+		c.Retry = 3 * time.Second
+	}
+}
+
+// UserEvents 返回 GET /api/v1/users/events 的处理器，以 SSE 推送 bus 上的用户变更:
+//
+//	id: 5f3a9c1e-42
+//	event: updated
+//	data: {"id":"usr_000001","name":"Alice",...}
+//
+// created/updated 的 data 是写入后的用户，deleted 的 data 只有 id 和 version。
+// 请求带 Last-Event-ID 时先补发日志中之后的事件；错过的事件已被淘汰，或 id 来自另一个 epoch 时先发送 reset 事件。
+func UserEvents(bus *EventBus, cfg EventsConfig) http.Handler {
+	cfg.setDefaults()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last, err := parseLastEventID(r.Header.Get("Last-Event-ID"))
+		if err != nil {
+			WriteError(w, r, NewAppError(ErrInvalidQuery, "Last-Event-ID must be an event id", err))
+			return
+		}
+		sub, replay, complete := bus.Subscribe(last, cfg.Buffer)
+		defer bus.Unsubscribe(sub)
+
+		h := w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		h.Set("X-Accel-Buffering", "no") // 关闭 nginx 的响应缓冲
+		w.WriteHeader(http.StatusOK)
+
+		sw := &sseWriter{w: w, rc: http.NewResponseController(w), timeout: cfg.WriteTimeout, epoch: bus.Epoch()}
+		sw.printf("retry: %d\n\n", cfg.Retry.Milliseconds())
+		if !complete {
+			sw.printf("event: %s\ndata: {}\n\n", EventReset)
+		}
+		for _, ev := range replay {
+			sw.event(ev)
+		}
+		if sw.flush() != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(cfg.Heartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				sw.printf(": heartbeat\n\n")
+			case ev, ok := <-sub.C:
+				if !ok {
+					if bus.Lagged(sub) {
+						slog.WarnContext(r.Context(), "sse client too slow, disconnected",
+							"request_id", RequestIDFromContext(r.Context()))
+					}
+					return
+				}
+				sw.event(ev)
+			}
+			if sw.flush() != nil {
+				return
+			}
+		}
+	})
+}
+
+func parseLastEventID(v string) (*EventID, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+	id, err := ParseEventID(v)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// sseWriter 写出 SSE 帧，第一次出错后的写入都被忽略，错误由 flush 返回。
+type sseWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+	epoch   string
+	err     error
+}
+
+func (sw *sseWriter) printf(format string, args ...any) {
+	if sw.err != nil {
+		return
+	}
+	// 不支持写期限的 ResponseWriter（例如 httptest.ResponseRecorder）返回 ErrNotSupported，忽略即可。
+	_ = sw.rc.SetWriteDeadline(time.Now().Add(sw.timeout))
+	_, sw.err = fmt.Fprintf(sw.w, format, args...)
+}
+
+func (sw *sseWriter) event(ev UserEvent) {
+	var data any = ev.User
+	if ev.Type == EventDeleted {
+		data = struct {
+			ID      string `json:"id"`
+			Version int64  `json:"version"`
+		}{ev.User.ID, ev.User.Version}
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		sw.err = err
+		return
+	}
+	sw.printf("id: %s\nevent: %s\ndata: %s\n\n", EventID{sw.epoch, ev.ID}, ev.Type, b)
+}
+
+func (sw *sseWriter) flush() error {
+	if sw.err != nil {
+		return sw.err
+	}
+	sw.err = sw.rc.Flush()
+	return sw.err
+}
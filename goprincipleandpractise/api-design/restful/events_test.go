@@ -0,0 +1,366 @@
+package restful
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func eventIDs(events []UserEvent) []uint64 {
+	ids := make([]uint64, len(events))
+	for i, ev := range events {
+		ids[i] = ev.ID
+	}
+	return ids
+}
+
+func TestEventBusReplay(t *testing.T) {
+	bus := NewEventBus(3)
+	for range 5 {
+		bus.Publish(EventCreated, User{})
+	}
+	// 日志中是 3、4、5。
+
+	id := func(seq uint64) *EventID { return &EventID{Epoch: bus.Epoch(), Seq: seq} }
+
+	tests := []struct {
+		name         string
+		last         *EventID
+		wantReplay   []uint64
+		wantComplete bool
+	}{
+		{"no Last-Event-ID", nil, nil, true},
+		{"up to date", id(5), nil, true},
+		{"resume inside log", id(3), []uint64{4, 5}, true},
+		{"resume at log boundary", id(2), []uint64{3, 4, 5}, true},
+		{"events evicted", id(1), []uint64{3, 4, 5}, false},
+		{"seq ahead of bus", id(9), nil, false},
+		{"other epoch", &EventID{Epoch: "00000000", Seq: 4}, nil, false},
+		{"id without epoch", &EventID{Seq: 4}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay, complete := bus.Subscribe(tt.last, 1)
+			defer bus.Unsubscribe(sub)
+			if got := eventIDs(replay); !slices.Equal(got, tt.wantReplay) || complete != tt.wantComplete {
+				t.Errorf("replay %v complete %v, want %v %v", got, complete, tt.wantReplay, tt.wantComplete)
+			}
+		})
+	}
+}
+
+func TestEventBusSlowSubscriberDoesNotBlock(t *testing.T) {
+	bus := NewEventBus(0)
+	slow, _, _ := bus.Subscribe(nil, 2)
+	fast, _, _ := bus.Subscribe(nil, 10)
+
+	done := make(chan struct{})
+	go func() {
+		for range 5 {
+			bus.Publish(EventUpdated, User{})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a slow subscriber")
+	}
+
+	// 慢订阅者收到缓冲内的 2 个事件后被断开，快订阅者不受影响。
+	var got []UserEvent
+	for ev := range slow.C {
+		got = append(got, ev)
+	}
+	if len(got) != 2 || !bus.Lagged(slow) {
+		t.Errorf("slow subscriber: %d events, lagged=%v", len(got), bus.Lagged(slow))
+	}
+	if len(fast.C) != 5 || bus.Lagged(fast) {
+		t.Errorf("fast subscriber: %d events buffered, lagged=%v", len(fast.C), bus.Lagged(fast))
+	}
+
+	// Close 结束订阅: 缓冲中的事件读完后通道关闭。
+	bus.Close()
+	n := 0
+	for range fast.C {
+		n++
+	}
+	if n != 5 {
+		t.Errorf("fast subscriber drained %d events after Close, want 5", n)
+	}
+}
+
+func TestPublishingStore(t *testing.T) {
+	ctx := context.Background()
+	bus := NewEventBus(0)
+	sub, _, _ := bus.Subscribe(nil, 10)
+	store := PublishingStore(NewInMemoryUserStore(), bus)
+
+	alice, _ := store.Create(ctx, User{Name: "Alice", Email: "alice@example.com"})
+	_, _ = store.Create(ctx, User{Name: "Dup", Email: "alice@example.com"}) // 失败的写入不发布
+	_, _ = store.Update(ctx, alice.ID, User{Name: "Alice2", Email: "alice@example.com"})
+
+	tx, ok := store.(TxUserStore)
+	if !ok {
+		t.Fatal("PublishingStore hides TxUserStore")
+	}
+	_ = tx.InTx(ctx, func(tx UserStore) error {
+		_ = tx.Delete(ctx, alice.ID, 0)
+		return errors.New("rollback")
+	})
+	_ = tx.InTx(ctx, func(tx UserStore) error { return tx.Delete(ctx, alice.ID, 0) })
+
+	want := []EventType{EventCreated, EventUpdated, EventDeleted}
+	for i, typ := range want {
+		select {
+		case ev := <-sub.C:
+			if ev.Type != typ || ev.ID != uint64(i+1) || ev.User.ID != alice.ID {
+				t.Errorf("event %d = %+v, want %s", i, ev, typ)
+			}
+		default:
+			t.Fatalf("missing event %d (%s)", i, typ)
+		}
+	}
+	if len(sub.C) != 0 {
+		t.Errorf("%d unexpected events", len(sub.C))
+	}
+}
+
+// sseFrame 是一个 SSE 帧中的字段，注释行以 ":" 为键。
+type sseFrame map[string]string
+
+type sseStream struct {
+	resp *http.Response
+	r    *bufio.Reader
+}
+
+func openEvents(t *testing.T, base, lastEventID string) *sseStream {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, base+"/api/v1/users/events", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d, Content-Type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return &sseStream{resp: resp, r: bufio.NewReader(resp.Body)}
+}
+
+// next 读取下一个帧，流结束时返回 nil。
+func (s *sseStream) next(t *testing.T) sseFrame {
+	t.Helper()
+	frame := sseFrame{}
+	for {
+		line, err := s.r.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return frame
+		}
+		key, value, _ := strings.Cut(line, ":")
+		if key == "" {
+			key = ":"
+		}
+		frame[key] = strings.TrimPrefix(value, " ")
+	}
+}
+
+// nextEvent 跳过 retry 和心跳，返回下一个事件帧。
+func (s *sseStream) nextEvent(t *testing.T) sseFrame {
+	t.Helper()
+	for {
+		f := s.next(t)
+		if f == nil || f["event"] != "" {
+			return f
+		}
+	}
+}
+
+func TestPublishingStoreConcurrentUpdateDelete(t *testing.T) {
+	ctx := context.Background()
+	const n = 200
+	bus := NewEventBus(0)
+	sub, _, _ := bus.Subscribe(nil, 3*n)
+	store := PublishingStore(NewInMemoryUserStore(), bus)
+
+	for i := range n {
+		u, err := store.Create(ctx, User{Name: "User", Email: fmt.Sprintf("u%d@example.com", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, _ = store.Update(ctx, u.ID, User{Name: "Updated", Email: u.Email})
+		}()
+		go func() {
+			defer wg.Done()
+			if err := store.Delete(ctx, u.ID, 0); err != nil {
+				t.Errorf("delete %s: %v", u.ID, err)
+			}
+		}()
+		wg.Wait()
+	}
+	bus.Close()
+
+	// 按客户端的方式消费: 版本不大于已知版本的事件是过时的，丢弃。
+	type state struct {
+		version int64
+		deleted bool
+	}
+	users := make(map[string]state)
+	for ev := range sub.C {
+		if cur, ok := users[ev.User.ID]; ok && ev.User.Version <= cur.version {
+			continue
+		}
+		users[ev.User.ID] = state{version: ev.User.Version, deleted: ev.Type == EventDeleted}
+	}
+	if len(users) != n {
+		t.Fatalf("saw %d users, want %d", len(users), n)
+	}
+	for id, st := range users {
+		if !st.deleted {
+			t.Errorf("%s: client ends with version %d, want deleted", id, st.version)
+		}
+	}
+}
+
+func TestUserEventsStream(t *testing.T) {
+	bus := NewEventBus(2)
+	ts := httptest.NewServer(NewServer(WithEvents(bus, EventsConfig{Heartbeat: 20 * time.Millisecond})))
+	t.Cleanup(ts.Close) // 在 t.Context() 取消、事件流断开之后关闭
+
+	stream := openEvents(t, ts.URL, "")
+	if f := stream.next(t); f["retry"] != "3000" {
+		t.Errorf("first frame = %v, want retry", f)
+	}
+
+	create := func(name string) {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/users",
+			strings.NewReader(`{"name":"`+name+`","email":"`+strings.ToLower(name)+`@example.com"}`))
+		req.Header.Set("Authorization", "Bearer demo-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	create("Alice")
+	f := stream.nextEvent(t)
+	if f["id"] != bus.Epoch()+"-1" || f["event"] != "created" || !strings.Contains(f["data"], `"name":"Alice"`) {
+		t.Errorf("event = %v", f)
+	}
+
+	// 空闲时收到心跳。
+	for f := stream.next(t); f[":"] != "heartbeat"; f = stream.next(t) {
+		if f == nil {
+			t.Fatal("stream ended before a heartbeat")
+		}
+	}
+
+	create("Bob")
+	create("Carol")
+
+	// 从 id 1 恢复: 补发 2、3。
+	resumed := openEvents(t, ts.URL, f["id"])
+	for _, want := range []string{"2", "3"} {
+		if f := resumed.nextEvent(t); f["id"] != bus.Epoch()+"-"+want {
+			t.Errorf("replayed event = %v, want id %s", f, want)
+		}
+	}
+
+	// 事件 1 已被淘汰（日志只保留 2 条）: 先收到 reset。
+	stale := openEvents(t, ts.URL, bus.Epoch()+"-0")
+	if f := stale.nextEvent(t); f["event"] != string(EventReset) {
+		t.Errorf("first event = %v, want reset", f)
+	}
+}
+
+func TestUserEventsResetAfterRestart(t *testing.T) {
+	old := NewEventBus(0)
+	for range 3 {
+		old.Publish(EventCreated, User{})
+	}
+	lastID := EventID{Epoch: old.Epoch(), Seq: 3}.String()
+
+	// 重启后的新进程已经发布到序号 10，超过了客户端持有的 3: 仅比较序号会误以为只错过了 4~10。
+	bus := NewEventBus(0)
+	for range 10 {
+		bus.Publish(EventCreated, User{})
+	}
+	ts := httptest.NewServer(NewServer(WithEvents(bus, EventsConfig{})))
+	t.Cleanup(ts.Close)
+
+	stream := openEvents(t, ts.URL, lastID)
+	if f := stream.nextEvent(t); f["event"] != string(EventReset) {
+		t.Errorf("first event = %v, want reset", f)
+	}
+	bus.Publish(EventUpdated, User{ID: "usr_000001"})
+	if f := stream.nextEvent(t); f["id"] != bus.Epoch()+"-11" {
+		t.Errorf("event after reset = %v, want id %s-11", f, bus.Epoch())
+	}
+}
+
+func TestUserEventsInvalidLastEventID(t *testing.T) {
+	for _, v := range []string{"abc", "5f3a9c1e-", "5f3a9c1e-x"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/events", nil)
+		req.Header.Set("Last-Event-ID", v)
+		rec := httptest.NewRecorder()
+		NewServer().ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Last-Event-ID %q: status = %d, want 400", v, rec.Code)
+		}
+	}
+}
+
+func TestRunEndsEventStreamsOnShutdown(t *testing.T) {
+	ln := listenLocal(t)
+	base := "http://" + ln.Addr().String()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- Run(ctx, RunConfig{
+			Listener:     ln,
+			DrainTimeout: 5 * time.Second,
+			Options:      []ServerOption{WithLogger(quietLogger)},
+			Logger:       quietLogger,
+		})
+	}()
+	waitStatus(t, base+"/readyz", http.StatusOK)
+
+	stream := openEvents(t, base, "")
+	stream.next(t) // retry
+	start := time.Now()
+	cancel()
+
+	if f := stream.nextEvent(t); f != nil {
+		t.Errorf("unexpected frame %v", f)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("shutdown took %v; the open stream held up draining", d)
+	}
+}
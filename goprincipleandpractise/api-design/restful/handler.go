@@ -1,8 +1,16 @@
 package restful
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"mime"
 	"net/http"
+	"slices"
 	"sync"
 	"time"
 )
@@ -13,6 +21,7 @@ type User struct {
 	Name      string    `json:"name"`
 	Email     string    `json:"email"`
 	Age       int       `json:"age,omitempty"`
+	Version   int64     `json:"version"` // 每次写入递增，用于 ETag 和乐观并发控制
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -24,20 +33,40 @@ type CreateUserRequest struct {
 	Age   int    `json:"age"   validate:"min=0,max=150"`
 }
 
-// UpdateUserRequest 是更新用户的请求体。
+// UpdateUserRequest 是 PUT 全量替换的请求体，也是 PATCH 的作用对象:
+// patch 应用在该结构的 JSON 表示上，结果按同样的规则重新校验。
 type UpdateUserRequest struct {
-	Name  string `json:"name"  validate:"min=2,max=50"`
-	Email string `json:"email" validate:"email"`
+	Name  string `json:"name"  validate:"required,min=2,max=50"`
+	Email string `json:"email" validate:"required,email"`
 	Age   int    `json:"age"   validate:"min=0,max=150"`
 }
 
 // UserStore 定义用户存储接口（依赖倒置）。
+//
+// 所有方法都接收 context 以支持超时和取消；实现应返回以下哨兵错误，
+// 以便 handler 统一映射为 HTTP 状态码:
+//   - ErrUserNotFound: 资源不存在
+//   - ErrEmailTaken:   email 唯一约束冲突
+//   - ErrStaleVersion: 版本号不匹配（乐观锁冲突）
+//
+// Update 是全量替换: 除 ID 和 CreatedAt 外的字段均以入参为准，零值也会写入。
+// Update 的 user.Version 与 Delete 的 version 为期望的当前版本，非 0 时实现必须
+// 原子地比较并写入（compare-and-swap）；为 0 表示无条件写入。
 type UserStore interface {
-	List() []User
-	Get(id string) (User, bool)
-	Create(user User) User
-	Update(id string, user User) (User, bool)
-	Delete(id string) bool
+	List(ctx context.Context, q ListQuery) (UserPage, error)
+	Get(ctx context.Context, id string) (User, error)
+	Create(ctx context.Context, user User) (User, error)
+	Update(ctx context.Context, id string, user User) (User, error)
+	Delete(ctx context.Context, id string, version int64) error
+}
+
+// TxUserStore 是支持事务的 UserStore，批量接口的 atomic 模式依赖它。
+//
+// InTx 中通过 tx 执行的写入作为一个整体生效: fn 返回错误时全部回滚，
+// fn 之外的读取看不到未提交的写入。fn 内只能使用 tx，不能再调用外层存储。
+type TxUserStore interface {
+	UserStore
+	InTx(ctx context.Context, fn func(tx UserStore) error) error
 }
 
 // InMemoryUserStore 是基于内存的 UserStore 实现，用于示例和测试。
@@ -52,64 +81,106 @@ func NewInMemoryUserStore() *InMemoryUserStore {
 	return &InMemoryUserStore{users: make(map[string]User)}
 }
 
-func (s *InMemoryUserStore) List() []User {
+func (s *InMemoryUserStore) List(ctx context.Context, q ListQuery) (UserPage, error) {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
 	result := make([]User, 0, len(s.users))
 	for _, u := range s.users {
-		result = append(result, u)
+		if q.matches(u) {
+			result = append(result, u)
+		}
 	}
-	return result
+	s.mu.RUnlock()
+
+	keys := q.sortKeys()
+	slices.SortFunc(result, func(a, b User) int {
+		return compareSortKeys(sortKeyValues(a, keys), sortKeyValues(b, keys), keys)
+	})
+	return q.paginate(result), nil
 }
 
-func (s *InMemoryUserStore) Get(id string) (User, bool) {
+func (s *InMemoryUserStore) Get(ctx context.Context, id string) (User, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	u, ok := s.users[id]
-	return u, ok
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return u, nil
 }
 
-func (s *InMemoryUserStore) Create(user User) User {
+func (s *InMemoryUserStore) Create(ctx context.Context, user User) (User, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if s.emailTakenLocked(user.Email, "") {
+		return User{}, ErrEmailTaken
+	}
 	s.seq++
 	user.ID = idFromSeq(s.seq)
+	user.Version = 1
 	now := time.Now().UTC()
 	user.CreatedAt = now
 	user.UpdatedAt = now
 	s.users[user.ID] = user
-	return user
+	return user, nil
 }
 
-func (s *InMemoryUserStore) Update(id string, user User) (User, bool) {
+func (s *InMemoryUserStore) Update(ctx context.Context, id string, user User) (User, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	existing, ok := s.users[id]
 	if !ok {
-		return User{}, false
+		return User{}, ErrUserNotFound
 	}
-	if user.Name != "" {
-		existing.Name = user.Name
+	if user.Version != 0 && user.Version != existing.Version {
+		return User{}, ErrStaleVersion
 	}
-	if user.Email != "" {
-		existing.Email = user.Email
-	}
-	if user.Age != 0 {
-		existing.Age = user.Age
+	if s.emailTakenLocked(user.Email, id) {
+		return User{}, ErrEmailTaken
 	}
+	existing.Name = user.Name
+	existing.Email = user.Email
+	existing.Age = user.Age
+	existing.Version++
 	existing.UpdatedAt = time.Now().UTC()
 	s.users[id] = existing
-	return existing, true
+	return existing, nil
 }
 
-func (s *InMemoryUserStore) Delete(id string) bool {
+func (s *InMemoryUserStore) Delete(ctx context.Context, id string, version int64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	_, ok := s.users[id]
-	if ok {
-		delete(s.users, id)
+	existing, ok := s.users[id]
+	if !ok {
+		return ErrUserNotFound
 	}
-	return ok
+	if version != 0 && version != existing.Version {
+		return ErrStaleVersion
+	}
+	delete(s.users, id)
+	return nil
+}
+
+// InTx 在写锁下对副本执行 fn，成功后整体替换，期间其他读写都会等待。
+// 复制是 O(n) 的，仅适用于示例和测试规模的数据。
+func (s *InMemoryUserStore) InTx(ctx context.Context, fn func(tx UserStore) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := &InMemoryUserStore{users: maps.Clone(s.users), seq: s.seq}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.users, s.seq = tx.users, tx.seq
+	return nil
+}
+
+// emailTakenLocked 检查 email 是否已被除 exceptID 之外的用户占用，调用方需持有锁。
+func (s *InMemoryUserStore) emailTakenLocked(email, exceptID string) bool {
+	for id, u := range s.users {
+		if id != exceptID && u.Email == email {
+			return true
+		}
+	}
+	return false
 }
 
 func idFromSeq(seq int) string {
@@ -129,117 +200,291 @@ func padInt(n, width int) string {
 
 // UserHandler 封装用户资源的 HTTP 处理器。
 type UserHandler struct {
-	store         UserStore
-	idempotencyMu sync.RWMutex
-	idempotency   map[string][]byte // Idempotency-Key → 响应缓存
+	store          UserStore
+	requireIfMatch bool
+	maxBatch       int
+}
+
+// UserHandlerOption 配置 UserHandler 的可选项。
+type UserHandlerOption func(*UserHandler)
+
+// WithRequireIfMatch 要求 PUT/PATCH/DELETE 必须携带 If-Match，缺失时返回 428，
+// 强制客户端基于已知版本修改资源，避免"最后写入者获胜"覆盖他人的修改。
+func WithRequireIfMatch(required bool) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.requireIfMatch = required
+	}
 }
 
 // NewUserHandler 创建 UserHandler。
-func NewUserHandler(store UserStore) *UserHandler {
-	return &UserHandler{
-		store:       store,
-		idempotency: make(map[string][]byte),
+func NewUserHandler(store UserStore, opts ...UserHandlerOption) *UserHandler {
+	h := &UserHandler{store: store, maxBatch: DefaultMaxBatchSize}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 // ListUsers GET /api/v1/users
+// 支持 offset/游标分页、排序和过滤，参数见 ParseListQuery。
+// 分页信息同时写入 meta 和 Link 头部（RFC 8288）。
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	users := h.store.List()
-	WriteSuccess(w, http.StatusOK, users)
+	q, err := ParseListQuery(r.URL.Query())
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	page, err := h.store.List(r.Context(), q)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	meta := Meta{Total: page.Total, Limit: q.Limit, Offset: q.Offset}
+	if q.After == nil {
+		meta.Page = q.Offset/q.Limit + 1
+	}
+	if page.HasMore && len(page.Users) > 0 {
+		meta.NextCursor = encodeCursor(page.Users[len(page.Users)-1], q.Sort)
+	}
+	if link := paginationLinks(r.URL, q, page, meta.NextCursor); link != "" {
+		w.Header().Add("Link", link)
+	}
+
+	// 集合没有单一版本号，用响应内容的哈希作为 ETag。
+	body, _ := json.Marshal(Response[[]User]{Data: page.Users, Meta: &meta})
+	if checkNotModified(w, r, contentETag(body)) {
+		return
+	}
+	WriteSuccessWithMeta(w, r, page.Users, meta)
 }
 
 // GetUser GET /api/v1/users/{id}
+// 响应携带 ETag；If-None-Match 命中时返回 304。
 func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	user, ok := h.store.Get(id)
-	if !ok {
-		WriteError(w, ErrUserNotFound)
+	user, err := h.store.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err)
 		return
 	}
-	WriteSuccess(w, http.StatusOK, user)
+	if checkNotModified(w, r, userETag(user)) {
+		return
+	}
+	WriteSuccess(w, r, http.StatusOK, user)
 }
 
 // CreateUser POST /api/v1/users
-// 支持 Idempotency-Key 头部实现幂等创建。
+// 幂等性（Idempotency-Key）由 Idempotency 中间件在路由层提供。
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
-	// 幂等性检查
-	idempotencyKey := r.Header.Get("Idempotency-Key")
-	if idempotencyKey != "" {
-		h.idempotencyMu.RLock()
-		cached, ok := h.idempotency[idempotencyKey]
-		h.idempotencyMu.RUnlock()
-		if ok {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.Header().Set("X-Idempotent-Replayed", "true")
-			w.WriteHeader(http.StatusCreated)
-			_, _ = w.Write(cached)
-			return
-		}
-	}
-
 	var req CreateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		WriteError(w, ErrInvalidBody)
+		WriteError(w, r, ErrInvalidBody)
 		return
 	}
 
 	if errs := Validate(req); len(errs) > 0 {
-		WriteValidationError(w, errs)
+		WriteValidationError(w, r, errs)
 		return
 	}
 
-	user := h.store.Create(User{
+	user, err := h.store.Create(r.Context(), User{
 		Name:  req.Name,
 		Email: req.Email,
 		Age:   req.Age,
 	})
-
-	// 缓存幂等响应
-	if idempotencyKey != "" {
-		resp := Response[User]{Data: user}
-		data, _ := json.Marshal(resp)
-		h.idempotencyMu.Lock()
-		h.idempotency[idempotencyKey] = data
-		h.idempotencyMu.Unlock()
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
 	}
 
-	WriteSuccess(w, http.StatusCreated, user)
+	setETag(w, r, userETag(user))
+	WriteSuccess(w, r, http.StatusCreated, user)
 }
 
 // UpdateUser PUT /api/v1/users/{id}
+// PUT 是全量替换: 请求体中缺省的字段会被置为零值。部分更新请使用 PATCH。
+// 携带 If-Match 时为条件更新，版本不匹配返回 412。
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
 	var req UpdateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		WriteError(w, ErrInvalidBody)
+		WriteError(w, r, ErrInvalidBody)
 		return
 	}
-
 	if errs := Validate(req); len(errs) > 0 {
-		WriteValidationError(w, errs)
+		WriteValidationError(w, r, errs)
 		return
 	}
 
-	user, ok := h.store.Update(id, User{
-		Name:  req.Name,
-		Email: req.Email,
-		Age:   req.Age,
+	version, ok := h.expectedVersion(w, r, id)
+	if !ok {
+		return
+	}
+
+	user, err := h.store.Update(r.Context(), id, User{
+		Name:    req.Name,
+		Email:   req.Email,
+		Age:     req.Age,
+		Version: version,
 	})
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	setETag(w, r, userETag(user))
+	WriteSuccess(w, r, http.StatusOK, user)
+}
+
+// PatchUser PATCH /api/v1/users/{id}
+// 根据 Content-Type 选择补丁语义:
+//   - application/merge-patch+json (RFC 7386): {"age": null} 可清空字段
+//   - application/json-patch+json  (RFC 6902): 操作序列，test 失败返回 409
+//
+// 补丁作用于当前资源的 UpdateUserRequest 表示，结果重新走校验后整体写回。
+func (h *UserHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	apply, ok := patchFuncs[mediaType(r.Header.Get("Content-Type"))]
 	if !ok {
-		WriteError(w, ErrUserNotFound)
+		w.Header().Set("Accept-Patch", MediaTypeMergePatch+", "+MediaTypeJSONPatch)
+		WriteError(w, r, NewAppError(ErrUnsupportedMedia,
+			"PATCH requires Content-Type "+MediaTypeMergePatch+" or "+MediaTypeJSONPatch, nil))
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil || !json.Valid(patch) {
+		WriteError(w, r, ErrInvalidBody)
+		return
+	}
+
+	// 读-改-写总是以读到的版本做 CAS。客户端带了 If-Match 时冲突返回 412；
+	// 否则说明只是与其他写入并发，重新读取后再应用补丁即可。
+	for attempt := 1; ; attempt++ {
+		current, err := h.store.Get(r.Context(), id)
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		if !checkIfMatch(w, r, current, h.requireIfMatch) {
+			return
+		}
+
+		req, appErr := applyUserPatch(apply, current, patch)
+		if appErr != nil {
+			WriteError(w, r, appErr)
+			return
+		}
+		if errs := Validate(req); len(errs) > 0 {
+			WriteValidationError(w, r, errs)
+			return
+		}
+
+		user, err := h.store.Update(r.Context(), id, User{
+			Name:    req.Name,
+			Email:   req.Email,
+			Age:     req.Age,
+			Version: current.Version,
+		})
+		if errors.Is(err, ErrStaleVersion) && r.Header.Get("If-Match") == "" && attempt < maxPatchAttempts {
+			continue
+		}
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		setETag(w, r, userETag(user))
+		WriteSuccess(w, r, http.StatusOK, user)
 		return
 	}
+}
+
+// maxPatchAttempts 是无 If-Match 的 PATCH 遇到并发写入时的最大尝试次数。
+const maxPatchAttempts = 3
+
+// applyUserPatch 将 patch 应用到 current 的 UpdateUserRequest 表示上。
+func applyUserPatch(apply func(doc, patch []byte) ([]byte, error), current User, patch []byte) (UpdateUserRequest, *AppError) {
+	doc, _ := json.Marshal(UpdateUserRequest{Name: current.Name, Email: current.Email, Age: current.Age})
+
+	patched, err := apply(doc, patch)
+	if err != nil {
+		var testErr *PatchTestFailedError
+		if errors.As(err, &testErr) {
+			return UpdateUserRequest{}, NewAppError(ErrConflict, "patch test operation failed", err).WithDetail(testErr.Path)
+		}
+		return UpdateUserRequest{}, NewAppError(ErrInvalidPatch, "patch cannot be applied", err).WithDetail(err.Error())
+	}
 
-	WriteSuccess(w, http.StatusOK, user)
+	var req UpdateUserRequest
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return UpdateUserRequest{}, NewAppError(ErrInvalidPatch, "patched document does not match the user schema", err).WithDetail(err.Error())
+	}
+	return req, nil
+}
+
+// patchFuncs 按媒体类型注册补丁实现。
+var patchFuncs = map[string]func(doc, patch []byte) ([]byte, error){
+	MediaTypeMergePatch: ApplyMergePatch,
+	MediaTypeJSONPatch:  ApplyJSONPatch,
+}
+
+// mediaType 返回去掉参数（如 charset）后的小写媒体类型。
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return mt
+}
+
+// expectedVersion 处理 PUT/DELETE 的 If-Match，返回交给存储层做 CAS 的期望版本。
+// 未携带 If-Match（且不强制）时返回 0，即无条件写入。
+// 用 If-Match 校验过的版本做 CAS，才能关闭"检查后写入"之间的竞态窗口。
+func (h *UserHandler) expectedVersion(w http.ResponseWriter, r *http.Request, id string) (int64, bool) {
+	if !h.requireIfMatch && r.Header.Get("If-Match") == "" {
+		return 0, true
+	}
+	current, err := h.store.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return 0, false
+	}
+	if !checkIfMatch(w, r, current, h.requireIfMatch) {
+		return 0, false
+	}
+	return current.Version, true
 }
 
 // DeleteUser DELETE /api/v1/users/{id}
+// 与 PUT 一样支持 If-Match 条件删除。
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	if !h.store.Delete(id) {
-		WriteError(w, ErrUserNotFound)
+
+	version, ok := h.expectedVersion(w, r, id)
+	if !ok {
+		return
+	}
+	if err := h.store.Delete(r.Context(), id, version); err != nil {
+		writeStoreError(w, r, err)
 		return
 	}
 	WriteNoContent(w)
 }
+
+// writeStoreError 将存储层错误写为响应: *AppError 原样返回，其余一律视为内部错误，
+// 避免把数据库错误细节泄漏给客户端。
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		WriteError(w, r, appErr)
+		return
+	}
+	slog.ErrorContext(r.Context(), "store error",
+		"request_id", RequestIDFromContext(r.Context()), "error", err)
+	WriteError(w, r, ErrServerFailure)
+}
@@ -0,0 +1,281 @@
+package restful
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage 是未协商出语言时使用的语言。
+const DefaultLanguage = "en"
+
+// Catalog 是错误消息目录，按语言保存 ErrCode 和校验规则对应的消息。
+//
+// 规则消息是模板，支持 {field}、{param}、{rule} 占位符。min/max/len 按字段类型区分 key:
+// "min.string"（字符数）、"min.items"（元素个数）、"min"（数值），其余规则直接以规则名为 key，
+// "" 是未登记规则（例如 RegisterRule 注册的自定义规则）的兜底模板。
+//
+// Catalog 在构建完成后只读，可以被多个请求并发使用。
+type Catalog struct {
+	langs map[string]*catalogMessages
+}
+
+type catalogMessages struct {
+	errors map[ErrCode]string
+	rules  map[string]string
+}
+
+// NewCatalog 创建空目录。
+func NewCatalog() *Catalog {
+	return &Catalog{langs: make(map[string]*catalogMessages)}
+}
+
+func (c *Catalog) lang(lang string) *catalogMessages {
+	m, ok := c.langs[lang]
+	if !ok {
+		m = &catalogMessages{errors: make(map[ErrCode]string), rules: make(map[string]string)}
+		c.langs[lang] = m
+	}
+	return m
+}
+
+// SetError 设置 lang 下 code 对应的消息。
+func (c *Catalog) SetError(lang string, code ErrCode, msg string) *Catalog {
+	c.lang(lang).errors[code] = msg
+	return c
+}
+
+// SetRule 设置 lang 下校验规则的消息模板。
+func (c *Catalog) SetRule(lang, key, template string) *Catalog {
+	c.lang(lang).rules[key] = template
+	return c
+}
+
+// Languages 返回目录支持的语言。
+func (c *Catalog) Languages() []string {
+	langs := make([]string, 0, len(c.langs))
+	for lang := range c.langs {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+	return langs
+}
+
+// ErrorMessage 返回 code 在 lang 下的消息；目录中没有时返回 fallback（通常是 AppError 自带的英文消息）。
+func (c *Catalog) ErrorMessage(lang string, code ErrCode, fallback string) string {
+	if m, ok := c.langs[lang]; ok {
+		if msg, ok := m.errors[code]; ok {
+			return msg
+		}
+	}
+	return fallback
+}
+
+// RuleMessage 渲染校验规则在 lang 下的消息，lang 缺少模板时回退到 DefaultLanguage。
+func (c *Catalog) RuleMessage(lang, field, rule, param string, kind reflect.Kind) string {
+	key := ruleKey(rule, kind)
+	for _, l := range []string{lang, DefaultLanguage} {
+		m, ok := c.langs[l]
+		if !ok {
+			continue
+		}
+		for _, k := range []string{key, rule, ""} {
+			if tmpl, ok := m.rules[k]; ok {
+				return strings.NewReplacer("{field}", field, "{param}", param, "{rule}", rule).Replace(tmpl)
+			}
+		}
+	}
+	return field + " failed " + rule + " validation"
+}
+
+// ruleKey 返回规则的模板 key，min/max/len 按字段类型细分。
+func ruleKey(rule string, kind reflect.Kind) string {
+	switch rule {
+	case "min", "max", "len":
+		switch {
+		case kind == reflect.String:
+			return rule + ".string"
+		case hasLength(kind):
+			return rule + ".items"
+		}
+	}
+	return rule
+}
+
+// Match 按 Accept-Language（RFC 9110 §12.5.4）选出目录支持的语言。
+// 先按 q 值排序，每个语言范围依次尝试完全匹配和主语言子标签匹配（zh-CN → zh）；都不匹配时返回 DefaultLanguage。
+func (c *Catalog) Match(acceptLanguage string) string {
+	type langRange struct {
+		tag string
+		q   float64
+	}
+	var ranges []langRange
+	for part := range strings.SplitSeq(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			ranges = append(ranges, langRange{strings.ToLower(tag), q})
+		}
+	}
+	slices.SortStableFunc(ranges, func(a, b langRange) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+
+	for _, r := range ranges {
+		if r.tag == "*" {
+			return DefaultLanguage
+		}
+		if _, ok := c.langs[r.tag]; ok {
+			return r.tag
+		}
+		primary, _, _ := strings.Cut(r.tag, "-")
+		if _, ok := c.langs[primary]; ok {
+			return primary
+		}
+	}
+	return DefaultLanguage
+}
+
+// ── 请求语言 ────────────────────────────────────────
+
+type localeKey struct{}
+
+type locale struct {
+	catalog *Catalog
+	lang    string
+}
+
+// Localize 返回语言协商中间件: 根据 Accept-Language 选出语言并存入 context，
+// 之后 WriteError / WriteValidationError 会使用 catalog 中对应语言的消息。
+func Localize(catalog *Catalog) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lang := catalog.Match(r.Header.Get("Accept-Language"))
+			w.Header().Add("Vary", "Accept-Language")
+			ctx := context.WithValue(r.Context(), localeKey{}, locale{catalog: catalog, lang: lang})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// LanguageFromContext 返回 Localize 协商出的语言，未经过 Localize 时返回 DefaultLanguage。
+func LanguageFromContext(ctx context.Context) string {
+	if loc, ok := ctx.Value(localeKey{}).(locale); ok {
+		return loc.lang
+	}
+	return DefaultLanguage
+}
+
+// localeFrom 返回请求的语言设置，未经过 Localize 时使用内置目录和默认语言。
+func localeFrom(r *http.Request) (locale, bool) {
+	if r != nil {
+		if loc, ok := r.Context().Value(localeKey{}).(locale); ok {
+			return loc, true
+		}
+	}
+	return locale{catalog: builtinCatalog, lang: DefaultLanguage}, false
+}
+
+// ── 内置目录 ────────────────────────────────────────
+
+// builtinCatalog 用于生成 Validate 的默认（英文）消息。
+var builtinCatalog = DefaultCatalog()
+
+// DefaultCatalog 返回内置的中英文目录。
+// 英文不登记 ErrCode 消息，直接使用 AppError 自带的更具体的消息。
+func DefaultCatalog() *Catalog {
+	c := NewCatalog()
+
+	c.SetError("en", ErrValidationFailed, "request validation failed")
+	for key, tmpl := range map[string]string{
+		"":           "{field} failed {rule} validation",
+		"required":   "{field} is required",
+		"email":      "{field} must be a valid email address",
+		"url":        "{field} must be a valid URL",
+		"uuid":       "{field} must be a valid UUID",
+		"regexp":     "{field} must match {param}",
+		"oneof":      "{field} must be one of [{param}]",
+		"eqfield":    "{field} must equal {param}",
+		"gtfield":    "{field} must be greater than {param}",
+		"min.string": "{field} must be at least {param} characters",
+		"min.items":  "{field} must contain at least {param} items",
+		"min":        "{field} must be at least {param}",
+		"max.string": "{field} must be at most {param} characters",
+		"max.items":  "{field} must contain at most {param} items",
+		"max":        "{field} must be at most {param}",
+		"len.string": "{field} must be exactly {param} characters",
+		"len.items":  "{field} must contain exactly {param} items",
+		"len":        "{field} must be {param}",
+	} {
+		c.SetRule("en", key, tmpl)
+	}
+
+	for code, msg := range map[ErrCode]string{
+		ErrInvalidJSON:      "请求体不是合法的 JSON",
+		ErrInvalidQuery:     "查询参数不合法",
+		ErrInvalidArgument:  "请求参数不合法",
+		ErrValidationFailed: "请求参数校验失败",
+		ErrInvalidPatch:     "补丁无法应用",
+		ErrUnsupportedMedia: "不支持的媒体类型",
+		ErrTooLarge:         "请求过大",
+		ErrNotAcceptable:    "不支持请求的响应格式",
+		ErrUnauthorized:     "未认证或认证信息无效",
+		ErrForbidden:        "没有访问权限",
+		ErrNotFound:         "资源不存在",
+		ErrConflict:         "资源冲突",
+		ErrIdempotencyReuse: "Idempotency-Key 已被用于不同的请求",
+		ErrPrecondition:     "资源已被修改",
+		ErrPreconditionReq:  "缺少 If-Match 请求头",
+		ErrBatchAborted:     "批量操作中的其他操作失败，本操作未执行",
+		ErrRateLimited:      "请求过于频繁，请稍后再试",
+		ErrCanceled:         "请求已取消",
+		ErrDeadlineExceeded: "请求超时",
+		ErrInternalError:    "服务器内部错误",
+		ErrNotImplemented:   "服务端不支持该功能",
+		ErrUnavailable:      "服务暂时不可用，请稍后再试",
+	} {
+		c.SetError("zh", code, msg)
+	}
+	for key, tmpl := range map[string]string{
+		"":           "{field} 未通过 {rule} 校验",
+		"required":   "{field} 为必填项",
+		"email":      "{field} 必须是有效的邮箱地址",
+		"url":        "{field} 必须是有效的 URL",
+		"uuid":       "{field} 必须是有效的 UUID",
+		"regexp":     "{field} 必须匹配 {param}",
+		"oneof":      "{field} 必须是 [{param}] 之一",
+		"eqfield":    "{field} 必须与 {param} 一致",
+		"gtfield":    "{field} 必须大于 {param}",
+		"min.string": "{field} 至少 {param} 个字符",
+		"min.items":  "{field} 至少包含 {param} 项",
+		"min":        "{field} 不能小于 {param}",
+		"max.string": "{field} 最多 {param} 个字符",
+		"max.items":  "{field} 最多包含 {param} 项",
+		"max":        "{field} 不能大于 {param}",
+		"len.string": "{field} 必须为 {param} 个字符",
+		"len.items":  "{field} 必须包含 {param} 项",
+		"len":        "{field} 必须等于 {param}",
+	} {
+		c.SetRule("zh", key, tmpl)
+	}
+	return c
+}
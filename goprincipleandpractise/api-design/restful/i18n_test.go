@@ -0,0 +1,132 @@
+package restful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCatalogMatch(t *testing.T) {
+	c := DefaultCatalog()
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"zh", "zh"},
+		{"zh-CN,zh;q=0.9,en;q=0.8", "zh"},
+		{"en-US,en;q=0.9,zh;q=0.8", "en"},
+		{"fr-FR, zh-TW;q=0.5", "zh"},
+		{"en;q=0.3, zh;q=0.7", "zh"},
+		{"zh;q=0, en", "en"},
+		{"fr, *;q=0.1", "en"},
+		{"ZH-hans-CN", "zh"},
+		{"zh;q=abc", "en"},
+	}
+	for _, tt := range tests {
+		if got := c.Match(tt.header); got != tt.want {
+			t.Errorf("Match(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogRuleMessage(t *testing.T) {
+	c := DefaultCatalog()
+	tests := []struct {
+		lang, rule, param string
+		kind              reflect.Kind
+		want              string
+	}{
+		{"en", "min", "2", reflect.String, "name must be at least 2 characters"},
+		{"en", "min", "2", reflect.Slice, "name must contain at least 2 items"},
+		{"en", "min", "2", reflect.Int, "name must be at least 2"},
+		{"zh", "min", "2", reflect.String, "name 至少 2 个字符"},
+		{"zh", "required", "", reflect.String, "name 为必填项"},
+		{"zh", "custom", "", reflect.String, "name 未通过 custom 校验"},
+		{"fr", "required", "", reflect.String, "name is required"},
+	}
+	for _, tt := range tests {
+		if got := c.RuleMessage(tt.lang, "name", tt.rule, tt.param, tt.kind); got != tt.want {
+			t.Errorf("RuleMessage(%s, %s, %v) = %q, want %q", tt.lang, tt.rule, tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestLocalizedErrors(t *testing.T) {
+	srv := NewServer()
+
+	do := func(method, path, body, lang string) (*httptest.ResponseRecorder, ErrorResponse) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer demo-token")
+		if lang != "" {
+			req.Header.Set("Accept-Language", lang)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		var resp ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode %s %s: %v", method, path, err)
+		}
+		return rec, resp
+	}
+
+	t.Run("validation zh", func(t *testing.T) {
+		rec, resp := do(http.MethodPost, "/api/v1/users", `{"name":"A","email":"bad"}`, "zh-CN,zh;q=0.9")
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("status = %d", rec.Code)
+		}
+		if rec.Header().Get("Content-Language") != "zh" || !strings.Contains(rec.Header().Get("Vary"), "Accept-Language") {
+			t.Errorf("headers = %v", rec.Header())
+		}
+		if resp.Error.Message != "请求参数校验失败" {
+			t.Errorf("message = %q", resp.Error.Message)
+		}
+		want := map[string]FieldError{
+			"name":  {Rule: "min", Param: "2", Message: "name 至少 2 个字符"},
+			"email": {Rule: "email", Message: "email 必须是有效的邮箱地址"},
+		}
+		if !reflect.DeepEqual(map[string]FieldError(resp.Error.Fields), want) {
+			t.Errorf("fields = %+v, want %+v", resp.Error.Fields, want)
+		}
+	})
+
+	t.Run("validation default en", func(t *testing.T) {
+		rec, resp := do(http.MethodPost, "/api/v1/users", `{"name":"A","email":"a@example.com"}`, "")
+		if rec.Header().Get("Content-Language") != "en" {
+			t.Errorf("Content-Language = %q", rec.Header().Get("Content-Language"))
+		}
+		if resp.Error.Message != "request validation failed" {
+			t.Errorf("message = %q", resp.Error.Message)
+		}
+		if fe := resp.Error.Fields["name"]; fe.Rule != "min" || fe.Message != "name must be at least 2 characters" {
+			t.Errorf("name = %+v", fe)
+		}
+	})
+
+	t.Run("app error", func(t *testing.T) {
+		_, zh := do(http.MethodGet, "/api/v1/users/missing", "", "zh")
+		if zh.Error.Code != ErrNotFound || zh.Error.Message != "资源不存在" {
+			t.Errorf("zh = %+v", zh.Error)
+		}
+		// 英文保留 AppError 自带的具体消息。
+		_, en := do(http.MethodGet, "/api/v1/users/missing", "", "en")
+		if en.Error.Message != ErrUserNotFound.Message {
+			t.Errorf("en = %+v", en.Error)
+		}
+	})
+
+	t.Run("custom catalog", func(t *testing.T) {
+		c := DefaultCatalog().SetError("ja", ErrNotFound, "見つかりません")
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/missing", nil)
+		req.Header.Set("Accept-Language", "ja-JP")
+		rec := httptest.NewRecorder()
+		NewServer(WithCatalog(c)).ServeHTTP(rec, req)
+		if !strings.Contains(rec.Body.String(), "見つかりません") {
+			t.Errorf("body = %s", rec.Body)
+		}
+	})
+}
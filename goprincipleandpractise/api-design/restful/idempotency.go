@@ -0,0 +1,287 @@
+package restful
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// IdempotencyRecord 是一次已完成请求的响应快照，用于重放。
+type IdempotencyRecord struct {
+	Fingerprint string // 请求指纹: method + path + body 的哈希
+	StatusCode  int
+	Header      http.Header // 只含 handler 自己设置的头部，不含外层中间件设置的（X-Request-ID、RateLimit-* 等）
+	Body        []byte
+}
+
+// ErrIdempotencyInFlight 表示同一个 Idempotency-Key 的请求仍在处理中。
+var ErrIdempotencyInFlight = errors.New("idempotency: request with this key is in flight")
+
+// IdempotencyStore 保存 Idempotency-Key 对应的处理状态和响应。
+//
+// 一个 key 的生命周期: Begin 占用 → 处理 → Complete 保存响应，或 Release 放弃。
+// 实现必须保证 Begin 对同一个 key 是互斥的，这样并发的重复请求只有一个会真正执行。
+// 内置 MemoryIdempotencyStore；多实例部署可基于 Redis/数据库实现该接口。
+type IdempotencyStore interface {
+	// Begin 尝试占用 key:
+	//   - key 已完成: 返回保存的记录，调用方负责比对指纹后重放
+	//   - key 处理中: 返回 ErrIdempotencyInFlight
+	//   - 否则占用 key 并返回 (nil, nil)
+	Begin(ctx context.Context, key string) (*IdempotencyRecord, error)
+	// Complete 保存响应并释放占用。
+	Complete(ctx context.Context, key string, rec IdempotencyRecord) error
+	// Release 释放占用但不保存响应，允许客户端用同一个 key 重试。
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryIdempotencyStore 是带 TTL 和 LRU 容量上限的内存 IdempotencyStore。
+// 处理中的 key 单独存放，不参与淘汰，避免淘汰后重复执行。
+type MemoryIdempotencyStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	capacity int
+	lru      *list.List               // 元素为 *idempotencyEntry，队首最近使用
+	entries  map[string]*list.Element // 已完成的 key
+	inFlight map[string]struct{}
+	now      func() time.Time // 便于测试注入时钟
+}
+
+type idempotencyEntry struct {
+	key       string
+	record    IdempotencyRecord
+	expiresAt time.Time
+}
+
+// NewMemoryIdempotencyStore 创建内存存储。ttl 为记录保留时长，capacity 为最多保留的记录数。
+func NewMemoryIdempotencyStore(ttl time.Duration, capacity int) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		ttl:      ttl,
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		inFlight: make(map[string]struct{}),
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryIdempotencyStore) Begin(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.inFlight[key]; ok {
+		return nil, ErrIdempotencyInFlight
+	}
+	if el, ok := s.entries[key]; ok {
+		entry := el.Value.(*idempotencyEntry)
+		if s.now().Before(entry.expiresAt) {
+			s.lru.MoveToFront(el)
+			rec := entry.record
+			return &rec, nil
+		}
+		s.removeLocked(el)
+	}
+	s.inFlight[key] = struct{}{}
+	return nil, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, rec IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inFlight, key)
+	if el, ok := s.entries[key]; ok {
+		s.removeLocked(el)
+	}
+	s.entries[key] = s.lru.PushFront(&idempotencyEntry{
+		key:       key,
+		record:    rec,
+		expiresAt: s.now().Add(s.ttl),
+	})
+	for s.lru.Len() > s.capacity {
+		s.removeLocked(s.lru.Back())
+	}
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inFlight, key)
+	return nil
+}
+
+// Len 返回当前保存的已完成记录数（含尚未清理的过期记录）。
+func (s *MemoryIdempotencyStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+func (s *MemoryIdempotencyStore) removeLocked(el *list.Element) {
+	s.lru.Remove(el)
+	delete(s.entries, el.Value.(*idempotencyEntry).key)
+}
+
+// maxIdempotentBodyBytes 限制参与指纹计算的请求体大小。
+const maxIdempotentBodyBytes = 1 << 20
+
+// Idempotency 返回幂等中间件，任何 POST 路由都可以通过它支持 Idempotency-Key:
+//   - 首次请求: 执行 handler，保存响应（5xx 不保存，允许重试）
+//   - 重复请求: 重放保存的响应，并带上 X-Idempotent-Replayed: true
+//   - 同 key 不同请求体: 422，防止客户端误用 key
+//   - 同 key 请求仍在处理: 409，而不是并发执行两次
+//
+// key 的作用域是调用方: 放在 Authenticate 之后时，存储中的 key 带上认证主体，
+// 不同主体碰巧使用同一个 Idempotency-Key 不会互相冲突或拿到对方的响应。
+//
+// 只保存 handler 自己设置的头部: X-Request-ID、RateLimit-*、CORS 等由外层中间件按本次请求生成，
+// 重放时保留本次的值，不能被第一次请求的值覆盖。
+//
+// 语义参考 IETF draft-ietf-httpapi-idempotency-key-header。
+func Idempotency(store IdempotencyStore) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodyBytes))
+			if err != nil {
+				WriteError(w, r, ErrInvalidBody)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			fingerprint := requestFingerprint(r, body)
+
+			key = scopedIdempotencyKey(r, key)
+			rec, err := store.Begin(r.Context(), key)
+			switch {
+			case errors.Is(err, ErrIdempotencyInFlight):
+				w.Header().Set("Retry-After", "1")
+				WriteError(w, r, ErrIdempotencyConflict)
+				return
+			case err != nil:
+				writeStoreError(w, r, err)
+				return
+			case rec != nil:
+				if rec.Fingerprint != fingerprint {
+					WriteError(w, r, ErrIdempotencyMismatch)
+					return
+				}
+				replay(w, rec)
+				return
+			}
+
+			capture := &captureWriter{ResponseWriter: w, statusCode: http.StatusOK, before: w.Header().Clone()}
+			completed := false
+			defer func() {
+				// handler panic 或返回 5xx 时释放 key，让客户端可以安全重试。
+				if !completed {
+					_ = store.Release(context.WithoutCancel(r.Context()), key)
+				}
+			}()
+
+			next.ServeHTTP(capture, r)
+
+			if capture.statusCode < http.StatusInternalServerError {
+				_ = store.Complete(context.WithoutCancel(r.Context()), key, IdempotencyRecord{
+					Fingerprint: fingerprint,
+					StatusCode:  capture.statusCode,
+					Header:      capture.header,
+					Body:        capture.body.Bytes(),
+				})
+				completed = true
+			}
+		})
+	}
+}
+
+// scopedIdempotencyKey 返回存储中使用的 key: 有认证主体时为 "<method>:<subject>:<key>"。
+// subject 只在同一种认证方式内唯一，所以带上 method。
+func scopedIdempotencyKey(r *http.Request, key string) string {
+	if p, ok := PrincipalFromContext(r.Context()); ok {
+		return p.Method + ":" + p.Subject + ":" + key
+	}
+	return key
+}
+
+// requestFingerprint 计算请求指纹。包含 method 和 path，
+// 同一个 key 被用于不同端点时也能识别出来。
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replay 写出保存的响应。本次请求中已经存在的头部不被覆盖，只补上其中没有的值（例如 Vary）。
+func replay(w http.ResponseWriter, rec *IdempotencyRecord) {
+	h := w.Header()
+	for k, values := range rec.Header {
+		for _, v := range values {
+			if !slices.Contains(h[k], v) {
+				h[k] = append(h[k], v)
+			}
+		}
+	}
+	w.Header().Set("X-Idempotent-Replayed", "true")
+	w.WriteHeader(rec.StatusCode)
+	_, _ = w.Write(rec.Body)
+}
+
+// captureWriter 在写出响应的同时保留状态码、handler 设置的头部和响应体副本。
+type captureWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	before      http.Header // 调用 handler 之前已有的头部，即外层中间件设置的
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (c *captureWriter) WriteHeader(code int) {
+	if !c.wroteHeader {
+		c.wroteHeader = true
+		c.statusCode = code
+		c.header = headerDiff(c.before, c.ResponseWriter.Header())
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+// headerDiff 返回 after 相对 before 新增的值；handler 替换了某个头部时保留替换后的全部值。
+func headerDiff(before, after http.Header) http.Header {
+	diff := http.Header{}
+	for k, values := range after {
+		old := before[k]
+		switch {
+		case slices.Equal(old, values):
+		case len(values) > len(old) && slices.Equal(old, values[:len(old)]):
+			diff[k] = slices.Clone(values[len(old):])
+		default:
+			diff[k] = slices.Clone(values)
+		}
+	}
+	return diff
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	c.body.Write(p)
+	return c.ResponseWriter.Write(p)
+}
+
+func (c *captureWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
@@ -0,0 +1,244 @@
+package restful
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryIdempotencyStoreTTLAndLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	store := NewMemoryIdempotencyStore(time.Minute, 2)
+	store.now = func() time.Time { return now }
+
+	complete := func(key string) {
+		t.Helper()
+		if rec, err := store.Begin(ctx, key); rec != nil || err != nil {
+			t.Fatalf("Begin(%s) = %v, %v; want fresh key", key, rec, err)
+		}
+		if err := store.Complete(ctx, key, IdempotencyRecord{Fingerprint: key}); err != nil {
+			t.Fatalf("Complete(%s): %v", key, err)
+		}
+	}
+
+	complete("a")
+	complete("b")
+	// 访问 a，使 b 成为最久未使用的记录。
+	if rec, _ := store.Begin(ctx, "a"); rec == nil {
+		t.Fatal("Begin(a): expected stored record")
+	}
+	complete("c")
+
+	if store.Len() != 2 {
+		t.Fatalf("Len = %d, want capacity 2", store.Len())
+	}
+	if rec, _ := store.Begin(ctx, "b"); rec != nil {
+		t.Fatal("Begin(b): least recently used record should have been evicted")
+	}
+	if _, err := store.Begin(ctx, "b"); err != ErrIdempotencyInFlight {
+		t.Fatalf("Begin(b) twice: err = %v, want ErrIdempotencyInFlight", err)
+	}
+	_ = store.Release(ctx, "b")
+
+	now = now.Add(2 * time.Minute)
+	if rec, _ := store.Begin(ctx, "a"); rec != nil {
+		t.Fatal("Begin(a) after TTL: expected expired record to be dropped")
+	}
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+	status := http.StatusCreated
+	handler := Idempotency(NewMemoryIdempotencyStore(time.Hour, 100))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			w.Header().Set("Location", "/things/1")
+			writeJSON(w, status, map[string]string{"id": "1"})
+		}))
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := post("k1", `{"n":1}`)
+	replayed := post("k1", `{"n":1}`)
+	if calls != 1 {
+		t.Fatalf("handler calls = %d, want 1", calls)
+	}
+	if replayed.Code != http.StatusCreated || replayed.Body.String() != first.Body.String() {
+		t.Fatalf("replay = %d %q, want %d %q", replayed.Code, replayed.Body, first.Code, first.Body)
+	}
+	if replayed.Header().Get("Location") != "/things/1" || replayed.Header().Get("X-Idempotent-Replayed") != "true" {
+		t.Errorf("replay headers = %v", replayed.Header())
+	}
+
+	if rec := post("k1", `{"n":2}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("same key, different body: expected 422, got %d", rec.Code)
+	}
+
+	// 5xx 不保存，同一个 key 可以重试。
+	status = http.StatusInternalServerError
+	post("k2", `{}`)
+	status = http.StatusCreated
+	if rec := post("k2", `{}`); rec.Code != http.StatusCreated || rec.Header().Get("X-Idempotent-Replayed") != "" {
+		t.Fatalf("retry after 5xx: got %d replayed=%q, want fresh 201", rec.Code, rec.Header().Get("X-Idempotent-Replayed"))
+	}
+}
+
+func TestIdempotencyReplayKeepsRequestScopedHeaders(t *testing.T) {
+	limiter := NewRateLimiter(100, time.Minute)
+	handler := Chain(RequestID, limiter.Middleware, Idempotency(NewMemoryIdempotencyStore(time.Hour, 100)))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", "/things/1")
+			writeJSON(w, http.StatusCreated, map[string]string{"id": "1"})
+		}))
+
+	post := func(requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "k1")
+		req.Header.Set(RequestIDHeader, requestID)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := post("req-aaa")
+	replayed := post("req-bbb")
+	if replayed.Header().Get("X-Idempotent-Replayed") != "true" {
+		t.Fatalf("second request was not replayed: %v", replayed.Header())
+	}
+	if got := replayed.Header().Values(RequestIDHeader); len(got) != 1 || got[0] != "req-bbb" {
+		t.Errorf("replayed %s = %v, want [req-bbb]", RequestIDHeader, got)
+	}
+	if a, b := first.Header().Get("RateLimit-Remaining"), replayed.Header().Values("RateLimit-Remaining"); len(b) != 1 || b[0] == a {
+		t.Errorf("RateLimit-Remaining: first %q, replay %v; want a fresh single value", a, b)
+	}
+	if replayed.Header().Get("Location") != "/things/1" {
+		t.Errorf("handler header not replayed: %v", replayed.Header())
+	}
+}
+
+func TestIdempotencyKeyScopedByPrincipal(t *testing.T) {
+	var calls int
+	authn := StaticTokens(map[string]Principal{
+		"alice-token": {Subject: "alice", Method: AuthMethodToken},
+		"bob-token":   {Subject: "bob", Method: AuthMethodToken},
+	})
+	handler := Chain(Authenticate(authn), Idempotency(NewMemoryIdempotencyStore(time.Hour, 100)))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			p, _ := PrincipalFromContext(r.Context())
+			writeJSON(w, http.StatusCreated, map[string]string{"owner": p.Subject})
+		}))
+
+	post := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Idempotency-Key", "shared")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	post("alice-token", `{"n":1}`)
+	// 相同的 key 和请求体: bob 的请求真正执行，而不是拿到 alice 的响应。
+	same := post("bob-token", `{"n":1}`)
+	if same.Header().Get("X-Idempotent-Replayed") != "" || !strings.Contains(same.Body.String(), `"owner":"bob"`) {
+		t.Errorf("bob got %d %s replayed=%q, want his own response", same.Code, same.Body, same.Header().Get("X-Idempotent-Replayed"))
+	}
+	// 作用域内的误用照常检测: bob 用自己的 key 换了请求体仍是 422。
+	if rec := post("bob-token", `{"n":2}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("bob reusing his own key with another body: status = %d, want 422", rec.Code)
+	}
+	if rec := post("alice-token", `{"n":1}`); rec.Header().Get("X-Idempotent-Replayed") != "true" {
+		t.Errorf("alice retry was not replayed: %v", rec.Header())
+	}
+	if calls != 2 {
+		t.Errorf("handler calls = %d, want 2", calls)
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := Idempotency(NewMemoryIdempotencyStore(time.Hour, 100))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(entered)
+			<-release
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest(http.MethodPost, "/things", nil)
+		req.Header.Set("Idempotency-Key", "slow")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-entered
+
+	req := httptest.NewRequest(http.MethodPost, "/things", nil)
+	req.Header.Set("Idempotency-Key", "slow")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	close(release)
+	<-done
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("concurrent duplicate: expected 409, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("409 for in-flight key should carry Retry-After")
+	}
+}
+
+// TestIdempotentCreateUserConcurrent 验证并发的重复创建只会产生一个用户。
+func TestIdempotentCreateUserConcurrent(t *testing.T) {
+	store := NewInMemoryUserStore()
+	srv := NewServer(WithUserStore(store))
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/users",
+				strings.NewReader(`{"name":"Racer","email":"racer@example.com"}`))
+			req.Header.Set("Authorization", "Bearer demo-token")
+			req.Header.Set("Idempotency-Key", "race-key")
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			if rec.Code != http.StatusCreated && rec.Code != http.StatusConflict {
+				t.Errorf("unexpected status %d: %s", rec.Code, rec.Body.String())
+			}
+			if rec.Code == http.StatusConflict {
+				var errResp ErrorResponse
+				_ = json.NewDecoder(rec.Body).Decode(&errResp)
+				if errResp.Error.Message != ErrIdempotencyConflict.Message {
+					t.Errorf("409 should come from the in-flight lock, got %q", errResp.Error.Message)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	page, _ := store.List(context.Background(), ListQuery{})
+	if page.Total != 1 {
+		t.Fatalf("users created = %d, want 1", page.Total)
+	}
+}
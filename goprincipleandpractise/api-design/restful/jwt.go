@@ -0,0 +1,207 @@
+package restful
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCredentials 是所有凭证校验失败的基础错误，Authenticator 返回的错误应当包装它，
+// 中间件据此区分 401（凭证无效）和 500（例如 APIKeyStore 故障）。
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// JWT 校验失败的具体原因。
+var (
+	ErrTokenMalformed   = fmt.Errorf("%w: malformed token", ErrInvalidCredentials)
+	ErrTokenAlgorithm   = fmt.Errorf("%w: unsupported signing algorithm", ErrInvalidCredentials)
+	ErrTokenSignature   = fmt.Errorf("%w: signature verification failed", ErrInvalidCredentials)
+	ErrTokenExpired     = fmt.Errorf("%w: token is expired", ErrInvalidCredentials)
+	ErrTokenNotYetValid = fmt.Errorf("%w: token is not valid yet", ErrInvalidCredentials)
+	ErrTokenIssuer      = fmt.Errorf("%w: unexpected issuer", ErrInvalidCredentials)
+	ErrTokenAudience    = fmt.Errorf("%w: unexpected audience", ErrInvalidCredentials)
+)
+
+// JWTConfig 配置 JWT 校验。HMACSecret 和 RSAPublicKey 至少设置一个，
+// 只有配置了密钥的算法才会被接受，从而杜绝 alg=none 和 RS256→HS256 的算法混淆攻击。
+type JWTConfig struct {
+	Issuer       string         // 非空时要求 iss 相等
+	Audience     string         // 非空时要求 aud 包含该值
+	HMACSecret   []byte         // HS256 密钥，至少 32 字节（RFC 7518 §3.2）
+	RSAPublicKey *rsa.PublicKey // RS256 公钥
+	Leeway       time.Duration  // exp/nbf 的时钟偏差容忍
+}
+
+// JWTClaims 是 JWT 中用到的注册声明（RFC 7519 §4.1）和 OAuth 2.0 的 scope（RFC 8693 §4.2）。
+type JWTClaims struct {
+	Issuer    string       `json:"iss,omitempty"`
+	Subject   string       `json:"sub,omitempty"`
+	Audience  Audience     `json:"aud,omitempty"`
+	ExpiresAt *NumericDate `json:"exp,omitempty"`
+	NotBefore *NumericDate `json:"nbf,omitempty"`
+	IssuedAt  *NumericDate `json:"iat,omitempty"`
+	Scope     string       `json:"scope,omitempty"` // 空格分隔
+}
+
+// Audience 是 aud 声明，可以是单个字符串或字符串数组。
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// NumericDate 是自 Unix 纪元起的秒数，可以带小数。
+type NumericDate struct {
+	time.Time
+}
+
+// NewNumericDate 将 t 截断到秒。
+func NewNumericDate(t time.Time) *NumericDate {
+	return &NumericDate{t.Truncate(time.Second)}
+}
+
+func (d NumericDate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Unix())
+}
+
+func (d *NumericDate) UnmarshalJSON(data []byte) error {
+	var sec float64
+	if err := json.Unmarshal(data, &sec); err != nil {
+		return err
+	}
+	whole, frac := math.Modf(sec)
+	d.Time = time.Unix(int64(whole), int64(frac*1e9))
+	return nil
+}
+
+// JWTVerifier 校验 HS256/RS256 签名的 JWT（JWS 紧凑序列化）。
+type JWTVerifier struct {
+	cfg JWTConfig
+	now func() time.Time
+}
+
+// NewJWTVerifier 创建校验器，配置缺少密钥或 HMAC 密钥过短时返回错误。
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	if cfg.HMACSecret == nil && cfg.RSAPublicKey == nil {
+		return nil, errors.New("jwt: no verification key configured")
+	}
+	if cfg.HMACSecret != nil && len(cfg.HMACSecret) < sha256.Size {
+		return nil, fmt.Errorf("jwt: HS256 secret must be at least %d bytes", sha256.Size)
+	}
+	return &JWTVerifier{cfg: cfg, now: time.Now}, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// Verify 校验签名和 exp/nbf/iss/aud，返回声明。exp 是必需的，不过期的 token 一律拒绝。
+func (v *JWTVerifier) Verify(token string) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if err := v.verifySignature(header.Alg, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims JWTClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if err := v.validateClaims(&claims); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+func (v *JWTVerifier) verifySignature(alg, signingInput string, sig []byte) error {
+	switch {
+	case alg == "HS256" && v.cfg.HMACSecret != nil:
+		mac := hmac.New(sha256.New, v.cfg.HMACSecret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return ErrTokenSignature
+		}
+	case alg == "RS256" && v.cfg.RSAPublicKey != nil:
+		digest := sha256.Sum256([]byte(signingInput))
+		if rsa.VerifyPKCS1v15(v.cfg.RSAPublicKey, crypto.SHA256, digest[:], sig) != nil {
+			return ErrTokenSignature
+		}
+	default:
+		return ErrTokenAlgorithm
+	}
+	return nil
+}
+
+func (v *JWTVerifier) validateClaims(c *JWTClaims) error {
+	now := v.now()
+	if c.ExpiresAt == nil || !now.Before(c.ExpiresAt.Add(v.cfg.Leeway)) {
+		return ErrTokenExpired
+	}
+	if c.NotBefore != nil && now.Before(c.NotBefore.Add(-v.cfg.Leeway)) {
+		return ErrTokenNotYetValid
+	}
+	if v.cfg.Issuer != "" && c.Issuer != v.cfg.Issuer {
+		return ErrTokenIssuer
+	}
+	if v.cfg.Audience != "" && !slices.Contains(c.Audience, v.cfg.Audience) {
+		return ErrTokenAudience
+	}
+	return nil
+}
+
+// Authenticate 从 Authorization: Bearer 中取出 JWT 并校验，成功时以 sub 和 scope 构造 Principal。
+// 请求没有 Bearer token 或 token 不是 JWT 格式时返回 ErrNoCredentials，交给下一个 Authenticator。
+func (v *JWTVerifier) Authenticate(r *http.Request) (*Principal, error) {
+	token, ok := bearerToken(r)
+	if !ok || strings.Count(token, ".") != 2 {
+		return nil, ErrNoCredentials
+	}
+	claims, err := v.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{
+		Subject: claims.Subject,
+		Scopes:  strings.Fields(claims.Scope),
+		Method:  AuthMethodJWT,
+	}, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
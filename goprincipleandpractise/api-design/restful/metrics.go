@@ -0,0 +1,228 @@
+package restful
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets 是延迟直方图的默认桶上界（秒），与 Prometheus 客户端库的 DefBuckets 一致。
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics 收集 HTTP 指标，并以 Prometheus 文本格式（0.0.4）输出，只依赖标准库:
+//
+//	http_requests_total{method,route,status,code}        请求计数，code 为错误响应的 ErrCode
+//	http_request_duration_seconds{method,route}          延迟直方图
+//	http_requests_in_flight{method,route}                正在处理的请求数
+//
+// route 使用路由模式（GET /api/v1/users/{id} 中的 /api/v1/users/{id}），而不是原始路径，
+// 否则每个不同的 id 都会产生一条新的时间序列，导致基数爆炸。
+type Metrics struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	requests  map[requestSeries]uint64
+	durations map[routeSeries]*histogram
+	inFlight  map[routeSeries]int64
+}
+
+type routeSeries struct {
+	method, route string
+}
+
+type requestSeries struct {
+	routeSeries
+	status int
+	code   ErrCode
+}
+
+type histogram struct {
+	counts []uint64 // 每个桶（不累计）的观测数，最后一个是 +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewMetrics 创建指标收集器，buckets 为空时使用 DefaultLatencyBuckets。
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	return &Metrics{
+		buckets:   buckets,
+		requests:  make(map[requestSeries]uint64),
+		durations: make(map[routeSeries]*histogram),
+		inFlight:  make(map[routeSeries]int64),
+	}
+}
+
+// ── 错误码回传 ──────────────────────────────────────
+
+type observationKey struct{}
+
+// requestObservation 由 Metrics 中间件放入 context，WriteError 把 ErrCode 写回这里。
+type requestObservation struct {
+	code ErrCode
+}
+
+// observeErrCode 记录本次请求的 ErrCode，请求未经过 Metrics 中间件时什么也不做。
+func observeErrCode(r *http.Request, code ErrCode) {
+	if r == nil {
+		return
+	}
+	if obs, ok := r.Context().Value(observationKey{}).(*requestObservation); ok {
+		obs.code = code
+	}
+}
+
+// ── 中间件 ──────────────────────────────────────────
+
+// Middleware 返回指标中间件。它应放在 Recovery 之外，这样 panic 产生的 500 也会被计入。
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeSeries{method: r.Method, route: routePath(r.Pattern)}
+		m.addInFlight(route, 1)
+		defer m.addInFlight(route, -1)
+
+		obs := &requestObservation{}
+		rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), observationKey{}, obs)))
+		m.observe(route, rec.statusCode, obs.code, time.Since(start))
+	})
+}
+
+// routePath 去掉模式中的方法和主机部分，方法已经是单独的标签。
+func routePath(pattern string) string {
+	if pattern == "" {
+		return "unmatched"
+	}
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		pattern = path
+	}
+	if i := strings.Index(pattern, "/"); i > 0 {
+		pattern = pattern[i:]
+	}
+	return pattern
+}
+
+func (m *Metrics) addInFlight(route routeSeries, delta int64) {
+	m.mu.Lock()
+	m.inFlight[route] += delta
+	m.mu.Unlock()
+}
+
+func (m *Metrics) observe(route routeSeries, status int, code ErrCode, d time.Duration) {
+	sec := d.Seconds()
+	i, _ := slices.BinarySearch(m.buckets, sec) // 第一个 >= sec 的桶，即 le 语义
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestSeries{routeSeries: route, status: status, code: code}]++
+	h, ok := m.durations[route]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets)+1)}
+		m.durations[route] = h
+	}
+	h.counts[i]++
+	h.sum += sec
+	h.count++
+}
+
+// ── 文本格式输出 ────────────────────────────────────
+
+// ServeHTTP 以 Prometheus 文本格式输出全部指标。
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.WritePrometheus(w)
+}
+
+// WritePrometheus 将全部指标以文本格式写入 out，序列按标签排序，输出稳定。
+// 只在复制快照时持有锁，慢的抓取方不会阻塞正在记录指标的请求。
+func (m *Metrics) WritePrometheus(out io.Writer) error {
+	requests, durations, inFlight := m.snapshot()
+	w := bufio.NewWriter(out)
+
+	fmt.Fprintln(w, "# HELP http_requests_total Total number of HTTP requests.")
+	fmt.Fprintln(w, "# TYPE http_requests_total counter")
+	reqKeys := slices.SortedFunc(maps.Keys(requests), func(a, b requestSeries) int {
+		if c := compareRoute(a.routeSeries, b.routeSeries); c != 0 {
+			return c
+		}
+		if a.status != b.status {
+			return a.status - b.status
+		}
+		return strings.Compare(string(a.code), string(b.code))
+	})
+	for _, k := range reqKeys {
+		fmt.Fprintf(w, "http_requests_total{%s,status=%q,code=%s} %d\n",
+			routeLabels(k.routeSeries), strconv.Itoa(k.status), quoteLabel(string(k.code)), requests[k])
+	}
+
+	fmt.Fprintln(w, "# HELP http_request_duration_seconds HTTP request latency in seconds.")
+	fmt.Fprintln(w, "# TYPE http_request_duration_seconds histogram")
+	for _, k := range slices.SortedFunc(maps.Keys(durations), compareRoute) {
+		h := durations[k]
+		labels := routeLabels(k)
+		var cumulative uint64
+		for i, le := range m.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=%q} %d\n", labels, formatFloat(le), cumulative)
+		}
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(w, "http_request_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP http_requests_in_flight Number of HTTP requests currently being served.")
+	fmt.Fprintln(w, "# TYPE http_requests_in_flight gauge")
+	for _, k := range slices.SortedFunc(maps.Keys(inFlight), compareRoute) {
+		fmt.Fprintf(w, "http_requests_in_flight{%s} %d\n", routeLabels(k), inFlight[k])
+	}
+	return w.Flush()
+}
+
+// snapshot 在锁内复制全部指标。
+func (m *Metrics) snapshot() (map[requestSeries]uint64, map[routeSeries]histogram, map[routeSeries]int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	durations := make(map[routeSeries]histogram, len(m.durations))
+	for k, h := range m.durations {
+		durations[k] = histogram{counts: slices.Clone(h.counts), sum: h.sum, count: h.count}
+	}
+	return maps.Clone(m.requests), durations, maps.Clone(m.inFlight)
+}
+
+func routeLabels(k routeSeries) string {
+	return "method=" + quoteLabel(k.method) + ",route=" + quoteLabel(k.route)
+}
+
+func compareRoute(a, b routeSeries) int {
+	if c := strings.Compare(a.route, b.route); c != 0 {
+		return c
+	}
+	return strings.Compare(a.method, b.method)
+}
+
+// quoteLabel 按文本格式转义标签值: 只转义反斜杠、双引号和换行。
+func quoteLabel(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
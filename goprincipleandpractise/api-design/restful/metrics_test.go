@@ -0,0 +1,161 @@
+package restful
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T, h http.Handler) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("scrape: %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	return rec.Body.String()
+}
+
+func TestMetricsExposition(t *testing.T) {
+	m := NewMetrics(0.1, 1)
+	route := routeSeries{method: "GET", route: "/users/{id}"}
+	m.observe(route, 200, "", 50*time.Millisecond)
+	m.observe(route, 200, "", 500*time.Millisecond)
+	m.observe(route, 404, ErrNotFound, 2*time.Second)
+	m.addInFlight(route, 1)
+
+	want := `# HELP http_requests_total Total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",route="/users/{id}",status="200",code=""} 2
+http_requests_total{method="GET",route="/users/{id}",status="404",code="not_found"} 1
+# HELP http_request_duration_seconds HTTP request latency in seconds.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{method="GET",route="/users/{id}",le="0.1"} 1
+http_request_duration_seconds_bucket{method="GET",route="/users/{id}",le="1"} 2
+http_request_duration_seconds_bucket{method="GET",route="/users/{id}",le="+Inf"} 3
+http_request_duration_seconds_sum{method="GET",route="/users/{id}"} 2.55
+http_request_duration_seconds_count{method="GET",route="/users/{id}"} 3
+# HELP http_requests_in_flight Number of HTTP requests currently being served.
+# TYPE http_requests_in_flight gauge
+http_requests_in_flight{method="GET",route="/users/{id}"} 1
+`
+	if got := scrape(t, m); got != want {
+		t.Errorf("exposition mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMetricsServer(t *testing.T) {
+	m := NewMetrics()
+	srv := NewServer(WithMetrics(m), WithMetricsEndpoint())
+
+	for _, path := range []string{"/api/v1/users", "/api/v1/users/usr_1", "/api/v1/users/usr_2"} {
+		srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(`{}`)))
+
+	body := scrape(t, srv)
+	for _, line := range []string{
+		`http_requests_total{method="GET",route="/api/v1/users",status="200",code=""} 1`,
+		// 路由模式作为标签，两个不同 id 聚合为同一条序列。
+		`http_requests_total{method="GET",route="/api/v1/users/{id}",status="404",code="not_found"} 2`,
+		`http_requests_total{method="POST",route="/api/v1/users",status="401",code="unauthorized"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/api/v1/users/{id}"} 2`,
+		`http_requests_in_flight{method="GET",route="/api/v1/users/{id}"} 0`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %s\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "usr_1") || strings.Contains(body, `route="/metrics"`) {
+		t.Errorf("raw paths or the scrape itself leaked into metrics:\n%s", body)
+	}
+}
+
+func TestMetricsCountsPanicsAndInFlight(t *testing.T) {
+	m := NewMetrics()
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /slow", Chain(m.Middleware, Recovery)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})))
+	mux.Handle("GET /panic", Chain(m.Middleware, Recovery)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}()
+	<-entered
+	if body := scrape(t, m); !strings.Contains(body, `http_requests_in_flight{method="GET",route="/slow"} 1`) {
+		t.Errorf("in-flight gauge not raised:\n%s", body)
+	}
+	close(release)
+	<-done
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	body := scrape(t, m)
+	for _, line := range []string{
+		`http_requests_in_flight{method="GET",route="/slow"} 0`,
+		`http_requests_total{method="GET",route="/panic",status="500",code="internal_error"} 1`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("missing %s\n%s", line, body)
+		}
+	}
+}
+
+func TestMetricsEndpointOptIn(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewServer().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("/metrics without WithMetricsEndpoint: status = %d, want 404", rec.Code)
+	}
+}
+
+// blockingWriter 的写入阻塞到 release 被关闭，模拟读取很慢的抓取方。
+type blockingWriter struct {
+	once    sync.Once
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.entered) })
+	<-w.release
+	return len(p), nil
+}
+
+func TestMetricsSlowScrapeDoesNotBlockRequests(t *testing.T) {
+	m := NewMetrics()
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	w := &blockingWriter{entered: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() { done <- m.WritePrometheus(w) }()
+	<-w.entered
+
+	served := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		close(served)
+	}()
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Error("request blocked while a scrape was writing")
+	}
+	close(w.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,10 +1,15 @@
 package restful
 
 import (
-	"log"
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net"
 	"net/http"
-	"strings"
-	"sync"
+	"net/netip"
+	"runtime/debug"
 	"time"
 )
 
@@ -24,128 +29,157 @@ func Chain(middlewares ...Middleware) Middleware {
 	}
 }
 
-// Recovery 捕获 handler 中的 panic，返回 500 而不是让服务器崩溃。
-func Recovery(next http.Handler) http.Handler {
+// ── 请求 ID ─────────────────────────────────────────
+
+// RequestIDHeader 是携带请求 ID 的头部。
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// maxRequestIDLen 限制客户端传入的请求 ID 长度，防止日志被超长值污染。
+const maxRequestIDLen = 128
+
+// RequestID 为每个请求分配 ID: 客户端传入合法的 X-Request-ID 时沿用（便于跨服务串联），
+// 否则生成新的随机 ID。ID 写入 context 和响应头部，日志、错误响应都通过它关联同一个请求。
+func RequestID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			if rec := recover(); rec != nil {
-				log.Printf("[PANIC] %s %s: %v", r.Method, r.URL.Path, rec)
-				WriteError(w, ErrServerFailure)
-			}
-		}()
-		next.ServeHTTP(w, r)
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// responseRecorder 包装 ResponseWriter 以捕获状态码。
-type responseRecorder struct {
-	http.ResponseWriter
-	statusCode int
+// RequestIDFromContext 返回 RequestID 中间件写入的请求 ID，没有时返回空串。
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
 }
 
-func (rr *responseRecorder) WriteHeader(code int) {
-	rr.statusCode = code
-	rr.ResponseWriter.WriteHeader(code)
+// validRequestID 只接受可见 ASCII 字符，拒绝空值、超长值和可用于日志注入的控制字符。
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := range len(id) {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
 }
 
-// Logging 记录每个请求的方法、路径、状态码和耗时。
-func Logging(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
-		next.ServeHTTP(rec, r)
-		log.Printf("[HTTP] %s %s → %d (%s)",
-			r.Method, r.URL.Path, rec.statusCode, time.Since(start))
-	})
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
 }
 
-// Auth 验证 Authorization 头部的 Bearer token。
-// tokenValidator 用于自定义 token 验证逻辑；为 nil 时使用内置的 demo token。
-func Auth(tokenValidator func(token string) bool) Middleware {
-	if tokenValidator == nil {
-		tokenValidator = func(token string) bool {
-			return token == "demo-token" // 仅用于示例
-		}
-	}
+// ── 恢复与日志 ──────────────────────────────────────
+
+// Recovery 捕获 handler 中的 panic，返回 500 而不是让服务器崩溃。
+// 使用 slog.Default() 记录日志，需要指定 logger 时用 RecoveryWith。
+func Recovery(next http.Handler) http.Handler {
+	return RecoveryWith(slog.Default())(next)
+}
+
+// RecoveryWith 返回使用 logger 的 Recovery，日志包含堆栈和请求 ID。
+func RecoveryWith(logger *slog.Logger) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			auth := r.Header.Get("Authorization")
-			if !strings.HasPrefix(auth, "Bearer ") {
-				WriteError(w, NewAppError(ErrUnauthorized, "missing or invalid Authorization header", nil))
-				return
-			}
-			token := strings.TrimPrefix(auth, "Bearer ")
-			if !tokenValidator(token) {
-				WriteError(w, NewAppError(ErrUnauthorized, "invalid token", nil))
-				return
-			}
+			defer func() {
+				if rec := recover(); rec != nil {
+					if rec == http.ErrAbortHandler {
+						panic(rec) // 约定的中止信号，交给 net/http 处理
+					}
+					logger.LogAttrs(r.Context(), slog.LevelError, "panic recovered",
+						slog.String("method", r.Method),
+						slog.String("path", r.URL.Path),
+						slog.String("request_id", RequestIDFromContext(r.Context())),
+						slog.Any("panic", rec),
+						slog.String("stack", string(debug.Stack())),
+					)
+					WriteError(w, r, ErrServerFailure)
+				}
+			}()
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
-// RateLimiter 实现简单的滑动窗口限流器（基于固定窗口近似）。
-// 生产环境建议使用 golang.org/x/time/rate 或分布式方案。
-type RateLimiter struct {
-	mu       sync.Mutex
-	requests map[string][]time.Time
-	limit    int
-	window   time.Duration
+// responseRecorder 包装 ResponseWriter 以捕获状态码和响应字节数。
+// 它透传 http.Flusher 和 http.Hijacker，并实现 Unwrap 供 http.ResponseController 使用，
+// 这样 SSE、WebSocket 等依赖这些接口的 handler 在日志中间件之后仍能工作。
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	bytes       int64
+	wroteHeader bool
 }
 
-// NewRateLimiter 创建限流器，limit 为窗口内最大请求数。
-func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
-	return &RateLimiter{
-		requests: make(map[string][]time.Time),
-		limit:    limit,
-		window:   window,
+func (rr *responseRecorder) WriteHeader(code int) {
+	if !rr.wroteHeader {
+		rr.statusCode = code
+		rr.wroteHeader = code >= 200 // 1xx 信息响应之后还会有最终状态码
 	}
+	rr.ResponseWriter.WriteHeader(code)
 }
 
-// Middleware 返回限流中间件。
-func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		clientIP := r.RemoteAddr
-
-		rl.mu.Lock()
-		now := time.Now()
-		windowStart := now.Add(-rl.window)
-
-		// 清理过期记录
-		reqs := rl.requests[clientIP]
-		valid := reqs[:0]
-		for _, t := range reqs {
-			if t.After(windowStart) {
-				valid = append(valid, t)
-			}
-		}
+func (rr *responseRecorder) Write(p []byte) (int, error) {
+	rr.wroteHeader = true
+	n, err := rr.ResponseWriter.Write(p)
+	rr.bytes += int64(n)
+	return n, err
+}
 
-		if len(valid) >= rl.limit {
-			rl.requests[clientIP] = valid
-			rl.mu.Unlock()
-			w.Header().Set("Retry-After", "60")
-			WriteError(w, NewAppError(ErrRateLimited, "rate limit exceeded", nil))
-			return
-		}
+func (rr *responseRecorder) Flush() {
+	rr.wroteHeader = true
+	_ = http.NewResponseController(rr.ResponseWriter).Flush()
+}
 
-		rl.requests[clientIP] = append(valid, now)
-		rl.mu.Unlock()
+func (rr *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(rr.ResponseWriter).Hijack()
+}
 
-		next.ServeHTTP(w, r)
-	})
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
 }
 
-// CORS 添加跨域资源共享头部。
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
+// Logging 使用 slog.Default() 记录每个请求，需要指定 logger 或受信代理时用 LoggingWith。
+func Logging(next http.Handler) http.Handler {
+	return LoggingWith(slog.Default(), nil)(next)
+}
 
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
+// LoggingWith 返回结构化访问日志中间件，记录方法、路由模式、状态码、响应字节数、耗时、客户端 IP 和请求 ID。
+// route 使用 ServeMux 匹配到的模式（如 GET /api/v1/users/{id}）而不是原始路径，便于聚合；
+// 客户端 IP 的解析规则见 ClientIP。5xx 记为 Error，4xx 记为 Warn。
+func LoggingWith(logger *slog.Logger, trustedProxies []netip.Prefix) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			level := slog.LevelInfo
+			switch {
+			case rec.statusCode >= 500:
+				level = slog.LevelError
+			case rec.statusCode >= 400:
+				level = slog.LevelWarn
+			}
+			logger.LogAttrs(r.Context(), level, "http request",
+				slog.String("method", r.Method),
+				slog.String("route", r.Pattern),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.statusCode),
+				slog.Int64("bytes", rec.bytes),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("client_ip", ClientIP(r, trustedProxies)),
+				slog.String("request_id", RequestIDFromContext(r.Context())),
+			)
+		})
+	}
 }
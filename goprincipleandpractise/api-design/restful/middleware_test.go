@@ -0,0 +1,160 @@
+package restful
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"generated", "", false},
+		{"honored", "trace-abc-123", true},
+		{"control chars rejected", "abc\ninjected", false},
+		{"too long rejected", strings.Repeat("x", maxRequestIDLen+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(RequestIDHeader)
+			if got == "" || got != seen {
+				t.Fatalf("header %q, context %q: want equal and non-empty", got, seen)
+			}
+			if (got == tt.incoming) != tt.keep {
+				t.Errorf("request ID = %q, incoming %q, keep %v", got, tt.incoming, tt.keep)
+			}
+		})
+	}
+}
+
+func TestLoggingWith(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /things/{id}", Chain(RequestID, LoggingWith(logger, nil))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+			_, _ = w.Write([]byte("hello"))
+		})))
+
+	req := httptest.NewRequest(http.MethodGet, "/things/42", nil)
+	req.RemoteAddr = "203.0.113.7:5555"
+	req.Header.Set(RequestIDHeader, "req-1")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log line %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"level":      "WARN",
+		"method":     "GET",
+		"route":      "GET /things/{id}",
+		"path":       "/things/42",
+		"status":     float64(http.StatusTeapot),
+		"bytes":      float64(5),
+		"client_ip":  "203.0.113.7",
+		"request_id": "req-1",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s = %v, want %v", k, entry[k], v)
+		}
+	}
+	if _, ok := entry["latency"]; !ok {
+		t.Error("latency missing")
+	}
+}
+
+func TestRecoveryLogsStack(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	h := Chain(RequestID, RecoveryWith(logger))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set(RequestIDHeader, "req-panic")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	var entry struct {
+		Panic     string `json:"panic"`
+		RequestID string `json:"request_id"`
+		Stack     string `json:"stack"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log line %q: %v", buf.String(), err)
+	}
+	if entry.Panic != "boom" || entry.RequestID != "req-panic" {
+		t.Errorf("entry = %+v", entry)
+	}
+	if !strings.Contains(entry.Stack, "TestRecoveryLogsStack") {
+		t.Errorf("stack does not point at the panicking handler:\n%s", entry.Stack)
+	}
+}
+
+// hijackableRecorder 模拟支持 Hijack 的连接。
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	return nil, nil, nil
+}
+
+func TestResponseRecorderPassesThroughInterfaces(t *testing.T) {
+	under := &hijackableRecorder{ResponseRecorder: httptest.NewRecorder()}
+	rr := &responseRecorder{ResponseWriter: under, statusCode: http.StatusOK}
+
+	var w http.ResponseWriter = rr
+	if _, ok := w.(http.Flusher); !ok {
+		t.Fatal("responseRecorder should implement http.Flusher")
+	}
+	_, _ = w.Write([]byte("data: 1\n\n"))
+	w.(http.Flusher).Flush()
+	if !under.Flushed {
+		t.Error("Flush was not passed through")
+	}
+	if rr.bytes != 9 {
+		t.Errorf("bytes = %d, want 9", rr.bytes)
+	}
+
+	if _, _, err := w.(http.Hijacker).Hijack(); err != nil || !under.hijacked {
+		t.Errorf("Hijack not passed through: err=%v", err)
+	}
+
+	// 底层不支持 Hijack 时返回 http.ErrNotSupported，而不是 panic。
+	plain := &responseRecorder{ResponseWriter: httptest.NewRecorder()}
+	if _, _, err := plain.Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("Hijack on plain writer: err = %v, want ErrNotSupported", err)
+	}
+}
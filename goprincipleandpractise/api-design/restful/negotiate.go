@@ -0,0 +1,101 @@
+package restful
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// mediaRange 是 Accept 头部中的一项，例如 application/*;q=0.8。
+type mediaRange struct {
+	typ, subtype string
+	params       map[string]string // 不含 q
+	q            float64
+}
+
+// parseAccept 解析 Accept 头部（RFC 9110 §12.5.1），忽略无法解析的项。
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for part := range strings.SplitSeq(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		mt, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := strings.Cut(mt, "/")
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+			delete(params, "q")
+		}
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, params: params, q: q})
+	}
+	return ranges
+}
+
+// quality 返回 offer 在 ranges 下的 q 值，取最具体的匹配项:
+// type/subtype;params > type/subtype > type/* > */*。没有匹配项时为 0。
+func quality(ranges []mediaRange, offer string) float64 {
+	mt, params, err := mime.ParseMediaType(offer)
+	if err != nil {
+		return 0
+	}
+	typ, subtype, _ := strings.Cut(mt, "/")
+
+	best, bestSpecificity := 0.0, -1
+	for _, r := range ranges {
+		specificity := 0
+		switch {
+		case r.typ == "*" && r.subtype == "*":
+		case r.typ == typ && r.subtype == "*":
+			specificity = 1
+		case r.typ == typ && r.subtype == subtype:
+			specificity = 2
+			if len(r.params) > 0 {
+				if !paramsMatch(r.params, params) {
+					continue
+				}
+				specificity = 3
+			}
+		default:
+			continue
+		}
+		if specificity > bestSpecificity {
+			best, bestSpecificity = r.q, specificity
+		}
+	}
+	return best
+}
+
+func paramsMatch(want, have map[string]string) bool {
+	for k, v := range want {
+		if !strings.EqualFold(have[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+// negotiate 按 Accept 从 offers 中选出客户端最偏好的媒体类型，q 值相同时取 offers 中靠前的一个。
+// 没有 Accept 头部时视为接受一切，返回 offers[0]；所有 offer 都不可接受时返回 ""。
+func negotiate(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := quality(ranges, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,45 @@
+package restful
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"application/json", "application/problem+json"}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"application/problem+json", "application/problem+json"},
+		{"application/json", "application/json"},
+		{"application/json;q=0.5, application/problem+json", "application/problem+json"},
+		{"application/*;q=0.2, application/problem+json;q=0.9", "application/problem+json"},
+		{"application/problem+json;q=0, */*", "application/json"},
+		{"text/html", ""},
+		{"text/html, application/*;q=0.1", "application/json"},
+		{"garbage;;, application/problem+json", "application/problem+json"},
+	}
+	for _, tt := range tests {
+		if got := negotiate(tt.accept, offers...); got != tt.want {
+			t.Errorf("negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestQualityPrefersMostSpecificRange(t *testing.T) {
+	ranges := parseAccept("*/*;q=0.1, application/*;q=0.5, application/json;q=0.8, application/json;v=2;q=1")
+	tests := []struct {
+		offer string
+		want  float64
+	}{
+		{"text/plain", 0.1},
+		{"application/xml", 0.5},
+		{"application/json", 0.8},
+		{"application/json;v=2", 1},
+	}
+	for _, tt := range tests {
+		if got := quality(ranges, tt.offer); got != tt.want {
+			t.Errorf("quality(%q) = %v, want %v", tt.offer, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package restful
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ── 路由注册表 ──────────────────────────────────────
+
+// Route 描述一个路由及其契约。路由只在这里声明一次，
+// 既用于注册到 ServeMux，也用于生成 OpenAPI 文档，二者不会各说各话。
+type Route struct {
+	Method      string
+	Pattern     string // 不含方法的路径模式，例如 /api/v1/users/{id}
+	OperationID string
+	Summary     string
+	Auth        bool           // 是否需要认证（Bearer token 或 API Key）
+	Scopes      []string       // 需要的 scope，仅用于文档；授权由 Handler 上的 RequireScopes 执行
+	Params      []Param        // 查询参数和头部参数；路径参数从 Pattern 自动推导
+	Request     map[string]any // Content-Type → 请求体类型的零值
+	Status      int            // 成功状态码
+	Response    any            // 成功响应体类型的零值，nil 表示无响应体
+	ContentType string         // 成功响应的 Content-Type，默认 application/json
+	Handler     http.Handler
+}
+
+// Param 描述一个查询参数或头部参数。
+type Param struct {
+	Name        string
+	In          string // query | header
+	Description string
+	Schema      any // 参数类型的零值，例如 0 或 ""
+}
+
+// Router 是带路由注册表的 ServeMux。
+type Router struct {
+	mux        *http.ServeMux
+	routes     []Route
+	onShutdown []func()
+}
+
+// NewRouter 创建空的 Router。
+func NewRouter() *Router {
+	return &Router{mux: http.NewServeMux()}
+}
+
+// Handle 注册路由，使用 Go 1.22+ 的 "METHOD /path" 模式。
+// 与 ServeMux.Handle 对非法模式的处理一致，请求体类型的 validate tag 有误时直接 panic。
+func (rt *Router) Handle(route Route) {
+	for _, body := range route.Request {
+		if err := CompileRules(body); err != nil {
+			panic(fmt.Sprintf("restful: route %s %s: %v", route.Method, route.Pattern, err))
+		}
+	}
+	rt.routes = append(rt.routes, route)
+	rt.mux.Handle(route.Method+" "+route.Pattern, route.Handler)
+}
+
+// Routes 返回已注册路由的副本。
+func (rt *Router) Routes() []Route {
+	return slices.Clone(rt.routes)
+}
+
+// RegisterOnShutdown 注册服务关闭时调用的函数，Run 把它们交给 http.Server.RegisterOnShutdown。
+// Shutdown 不会中断进行中的请求，SSE 这类长连接需要借此主动结束，否则会拖满整个排空时间。
+func (rt *Router) RegisterOnShutdown(f func()) {
+	rt.onShutdown = append(rt.onShutdown, f)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+// ServeOpenAPI 返回输出 OpenAPI 文档的 handler，每次请求都从注册表实时生成。
+func (rt *Router) ServeOpenAPI(info OpenAPIInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, rt.OpenAPI(info))
+	})
+}
+
+// ── OpenAPI 文档生成 ────────────────────────────────
+
+// OpenAPIInfo 是文档的 info 对象。
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPI 根据注册表生成 OpenAPI 3.1 文档。
+// 请求/响应 schema 由 Go 类型反射得到，validate tag 转换为 JSON Schema 约束。
+func (rt *Router) OpenAPI(info OpenAPIInfo) map[string]any {
+	g := &schemaGen{components: make(map[string]any)}
+	paths := make(map[string]map[string]any)
+
+	errorContent := map[string]any{
+		"application/json":   map[string]any{"schema": g.schema(reflect.TypeFor[ErrorResponse]())},
+		MediaTypeProblemJSON: map[string]any{"schema": g.schema(reflect.TypeFor[Problem]())},
+	}
+
+	for _, route := range rt.routes {
+		op := map[string]any{
+			"operationId": route.OperationID,
+			"summary":     route.Summary,
+		}
+
+		var params []any
+		for _, name := range pathParams(route.Pattern) {
+			params = append(params, map[string]any{
+				"name": name, "in": "path", "required": true,
+				"schema": map[string]any{"type": "string"},
+			})
+		}
+		for _, p := range route.Params {
+			param := map[string]any{
+				"name": p.Name, "in": p.In,
+				"schema": g.schema(reflect.TypeOf(p.Schema)),
+			}
+			if p.Description != "" {
+				param["description"] = p.Description
+			}
+			params = append(params, param)
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		if len(route.Request) > 0 {
+			content := make(map[string]any)
+			for ct, body := range route.Request {
+				content[ct] = map[string]any{"schema": g.schema(reflect.TypeOf(body))}
+			}
+			op["requestBody"] = map[string]any{"required": true, "content": content}
+		}
+
+		success := map[string]any{"description": http.StatusText(route.Status)}
+		if route.Response != nil {
+			ct := route.ContentType
+			if ct == "" {
+				ct = "application/json"
+			}
+			success["content"] = map[string]any{
+				ct: map[string]any{"schema": g.schema(reflect.TypeOf(route.Response))},
+			}
+		}
+		op["responses"] = map[string]any{
+			strconv.Itoa(route.Status): success,
+			"default": map[string]any{
+				"description": "Error",
+				"content":     errorContent,
+			},
+		}
+
+		if route.Auth {
+			scopes := []any{}
+			for _, sc := range route.Scopes {
+				scopes = append(scopes, sc)
+			}
+			op["security"] = []any{
+				map[string]any{"bearerAuth": scopes},
+				map[string]any{"apiKeyAuth": scopes},
+			}
+		}
+
+		if paths[route.Pattern] == nil {
+			paths[route.Pattern] = make(map[string]any)
+		}
+		paths[route.Pattern][strings.ToLower(route.Method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info":    info,
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": g.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKeyAuth": map[string]any{"type": "apiKey", "in": "header", "name": APIKeyHeader},
+			},
+		},
+	}
+}
+
+var pathParamRe = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+func pathParams(pattern string) []string {
+	var names []string
+	for _, m := range pathParamRe.FindAllStringSubmatch(pattern, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// schemaGen 将 Go 类型转换为 JSON Schema（OpenAPI 3.1 与 JSON Schema 2020-12 对齐）。
+// 具名结构体放入 components 并以 $ref 引用。
+type schemaGen struct {
+	components map[string]any
+}
+
+var rawMessageType = reflect.TypeFor[json.RawMessage]()
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := schemaName(t)
+		ref := map[string]any{"$ref": "#/components/schemas/" + name}
+		if _, ok := g.components[name]; !ok {
+			g.components[name] = map[string]any{} // 占位，防止递归类型无限展开
+			g.components[name] = g.structSchema(t)
+		}
+		return ref
+	default:
+		return map[string]any{}
+	}
+}
+
+func (g *schemaGen) structSchema(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := g.schema(f.Type)
+		if rules := f.Tag.Get("validate"); rules != "" && rules != "-" {
+			if applyValidateConstraints(prop, f.Type, rules) {
+				required = append(required, name)
+			}
+		}
+		props[name] = prop
+	}
+
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// applyValidateConstraints 将 validate 规则翻译为 JSON Schema 关键字，返回字段是否必填。
+// dive 之后的规则作用于 items（slice/array）或 additionalProperties（map）。
+// 对 $ref 引用的类型不附加约束。
+func applyValidateConstraints(prop map[string]any, t reflect.Type, rules string) (required bool) {
+	for i, rule := range splitRules(rules) {
+		name, arg, _ := strings.Cut(rule, "=")
+		if name == "dive" {
+			t = indirectType(t)
+			key := "items"
+			if t.Kind() == reflect.Map {
+				key = "additionalProperties"
+			}
+			elem, ok := prop[key].(map[string]any)
+			if !ok {
+				return required
+			}
+			applyValidateConstraints(elem, t.Elem(), strings.Join(splitRules(rules)[i+1:], ","))
+			return required
+		}
+		if name == "required" {
+			required = true
+			continue
+		}
+		if _, isRef := prop["$ref"]; isRef {
+			continue
+		}
+		kind := indirectType(t).Kind()
+
+		switch name {
+		case "email":
+			prop["format"] = "email"
+		case "url":
+			prop["format"] = "uri"
+		case "uuid":
+			prop["format"] = "uuid"
+		case "regexp":
+			prop["pattern"] = arg
+		case "oneof":
+			var enum []any
+			for v := range strings.FieldsSeq(arg) {
+				if n, err := strconv.ParseInt(v, 10, 64); err == nil && kind != reflect.String {
+					enum = append(enum, n)
+				} else {
+					enum = append(enum, v)
+				}
+			}
+			prop["enum"] = enum
+		case "min", "max":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			prop[boundKeyword(name, kind)] = n
+		case "len":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			if isNumberKind(kind) {
+				prop["const"] = n
+			} else {
+				prop[boundKeyword("min", kind)] = n
+				prop[boundKeyword("max", kind)] = n
+			}
+		}
+	}
+	return required
+}
+
+// boundKeyword 返回 min/max 在不同类型上对应的 JSON Schema 关键字，
+// 与 Validate 的语义一致: 字符串限制长度，集合限制元素个数，数值限制取值。
+func boundKeyword(rule string, kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return rule + "Length"
+	case reflect.Slice, reflect.Array:
+		return rule + "Items"
+	case reflect.Map:
+		return rule + "Properties"
+	default:
+		return rule + "imum"
+	}
+}
+
+// schemaName 生成组件名。泛型类型实例化后的名字带完整包路径，例如
+// Response[go-notes/.../restful.User]，这里转换为 Response_User；切片参数转换为 XxxList。
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	base, args, ok := strings.Cut(name, "[")
+	if !ok {
+		return name
+	}
+	args = strings.TrimSuffix(args, "]")
+
+	parts := []string{base}
+	for arg := range strings.SplitSeq(args, ",") {
+		list := strings.HasPrefix(arg, "[]")
+		arg = strings.TrimPrefix(arg, "[]")
+		if i := strings.LastIndex(arg, "."); i >= 0 {
+			arg = arg[i+1:]
+		}
+		if list {
+			arg += "List"
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, "_")
+}
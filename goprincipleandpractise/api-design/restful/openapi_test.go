@@ -0,0 +1,167 @@
+package restful
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite testdata golden files")
+
+func fetchOpenAPI(t *testing.T) []byte {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	NewServer().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json: status %d", rec.Code)
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, rec.Body.Bytes(), "", "  "); err != nil {
+		t.Fatalf("spec is not valid JSON: %v", err)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// TestOpenAPIGolden 将生成的文档与 testdata/openapi.json 比对，路由或类型变化会使测试失败。
+// 确认变化符合预期后，用 go test -run TestOpenAPIGolden -update 更新。
+func TestOpenAPIGolden(t *testing.T) {
+	got := fetchOpenAPI(t)
+	golden := filepath.Join("testdata", "openapi.json")
+
+	if *updateGolden {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("read golden (run with -update to create): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("OpenAPI spec drifted from %s; run go test -run TestOpenAPIGolden -update and review the diff", golden)
+	}
+}
+
+// TestOpenAPIMatchesRoutes 验证文档中的每个操作都真实存在，且每个注册的路由都出现在文档中。
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(fetchOpenAPI(t), &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewServer()
+	// 已取消的 context 让长连接路由（SSE）写出响应头后立即返回。
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	ops := 0
+	for path, methods := range spec.Paths {
+		for method := range methods {
+			ops++
+			url := strings.ReplaceAll(path, "{id}", "1")
+			req := httptest.NewRequestWithContext(ctx, strings.ToUpper(method), url, nil)
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			// 404 也可能来自 handler（用户不存在），所以用错误响应体区分：路由缺失时 ServeMux 返回纯文本。
+			if rec.Code == http.StatusMethodNotAllowed ||
+				(rec.Code == http.StatusNotFound && !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json")) {
+				t.Errorf("%s %s documented but not routed (status %d)", method, path, rec.Code)
+			}
+		}
+	}
+
+	rt, ok := srv.(*Router)
+	if !ok {
+		t.Fatalf("NewServer returned %T, want *Router", srv)
+	}
+	if ops != len(rt.Routes()) {
+		t.Errorf("spec has %d operations, router has %d routes", ops, len(rt.Routes()))
+	}
+}
+
+func TestOpenAPIValidateConstraints(t *testing.T) {
+	var spec struct {
+		Components struct {
+			Schemas map[string]struct {
+				Required   []string                  `json:"required"`
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(fetchOpenAPI(t), &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	create, ok := spec.Components.Schemas["CreateUserRequest"]
+	if !ok {
+		t.Fatal("CreateUserRequest schema missing")
+	}
+	if !slices.Contains(create.Required, "name") || !slices.Contains(create.Required, "email") {
+		t.Errorf("required = %v, want name and email", create.Required)
+	}
+	if slices.Contains(create.Required, "age") {
+		t.Errorf("age is optional but listed as required")
+	}
+
+	name := create.Properties["name"]
+	if name["minLength"] != 2.0 || name["maxLength"] != 50.0 {
+		t.Errorf("name = %v, want minLength 2 maxLength 50", name)
+	}
+	if create.Properties["email"]["format"] != "email" {
+		t.Errorf("email = %v, want format email", create.Properties["email"])
+	}
+	age := create.Properties["age"]
+	if age["minimum"] != 0.0 || age["maximum"] != 150.0 {
+		t.Errorf("age = %v, want minimum 0 maximum 150", age)
+	}
+}
+
+func TestOpenAPIRuleMapping(t *testing.T) {
+	g := &schemaGen{components: make(map[string]any)}
+	g.schema(reflect.TypeFor[testOrder]())
+	props := g.components["testOrder"].(map[string]any)["properties"].(map[string]any)
+	prop := func(name string) map[string]any { return props[name].(map[string]any) }
+
+	checks := []struct {
+		field, key string
+		want       any
+	}{
+		{"id", "format", "uuid"},
+		{"callback", "format", "uri"},
+		{"code", "pattern", "^[A-Z]{2,3}$"},
+		{"price", "minimum", 0.01},
+		{"tags", "maxItems", 3.0},
+	}
+	for _, c := range checks {
+		if got := prop(c.field)[c.key]; got != c.want {
+			t.Errorf("%s.%s = %v, want %v", c.field, c.key, got, c.want)
+		}
+	}
+	if got := prop("status")["enum"]; !reflect.DeepEqual(got, []any{"pending", "paid", "shipped"}) {
+		t.Errorf("status.enum = %v", got)
+	}
+	if got := prop("priority")["enum"]; !reflect.DeepEqual(got, []any{int64(1), int64(2), int64(3)}) {
+		t.Errorf("priority.enum = %v", got)
+	}
+	if got := prop("tags")["items"].(map[string]any)["minLength"]; got != 2.0 {
+		t.Errorf("tags.items.minLength = %v, want 2", got)
+	}
+	if got := prop("labels")["additionalProperties"].(map[string]any)["minimum"]; got != 0.0 {
+		t.Errorf("labels.additionalProperties.minimum = %v, want 0", got)
+	}
+}
@@ -0,0 +1,334 @@
+package restful
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PATCH 支持的两种媒体类型。
+const (
+	MediaTypeMergePatch = "application/merge-patch+json" // RFC 7386
+	MediaTypeJSONPatch  = "application/json-patch+json"  // RFC 6902
+)
+
+// ApplyMergePatch 按 RFC 7386 将 patch 合并到 doc:
+//   - patch 中的对象递归合并
+//   - 值为 null 表示删除该成员
+//   - 其他值（包括数组）整体替换
+//
+// patch 不是对象时直接替换整个文档。
+func ApplyMergePatch(doc, patch []byte) ([]byte, error) {
+	var target, p any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, fmt.Errorf("merge patch: invalid document: %w", err)
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("merge patch: invalid patch: %w", err)
+	}
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = make(map[string]any)
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+			continue
+		}
+		targetObj[k] = mergePatch(targetObj[k], v)
+	}
+	return targetObj
+}
+
+// JSONPatchOp 是 RFC 6902 中的单个操作。
+type JSONPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// PatchTestFailedError 表示 JSON Patch 的 test 操作未通过，
+// 通常意味着客户端基于过期的资源状态生成了 patch。
+type PatchTestFailedError struct {
+	Path string
+}
+
+func (e *PatchTestFailedError) Error() string {
+	return fmt.Sprintf("json patch: test failed at %q", e.Path)
+}
+
+// ApplyJSONPatch 按 RFC 6902 依次执行 ops，任一操作失败则整体失败（原子性）。
+// 支持 add、remove、replace、move、copy、test 六种操作。
+func ApplyJSONPatch(doc, patch []byte) ([]byte, error) {
+	var ops []JSONPatchOp
+	dec := json.NewDecoder(bytes.NewReader(patch))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&ops); err != nil {
+		return nil, fmt.Errorf("json patch: patch must be an array of operations: %w", err)
+	}
+
+	var root any
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return nil, fmt.Errorf("json patch: invalid document: %w", err)
+	}
+
+	for i, op := range ops {
+		var err error
+		root, err = applyOp(root, op)
+		if err != nil {
+			return nil, fmt.Errorf("json patch: operation %d (%s): %w", i, op.Op, err)
+		}
+	}
+	return json.Marshal(root)
+}
+
+func applyOp(root any, op JSONPatchOp) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	decodeValue := func() (any, error) {
+		if op.Value == nil {
+			return nil, fmt.Errorf("missing value")
+		}
+		var v any
+		if err := json.Unmarshal(op.Value, &v); err != nil {
+			return nil, fmt.Errorf("invalid value: %w", err)
+		}
+		return v, nil
+	}
+
+	switch op.Op {
+	case "add":
+		v, err := decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		return addAt(root, path, v)
+	case "remove":
+		root, _, err := removeAt(root, path)
+		return root, err
+	case "replace":
+		v, err := decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		if root, _, err = removeAt(root, path); err != nil {
+			return nil, err
+		}
+		return addAt(root, path, v)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" && isPrefix(from, path) && len(from) < len(path) {
+			return nil, fmt.Errorf("cannot move a value into one of its children")
+		}
+		v, err := getAt(root, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if root, _, err = removeAt(root, from); err != nil {
+				return nil, err
+			}
+		} else {
+			v = deepCopy(v)
+		}
+		return addAt(root, path, v)
+	case "test":
+		want, err := decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		got, err := getAt(root, path)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			return nil, &PatchTestFailedError{Path: op.Path}
+		}
+		return root, nil
+	default:
+		return nil, fmt.Errorf("unknown op %q", op.Op)
+	}
+}
+
+// parsePointer 解析 RFC 6901 JSON Pointer，"" 表示整个文档。
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		// 顺序不能反: 先 ~1 再 ~0，否则 "~01" 会被错误解码为 "/"。
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func getAt(node any, path []string) (any, error) {
+	for _, tok := range path {
+		switch n := node.(type) {
+		case map[string]any:
+			v, ok := n[tok]
+			if !ok {
+				return nil, fmt.Errorf("path member %q not found", tok)
+			}
+			node = v
+		case []any:
+			i, err := arrayIndex(tok, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("cannot traverse into scalar at %q", tok)
+		}
+	}
+	return node, nil
+}
+
+// addAt 在 path 处插入 value 并返回新的根。数组中间插入会后移元素，"-" 表示追加。
+func addAt(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := getAt(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch p := parent.(type) {
+	case map[string]any:
+		p[last] = value
+		return root, nil
+	case []any:
+		i := len(p)
+		if last != "-" {
+			if i, err = arrayIndex(last, len(p)); err != nil {
+				return nil, err
+			}
+		}
+		p = append(p, nil)
+		copy(p[i+1:], p[i:])
+		p[i] = value
+		return setAt(root, path[:len(path)-1], p)
+	default:
+		return nil, fmt.Errorf("cannot add member to scalar")
+	}
+}
+
+// removeAt 删除 path 处的值，返回新的根和被删除的值。
+func removeAt(root any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, root, nil
+	}
+	parent, err := getAt(root, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+
+	switch p := parent.(type) {
+	case map[string]any:
+		v, ok := p[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("path member %q not found", last)
+		}
+		delete(p, last)
+		return root, v, nil
+	case []any:
+		i, err := arrayIndex(last, len(p)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		v := p[i]
+		p = append(p[:i:i], p[i+1:]...)
+		root, err = setAt(root, path[:len(path)-1], p)
+		return root, v, err
+	default:
+		return nil, nil, fmt.Errorf("cannot remove member of scalar")
+	}
+}
+
+// setAt 用 value 替换 path 处已存在的值。切片扩缩容后需要写回父节点。
+func setAt(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := getAt(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		p[last] = value
+	case []any:
+		i, err := arrayIndex(last, len(p)-1)
+		if err != nil {
+			return nil, err
+		}
+		p[i] = value
+	}
+	return root, nil
+}
+
+// arrayIndex 解析数组下标，拒绝前导零和越界（maxIndex 为允许的最大下标）。
+func arrayIndex(tok string, maxIndex int) (int, error) {
+	if tok == "" || (len(tok) > 1 && tok[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", tok)
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i < 0 || i > maxIndex {
+		return 0, fmt.Errorf("array index %q out of range", tok)
+	}
+	return i, nil
+}
+
+func deepCopy(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[k] = deepCopy(e)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, e := range v {
+			s[i] = deepCopy(e)
+		}
+		return s
+	default:
+		return v
+	}
+}
@@ -0,0 +1,258 @@
+package restful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func jsonEqual(t *testing.T, got []byte, want string) bool {
+	t.Helper()
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("unmarshal got: %v", err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("unmarshal want: %v", err)
+	}
+	return reflect.DeepEqual(g, w)
+}
+
+// 用例取自 RFC 7386 附录 A。
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.patch, func(t *testing.T) {
+			got, err := ApplyMergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("ApplyMergePatch: %v", err)
+			}
+			if !jsonEqual(t, got, tt.want) {
+				t.Errorf("ApplyMergePatch(%s, %s) = %s, want %s", tt.doc, tt.patch, got, tt.want)
+			}
+		})
+	}
+}
+
+// 用例取自 RFC 6902 附录 A。
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		name, doc, patch, want string
+		wantErr                bool
+	}{
+		{"add object member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, false},
+		{"add array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, false},
+		{"append to array", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`, false},
+		{"remove object member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, false},
+		{"remove array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, false},
+		{"replace value", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, false},
+		{"move value", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, false},
+		{"move array element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`, false},
+		{"copy value", `{"foo":{"a":1}}`, `[{"op":"copy","from":"/foo","path":"/bar"}]`, `{"foo":{"a":1},"bar":{"a":1}}`, false},
+		{"test success", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`, false},
+		{"escaped pointer", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`, false},
+		{"test failure", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, "", true},
+		{"add to nonexistent target", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, "", true},
+		{"remove missing member", `{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, "", true},
+		{"array index out of range", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/5","value":1}]`, "", true},
+		{"leading zero index", `{"foo":["a","b"]}`, `[{"op":"remove","path":"/foo/01"}]`, "", true},
+		{"unknown op", `{}`, `[{"op":"frobnicate","path":"/a"}]`, "", true},
+		{"move into own child", `{"a":{"b":{}}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`, "", true},
+		{"failed op leaves no partial result", `{"a":1}`, `[{"op":"add","path":"/b","value":2},{"op":"remove","path":"/c"}]`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyJSONPatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyJSONPatch: %v", err)
+			}
+			if !jsonEqual(t, got, tt.want) {
+				t.Errorf("ApplyJSONPatch = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchUserHTTP(t *testing.T) {
+	srv := NewServer()
+	auth := "Bearer demo-token"
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users",
+		strings.NewReader(`{"name":"Alice","email":"alice@example.com","age":30}`))
+	req.Header.Set("Authorization", auth)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	var created Response[User]
+	_ = json.NewDecoder(rec.Body).Decode(&created)
+	path := "/api/v1/users/" + created.Data.ID
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantCode    ErrCode
+		check       func(t *testing.T, u User)
+	}{
+		{
+			name:        "merge patch clears age with null",
+			contentType: MediaTypeMergePatch,
+			body:        `{"age":null}`,
+			wantStatus:  http.StatusOK,
+			check: func(t *testing.T, u User) {
+				if u.Age != 0 || u.Name != "Alice" {
+					t.Errorf("got %+v, want age cleared and name kept", u)
+				}
+			},
+		},
+		{
+			name:        "json patch with passing test op",
+			contentType: MediaTypeJSONPatch + "; charset=utf-8",
+			body:        `[{"op":"test","path":"/name","value":"Alice"},{"op":"replace","path":"/name","value":"Alicia"}]`,
+			wantStatus:  http.StatusOK,
+			check: func(t *testing.T, u User) {
+				if u.Name != "Alicia" || u.Email != "alice@example.com" {
+					t.Errorf("got %+v, want name replaced and email kept", u)
+				}
+			},
+		},
+		{
+			name:        "json patch test failure",
+			contentType: MediaTypeJSONPatch,
+			body:        `[{"op":"test","path":"/name","value":"Stale"},{"op":"replace","path":"/name","value":"Bob"}]`,
+			wantStatus:  http.StatusConflict,
+			wantCode:    ErrConflict,
+		},
+		{
+			name:        "patched resource is revalidated",
+			contentType: MediaTypeMergePatch,
+			body:        `{"email":null}`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    ErrValidationFailed,
+		},
+		{
+			name:        "unknown field is rejected",
+			contentType: MediaTypeMergePatch,
+			body:        `{"role":"admin"}`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    ErrInvalidPatch,
+		},
+		{
+			name:        "json patch on missing path",
+			contentType: MediaTypeJSONPatch,
+			body:        `[{"op":"remove","path":"/nickname"}]`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    ErrInvalidPatch,
+		},
+		{
+			name:        "plain json is not a patch format",
+			contentType: "application/json",
+			body:        `{"name":"Bob"}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantCode:    ErrUnsupportedMedia,
+		},
+		{
+			name:        "malformed patch body",
+			contentType: MediaTypeMergePatch,
+			body:        `{not json}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    ErrInvalidJSON,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", auth)
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status: got %d, want %d; body: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.check != nil {
+				var resp Response[User]
+				if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				tt.check(t, resp.Data)
+				return
+			}
+			var errResp ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&errResp); err != nil {
+				t.Fatalf("decode error response: %v", err)
+			}
+			if errResp.Error.Code != tt.wantCode {
+				t.Errorf("error code: got %s, want %s", errResp.Error.Code, tt.wantCode)
+			}
+			if tt.wantStatus == http.StatusUnsupportedMediaType && rec.Header().Get("Accept-Patch") == "" {
+				t.Error("415 response should advertise Accept-Patch")
+			}
+		})
+	}
+}
+
+func TestPutIsFullReplacement(t *testing.T) {
+	srv := NewServer()
+	auth := "Bearer demo-token"
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users",
+		strings.NewReader(`{"name":"Alice","email":"alice@example.com","age":30}`))
+	req.Header.Set("Authorization", auth)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	var created Response[User]
+	_ = json.NewDecoder(rec.Body).Decode(&created)
+
+	// 缺省 age → 置零，而不是保留旧值。
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/users/"+created.Data.ID,
+		strings.NewReader(`{"name":"Alice","email":"alice@example.com"}`))
+	req.Header.Set("Authorization", auth)
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("put: expected 200, got %d; body: %s", rec.Code, rec.Body.String())
+	}
+	var updated Response[User]
+	_ = json.NewDecoder(rec.Body).Decode(&updated)
+	if updated.Data.Age != 0 {
+		t.Errorf("put: age = %d, want 0 after full replacement", updated.Data.Age)
+	}
+
+	// 缺少必填字段 → 422。
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/users/"+created.Data.ID,
+		strings.NewReader(`{"name":"Alice"}`))
+	req.Header.Set("Authorization", auth)
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("put partial: expected 422, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package restful
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// MediaTypeProblemJSON 是 RFC 9457 Problem Details 的媒体类型。
+const MediaTypeProblemJSON = "application/problem+json"
+
+// problemTypePrefix 是 problem type URI 的前缀，后接 ErrCode，例如 /problems/not_found。
+// RFC 9457 允许相对 URI，客户端按请求 URL 解析即可。
+const problemTypePrefix = "/problems/"
+
+// Problem 是 RFC 9457 Problem Details 响应体。
+// code 和 fields 是扩展成员，与 ErrorResponse 中的同名字段含义一致，便于客户端迁移。
+type Problem struct {
+	Type     string           `json:"type"`
+	Title    string           `json:"title"`
+	Status   int              `json:"status"`
+	Detail   string           `json:"detail,omitempty"`
+	Instance string           `json:"instance,omitempty"`
+	Code     ErrCode          `json:"code"`
+	Fields   ValidationErrors `json:"fields,omitempty"`
+}
+
+// ErrorFormat 是错误响应体的格式。
+type ErrorFormat int
+
+const (
+	// ErrorFormatEnvelope 是 ErrorResponse 信封（application/json），默认格式。
+	ErrorFormatEnvelope ErrorFormat = iota
+	// ErrorFormatProblem 是 RFC 9457 Problem Details（application/problem+json）。
+	ErrorFormatProblem
+)
+
+type errorFormatKey struct{}
+
+// DefaultErrorFormat 返回中间件，设置该请求链上错误响应的默认格式。
+//
+// 实际格式由 Accept 协商: 客户端更偏好 application/problem+json 时输出 Problem，
+// 更偏好 application/json 时输出信封，二者无差别（没有 Accept、*/* 等）时使用 format。
+// 因此把默认值切到 ErrorFormatProblem 不会影响显式声明 Accept: application/json 的老客户端。
+func DefaultErrorFormat(format ErrorFormat) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), errorFormatKey{}, format)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// errorFormatFor 返回 r 应使用的错误格式。
+func errorFormatFor(r *http.Request) ErrorFormat {
+	if r == nil {
+		return ErrorFormatEnvelope
+	}
+	def, _ := r.Context().Value(errorFormatKey{}).(ErrorFormat)
+	offers := []string{"application/json", MediaTypeProblemJSON}
+	if def == ErrorFormatProblem {
+		offers[0], offers[1] = offers[1], offers[0]
+	}
+	switch negotiate(r.Header.Get("Accept"), offers...) {
+	case MediaTypeProblemJSON:
+		return ErrorFormatProblem
+	case "application/json":
+		return ErrorFormatEnvelope
+	default:
+		return def
+	}
+}
+
+// writeProblem 以 application/problem+json 写出错误。
+// detail 为面向用户的（本地化）消息，AppError.Detail 附在其后。
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code ErrCode, message, detail string, fields ValidationErrors) {
+	if detail != "" {
+		message += ": " + detail
+	}
+	p := Problem{
+		Type:   problemTypePrefix + string(code),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: message,
+		Code:   code,
+		Fields: fields,
+	}
+	if r != nil {
+		p.Instance = r.URL.Path
+	}
+	w.Header().Set("Content-Type", MediaTypeProblemJSON)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(p)
+}
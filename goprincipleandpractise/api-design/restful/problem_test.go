@@ -0,0 +1,94 @@
+package restful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProblemDetails(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      ErrorFormat
+		accept      string
+		wantProblem bool
+	}{
+		{"default envelope", ErrorFormatEnvelope, "", false},
+		{"client asks for problem", ErrorFormatEnvelope, "application/problem+json", true},
+		{"client prefers problem", ErrorFormatEnvelope, "application/json;q=0.5, application/problem+json", true},
+		{"server default problem", ErrorFormatProblem, "", true},
+		{"server default problem, wildcard", ErrorFormatProblem, "*/*", true},
+		// 显式要求 application/json 的老客户端不受服务端默认值影响。
+		{"server default problem, legacy client", ErrorFormatProblem, "application/json", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(WithErrorFormat(tt.format))
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/users/missing", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			if !strings.Contains(strings.Join(rec.Header().Values("Vary"), ","), "Accept") {
+				t.Errorf("Vary = %v, want Accept", rec.Header().Values("Vary"))
+			}
+			ct := rec.Header().Get("Content-Type")
+			if got := ct == MediaTypeProblemJSON; got != tt.wantProblem {
+				t.Fatalf("Content-Type = %q, wantProblem %v", ct, tt.wantProblem)
+			}
+			if !tt.wantProblem {
+				var resp ErrorResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Error.Code != ErrNotFound {
+					t.Fatalf("envelope = %s (%v)", rec.Body, err)
+				}
+				return
+			}
+
+			var p Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			want := Problem{
+				Type:     "/problems/not_found",
+				Title:    "Not Found",
+				Status:   http.StatusNotFound,
+				Detail:   "user not found",
+				Instance: "/api/v1/users/missing",
+				Code:     ErrNotFound,
+			}
+			if p.Type != want.Type || p.Title != want.Title || p.Status != want.Status ||
+				p.Detail != want.Detail || p.Instance != want.Instance || p.Code != want.Code {
+				t.Errorf("problem = %+v, want %+v", p, want)
+			}
+		})
+	}
+}
+
+func TestProblemDetailsValidation(t *testing.T) {
+	srv := NewServer()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(`{"name":"A","email":"a@example.com"}`))
+	req.Header.Set("Authorization", "Bearer demo-token")
+	req.Header.Set("Accept", MediaTypeProblemJSON)
+	req.Header.Set("Accept-Language", "zh")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	var p Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != http.StatusUnprocessableEntity || p.Code != ErrValidationFailed || p.Detail != "请求参数校验失败" {
+		t.Errorf("problem = %+v", p)
+	}
+	if fe := p.Fields["name"]; fe.Rule != "min" || fe.Message != "name 至少 2 个字符" {
+		t.Errorf("fields = %+v", p.Fields)
+	}
+}
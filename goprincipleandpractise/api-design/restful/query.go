@@ -0,0 +1,309 @@
+package restful
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// ListQuery 描述列表查询: 过滤、排序和分页条件。
+//
+// 分页支持两种互斥模式:
+//   - offset 分页: Offset + Limit，适合跳页，但深分页代价高且数据变动时会重复/遗漏
+//   - 游标分页:    After + Limit，基于排序键做 keyset 查询，翻页结果稳定
+type ListQuery struct {
+	Email      string    // 精确匹配
+	NamePrefix string    // 名称前缀，区分大小写
+	Sort       []SortKey // 已规范化，末尾总是 id 作为唯一的决胜键；为空时使用默认排序
+	Limit      int       // <= 0 表示不限制
+	Offset     int
+	After      []any // 上一页最后一条记录的排序键值，与 Sort 一一对应
+}
+
+// SortKey 是单个排序字段。
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// UserPage 是一次列表查询的结果。
+type UserPage struct {
+	Users   []User
+	Total   int  // 满足过滤条件的总数（不受分页影响）
+	HasMore bool // 当前页之后是否还有数据
+}
+
+// sortField 描述一个可排序字段: SQL 列名和从 User 中取排序键的方法。
+// 排序键只有 string 和 int64 两种类型，时间统一转为 Unix 纳秒。
+type sortField struct {
+	column  string
+	numeric bool
+	key     func(User) any
+}
+
+var userSortFields = map[string]sortField{
+	"id":         {column: "id", key: func(u User) any { return u.ID }},
+	"name":       {column: "name", key: func(u User) any { return u.Name }},
+	"email":      {column: "email", key: func(u User) any { return u.Email }},
+	"age":        {column: "age", numeric: true, key: func(u User) any { return int64(u.Age) }},
+	"created_at": {column: "created_at", numeric: true, key: func(u User) any { return u.CreatedAt.UnixNano() }},
+	"updated_at": {column: "updated_at", numeric: true, key: func(u User) any { return u.UpdatedAt.UnixNano() }},
+}
+
+var defaultSort = []SortKey{{Field: "created_at"}, {Field: "id"}}
+
+// ParseListQuery 从 URL 查询参数解析 ListQuery。
+//
+// 支持的参数:
+//
+//	?limit=20&offset=40           offset 分页
+//	?limit=20&cursor=<opaque>     游标分页（与 offset 互斥）
+//	?sort=created_at,-name        排序，"-" 前缀表示降序
+//	?email=a@b.com&name_prefix=Al 过滤
+//
+// 返回的 error 为 *AppError（ErrInvalidQuery）。
+func ParseListQuery(values url.Values) (ListQuery, error) {
+	q := ListQuery{
+		Email:      values.Get("email"),
+		NamePrefix: values.Get("name_prefix"),
+		Limit:      defaultPageLimit,
+	}
+
+	if s := values.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxPageLimit {
+			return ListQuery{}, invalidQuery("limit", fmt.Sprintf("limit must be between 1 and %d", maxPageLimit))
+		}
+		q.Limit = n
+	}
+	if s := values.Get("offset"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return ListQuery{}, invalidQuery("offset", "offset must be a non-negative integer")
+		}
+		q.Offset = n
+	}
+
+	sort, err := parseSort(values.Get("sort"))
+	if err != nil {
+		return ListQuery{}, err
+	}
+	q.Sort = sort
+
+	if token := values.Get("cursor"); token != "" {
+		if values.Has("offset") {
+			return ListQuery{}, invalidQuery("cursor", "cursor and offset are mutually exclusive")
+		}
+		after, err := decodeCursor(token, q.Sort)
+		if err != nil {
+			return ListQuery{}, err
+		}
+		q.After = after
+	}
+	return q, nil
+}
+
+func parseSort(s string) ([]SortKey, error) {
+	if s == "" {
+		return slices.Clone(defaultSort), nil
+	}
+	var keys []SortKey
+	seen := make(map[string]bool)
+	for part := range strings.SplitSeq(s, ",") {
+		key := SortKey{Field: strings.TrimSpace(part)}
+		if rest, ok := strings.CutPrefix(key.Field, "-"); ok {
+			key = SortKey{Field: rest, Desc: true}
+		}
+		if _, ok := userSortFields[key.Field]; !ok {
+			return nil, invalidQuery("sort", fmt.Sprintf("unknown sort field %q", key.Field))
+		}
+		if seen[key.Field] {
+			return nil, invalidQuery("sort", fmt.Sprintf("duplicate sort field %q", key.Field))
+		}
+		seen[key.Field] = true
+		keys = append(keys, key)
+	}
+	// id 唯一，作为最后的决胜键保证顺序确定，游标才不会跳过或重复记录。
+	if !seen["id"] {
+		keys = append(keys, SortKey{Field: "id"})
+	}
+	return keys, nil
+}
+
+// formatSort 是 parseSort 的逆操作，用于游标校验和生成翻页链接。
+func formatSort(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k.Field
+		if k.Desc {
+			parts[i] = "-" + k.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func invalidQuery(field, msg string) *AppError {
+	return NewAppError(ErrInvalidQuery, "invalid query parameters", nil).WithDetail(field + ": " + msg)
+}
+
+// sortKeyValues 返回 u 在 keys 下的排序键值。
+func sortKeyValues(u User, keys []SortKey) []any {
+	vals := make([]any, len(keys))
+	for i, k := range keys {
+		vals[i] = userSortFields[k.Field].key(u)
+	}
+	return vals
+}
+
+// compareSortKeys 按 keys 的方向逐个比较两组排序键值。
+func compareSortKeys(a, b []any, keys []SortKey) int {
+	for i, k := range keys {
+		var c int
+		switch av := a[i].(type) {
+		case int64:
+			c = cmp.Compare(av, b[i].(int64))
+		case string:
+			c = cmp.Compare(av, b[i].(string))
+		}
+		if k.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// cursorPayload 是游标的内部结构。游标对客户端不透明，
+// 携带排序规则是为了拒绝在更换 sort 后继续使用旧游标。
+type cursorPayload struct {
+	Sort  string   `json:"s"`
+	After []string `json:"a"`
+}
+
+func encodeCursor(u User, keys []SortKey) string {
+	p := cursorPayload{Sort: formatSort(keys)}
+	for _, v := range sortKeyValues(u, keys) {
+		switch v := v.(type) {
+		case int64:
+			p.After = append(p.After, strconv.FormatInt(v, 10))
+		case string:
+			p.After = append(p.After, v)
+		}
+	}
+	data, _ := json.Marshal(p)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string, keys []SortKey) ([]any, error) {
+	errInvalid := invalidQuery("cursor", "cursor is malformed or does not match sort")
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errInvalid
+	}
+	var p cursorPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, errInvalid
+	}
+	if p.Sort != formatSort(keys) || len(p.After) != len(keys) {
+		return nil, errInvalid
+	}
+
+	after := make([]any, len(keys))
+	for i, k := range keys {
+		if !userSortFields[k.Field].numeric {
+			after[i] = p.After[i]
+			continue
+		}
+		n, err := strconv.ParseInt(p.After[i], 10, 64)
+		if err != nil {
+			return nil, errInvalid
+		}
+		after[i] = n
+	}
+	return after, nil
+}
+
+// matches 判断 u 是否满足 q 的过滤条件。
+func (q ListQuery) matches(u User) bool {
+	if q.Email != "" && u.Email != q.Email {
+		return false
+	}
+	if q.NamePrefix != "" && !strings.HasPrefix(u.Name, q.NamePrefix) {
+		return false
+	}
+	return true
+}
+
+// sortKeys 返回实际生效的排序键。
+func (q ListQuery) sortKeys() []SortKey {
+	if len(q.Sort) == 0 {
+		return defaultSort
+	}
+	return q.Sort
+}
+
+// paginate 对已按 q.sortKeys() 排好序的 users 应用游标/offset 和 limit。
+// InMemoryUserStore 直接使用；SQL 实现则把同样的语义下推到查询中。
+func (q ListQuery) paginate(users []User) UserPage {
+	keys := q.sortKeys()
+	page := UserPage{Total: len(users)}
+	if q.After != nil {
+		i, _ := slices.BinarySearchFunc(users, q.After, func(u User, after []any) int {
+			if compareSortKeys(sortKeyValues(u, keys), after, keys) <= 0 {
+				return -1
+			}
+			return 1
+		})
+		users = users[i:]
+	}
+	users = users[min(q.Offset, len(users)):]
+	if q.Limit > 0 && len(users) > q.Limit {
+		users = users[:q.Limit]
+		page.HasMore = true
+	}
+	page.Users = users
+	return page
+}
+
+// paginationLinks 生成 Link 头部: first 总是存在；offset 模式有 prev/next，
+// 游标模式只有 next（游标只能前进）。其他查询参数原样保留。
+func paginationLinks(u *url.URL, q ListQuery, page UserPage, nextCursor string) string {
+	link := func(rel string, set map[string]string) string {
+		values := u.Query()
+		values.Del("cursor")
+		values.Del("offset")
+		for k, v := range set {
+			values.Set(k, v)
+		}
+		ref := url.URL{Path: u.Path, RawQuery: values.Encode()}
+		return fmt.Sprintf("<%s>; rel=%q", ref.String(), rel)
+	}
+
+	links := []string{link("first", nil)}
+	if q.After == nil {
+		if q.Offset > 0 {
+			prev := max(q.Offset-q.Limit, 0)
+			links = append(links, link("prev", map[string]string{"offset": strconv.Itoa(prev)}))
+		}
+		if page.HasMore {
+			next := q.Offset + q.Limit
+			links = append(links, link("next", map[string]string{"offset": strconv.Itoa(next)}))
+		}
+	} else if nextCursor != "" {
+		links = append(links, link("next", map[string]string{"cursor": nextCursor}))
+	}
+	return strings.Join(links, ", ")
+}
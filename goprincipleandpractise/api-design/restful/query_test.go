@@ -0,0 +1,187 @@
+package restful
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func seedUsers(t *testing.T, store UserStore) {
+	t.Helper()
+	seed := []User{
+		{Name: "Alice", Email: "alice@example.com", Age: 30},
+		{Name: "Alan", Email: "alan@example.com", Age: 25},
+		{Name: "Bob", Email: "bob@example.com", Age: 30},
+		{Name: "alex", Email: "alex@example.com", Age: 40},
+		{Name: "Carol", Email: "carol@example.com", Age: 20},
+	}
+	for _, u := range seed {
+		if _, err := store.Create(context.Background(), u); err != nil {
+			t.Fatalf("seed %s: %v", u.Name, err)
+		}
+	}
+}
+
+func names(users []User) []string {
+	out := make([]string, len(users))
+	for i, u := range users {
+		out[i] = u.Name
+	}
+	return out
+}
+
+func TestUserStoreListContract(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"default sort is creation order", "", []string{"Alice", "Alan", "Bob", "alex", "Carol"}},
+		{"sort by age desc then name", "sort=-age,name", []string{"alex", "Alice", "Bob", "Alan", "Carol"}},
+		{"name prefix is case sensitive", "name_prefix=Al", []string{"Alice", "Alan"}},
+		{"email exact match", "email=bob@example.com", []string{"Bob"}},
+		{"offset and limit", "sort=name&limit=2&offset=2", []string{"Bob", "Carol"}},
+	}
+
+	for storeName, newStore := range userStoreFactories {
+		t.Run(storeName, func(t *testing.T) {
+			store := newStore(t)
+			seedUsers(t, store)
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					values, _ := url.ParseQuery(tt.query)
+					q, err := ParseListQuery(values)
+					if err != nil {
+						t.Fatalf("ParseListQuery: %v", err)
+					}
+					page, err := store.List(context.Background(), q)
+					if err != nil {
+						t.Fatalf("List: %v", err)
+					}
+					if got := names(page.Users); !slices.Equal(got, tt.want) {
+						t.Errorf("List(%q) = %v, want %v", tt.query, got, tt.want)
+					}
+				})
+			}
+
+			// 游标翻页应按排序完整遍历，且不重复不遗漏。
+			q, _ := ParseListQuery(url.Values{"sort": {"-age,name"}, "limit": {"2"}})
+			var got []string
+			for range 10 {
+				page, err := store.List(context.Background(), q)
+				if err != nil {
+					t.Fatalf("List: %v", err)
+				}
+				got = append(got, names(page.Users)...)
+				if !page.HasMore {
+					break
+				}
+				token := encodeCursor(page.Users[len(page.Users)-1], q.Sort)
+				if q.After, err = decodeCursor(token, q.Sort); err != nil {
+					t.Fatalf("decodeCursor: %v", err)
+				}
+			}
+			want := []string{"alex", "Alice", "Bob", "Alan", "Carol"}
+			if !slices.Equal(got, want) {
+				t.Errorf("cursor walk = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseListQueryErrors(t *testing.T) {
+	otherSortCursor := encodeCursor(User{ID: "usr_000001", Name: "A"}, []SortKey{{Field: "name"}, {Field: "id"}})
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"limit zero", "limit=0"},
+		{"limit too large", "limit=101"},
+		{"limit not a number", "limit=ten"},
+		{"negative offset", "offset=-1"},
+		{"unknown sort field", "sort=password"},
+		{"duplicate sort field", "sort=name,-name"},
+		{"garbage cursor", "cursor=not-a-cursor"},
+		{"cursor from another sort", "cursor=" + otherSortCursor},
+		{"cursor with offset", "cursor=" + otherSortCursor + "&sort=name&offset=0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			_, err := ParseListQuery(values)
+			appErr, ok := err.(*AppError)
+			if !ok || appErr.Code != ErrInvalidQuery {
+				t.Fatalf("ParseListQuery(%q) err = %v, want ErrInvalidQuery", tt.query, err)
+			}
+		})
+	}
+}
+
+func TestListUsersPaginationHTTP(t *testing.T) {
+	store := NewInMemoryUserStore()
+	seedUsers(t, store)
+	srv := NewServer(WithUserStore(store))
+
+	get := func(target string) (*httptest.ResponseRecorder, Response[[]User]) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d; body: %s", target, rec.Code, rec.Body.String())
+		}
+		var resp Response[[]User]
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return rec, resp
+	}
+
+	// ── offset 分页 ─────────────────────────────
+	rec, resp := get("/api/v1/users?limit=2&offset=2&name_prefix=A")
+	if resp.Meta == nil || resp.Meta.Total != 2 || resp.Meta.Page != 2 || resp.Meta.Limit != 2 {
+		t.Fatalf("offset meta = %+v, want total=2 page=2 limit=2", resp.Meta)
+	}
+	link := rec.Header().Get("Link")
+	if !strings.Contains(link, `rel="first"`) || !strings.Contains(link, `rel="prev"`) {
+		t.Errorf("Link = %q, want first and prev", link)
+	}
+	if strings.Contains(link, `rel="next"`) {
+		t.Errorf("Link = %q, last page should not have next", link)
+	}
+	if !strings.Contains(link, "name_prefix=A") {
+		t.Errorf("Link = %q, should keep filter parameters", link)
+	}
+
+	// ── 游标分页: 跟随 meta.next_cursor 遍历全部 ────
+	var got []string
+	target := "/api/v1/users?limit=2&sort=-age,name"
+	for range 10 {
+		_, resp = get(target)
+		got = append(got, names(resp.Data)...)
+		if resp.Meta.NextCursor == "" {
+			break
+		}
+		target = "/api/v1/users?limit=2&sort=-age,name&cursor=" + resp.Meta.NextCursor
+	}
+	want := []string{"alex", "Alice", "Bob", "Alan", "Carol"}
+	if !slices.Equal(got, want) {
+		t.Errorf("cursor walk = %v, want %v", got, want)
+	}
+
+	// ── 非法参数 → 400 ──────────────────────────
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users?sort=password", nil)
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid sort: expected 400, got %d", rec.Code)
+	}
+}
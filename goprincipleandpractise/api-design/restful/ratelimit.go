@@ -0,0 +1,384 @@
+package restful
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── 限流算法 ────────────────────────────────────────
+
+// Limiter 是限流算法的抽象，对一个 key 的一次请求做出放行/拒绝决定。
+type Limiter interface {
+	Allow(ctx context.Context, key string) (RateLimitDecision, error)
+}
+
+// RateLimitDecision 是一次限流判定的结果，对应 RateLimit-* 响应头部。
+type RateLimitDecision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // 配额恢复到满额还需多久
+	RetryAfter time.Duration // 被拒绝时，下一次请求可能成功的等待时间
+}
+
+// RateLimitState 是单个 key 的限流状态，两种算法共用同一结构，
+// 这样 RateLimitStore 只需要存取一种可序列化的值。
+type RateLimitState struct {
+	Tokens float64     // 令牌桶: 当前令牌数
+	Last   time.Time   // 令牌桶: 上次补充令牌的时间
+	Hits   []time.Time // 滑动窗口日志: 窗口内的请求时间戳
+}
+
+// RateLimitStore 保存每个 key 的限流状态。
+// Update 必须对同一个 key 原子地执行 fn（读取-修改-写回），key 不存在时传入零值。
+// 内置 MemoryRateLimitStore；多实例部署可基于 Redis（WATCH/MULTI 或 Lua 脚本）实现。
+type RateLimitStore interface {
+	Update(ctx context.Context, key string, fn func(state *RateLimitState)) error
+}
+
+// TokenBucket 是令牌桶算法: 以固定速率补充令牌，桶容量即允许的突发量。
+// 相比固定窗口，它不会在窗口边界放行两倍流量。
+type TokenBucket struct {
+	store RateLimitStore
+	burst int
+	rate  float64 // 每秒补充的令牌数
+	now   func() time.Time
+}
+
+// NewTokenBucket 创建令牌桶: 每 per 时间补充 limit 个令牌，桶容量为 limit。
+func NewTokenBucket(store RateLimitStore, limit int, per time.Duration) *TokenBucket {
+	return &TokenBucket{
+		store: store,
+		burst: limit,
+		rate:  float64(limit) / per.Seconds(),
+		now:   time.Now,
+	}
+}
+
+func (b *TokenBucket) Allow(ctx context.Context, key string) (RateLimitDecision, error) {
+	d := RateLimitDecision{Limit: b.burst}
+	err := b.store.Update(ctx, key, func(s *RateLimitState) {
+		now := b.now()
+		if s.Last.IsZero() {
+			s.Tokens = float64(b.burst)
+		} else {
+			elapsed := now.Sub(s.Last).Seconds()
+			s.Tokens = math.Min(float64(b.burst), s.Tokens+elapsed*b.rate)
+		}
+		s.Last = now
+
+		if s.Tokens >= 1 {
+			s.Tokens--
+			d.Allowed = true
+		} else {
+			d.RetryAfter = b.secondsToDuration((1 - s.Tokens) / b.rate)
+		}
+		d.Remaining = int(s.Tokens)
+		d.Reset = b.secondsToDuration((float64(b.burst) - s.Tokens) / b.rate)
+	})
+	return d, err
+}
+
+func (b *TokenBucket) secondsToDuration(sec float64) time.Duration {
+	return time.Duration(sec * float64(time.Second))
+}
+
+// SlidingWindowLog 是滑动窗口日志算法: 记录窗口内每次请求的时间戳，精确但内存占用与 limit 成正比。
+type SlidingWindowLog struct {
+	store  RateLimitStore
+	limit  int
+	window time.Duration
+	now    func() time.Time
+}
+
+// NewSlidingWindowLog 创建滑动窗口日志: 任意 window 时长内最多 limit 次请求。
+func NewSlidingWindowLog(store RateLimitStore, limit int, window time.Duration) *SlidingWindowLog {
+	return &SlidingWindowLog{store: store, limit: limit, window: window, now: time.Now}
+}
+
+func (l *SlidingWindowLog) Allow(ctx context.Context, key string) (RateLimitDecision, error) {
+	d := RateLimitDecision{Limit: l.limit}
+	err := l.store.Update(ctx, key, func(s *RateLimitState) {
+		now := l.now()
+		windowStart := now.Add(-l.window)
+
+		valid := s.Hits[:0]
+		for _, t := range s.Hits {
+			if t.After(windowStart) {
+				valid = append(valid, t)
+			}
+		}
+		s.Hits = valid
+
+		if len(s.Hits) < l.limit {
+			s.Hits = append(s.Hits, now)
+			d.Allowed = true
+		}
+		d.Remaining = l.limit - len(s.Hits)
+		if len(s.Hits) > 0 {
+			// 最新的一条过期后窗口清空，配额恢复到满额。
+			d.Reset = s.Hits[len(s.Hits)-1].Add(l.window).Sub(now)
+		}
+		if !d.Allowed {
+			// 最早的一条过期后就能腾出一个配额。
+			d.RetryAfter = s.Hits[0].Add(l.window).Sub(now)
+		}
+	})
+	return d, err
+}
+
+// ── 内存存储 ────────────────────────────────────────
+
+// MemoryRateLimitStore 是进程内的 RateLimitStore。
+// 每隔 idleTTL 顺带清理一次超过 idleTTL 未访问的 key，避免大量不同客户端导致内存无限增长。
+// idleTTL 应不小于限流窗口，这样被清理的 key 与"配额已满额恢复"等价。
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	entries   map[string]*rateLimitEntry
+	idleTTL   time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type rateLimitEntry struct {
+	state    RateLimitState
+	lastSeen time.Time
+}
+
+// NewMemoryRateLimitStore 创建内存存储，idleTTL 为空闲 key 的保留时长。
+func NewMemoryRateLimitStore(idleTTL time.Duration) *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		entries: make(map[string]*rateLimitEntry),
+		idleTTL: idleTTL,
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryRateLimitStore) Update(ctx context.Context, key string, fn func(state *RateLimitState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) >= s.idleTTL {
+		s.sweepLocked(now)
+	}
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = &rateLimitEntry{}
+		s.entries[key] = e
+	}
+	fn(&e.state)
+	e.lastSeen = now
+	return nil
+}
+
+func (s *MemoryRateLimitStore) sweepLocked(now time.Time) {
+	for key, e := range s.entries {
+		if now.Sub(e.lastSeen) >= s.idleTTL {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// Len 返回当前跟踪的 key 数量。
+func (s *MemoryRateLimitStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// ── 限流 key 提取 ───────────────────────────────────
+
+// KeyFunc 从请求中提取限流 key，返回 false 表示该提取器不适用。
+type KeyFunc func(r *http.Request) (string, bool)
+
+// ClientIP 返回真实客户端 IP（不含端口）。
+//
+// 只有当直连对端属于 trustedProxies 时才信任 X-Forwarded-For，
+// 并从右向左跳过受信代理，取第一个非受信地址；否则任何客户端都能伪造头部绕过限流。
+func ClientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	remote := remoteIP(r.RemoteAddr)
+	if !isTrusted(remote, trustedProxies) {
+		return remote.String()
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		ip = ip.Unmap()
+		if !isTrusted(ip, trustedProxies) {
+			return ip.String()
+		}
+		remote = ip
+	}
+	return remote.String()
+}
+
+func remoteIP(addr string) netip.Addr {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return ip.Unmap()
+}
+
+func isTrusted(ip netip.Addr, trusted []netip.Prefix) bool {
+	for _, p := range trusted {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyByIP 按客户端 IP 限流。
+func KeyByIP(trustedProxies []netip.Prefix) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		return "ip:" + ClientIP(r, trustedProxies), true
+	}
+}
+
+// KeyByAPIKey 按已验证的 API key 限流，只适用于放在 Authenticate 之后的限流器。
+// 仅当调用方是通过 API key 认证的才适用，key 取请求头的 SHA-256 摘要而不是明文。
+// 未经校验的头部不能作为限流 key: 客户端每次换一个随机值就能绕过按 IP 的限流，还会不断撑大限流存储。
+func KeyByAPIKey(header string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		p, ok := PrincipalFromContext(r.Context())
+		key := r.Header.Get(header)
+		if !ok || p.Method != AuthMethodAPIKey || key == "" {
+			return "", false
+		}
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:16]), true
+	}
+}
+
+// KeyByUserID 按已认证用户限流，userID 返回空串表示匿名请求。
+// userID 通常读取 PrincipalFromContext，因此限流器必须放在 Authenticate 之后，见 KeyByPrincipal。
+func KeyByUserID(userID func(r *http.Request) string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		id := userID(r)
+		return "user:" + id, id != ""
+	}
+}
+
+// KeyByPrincipal 按 Authenticate 识别出的调用方（Principal.Subject）限流。
+func KeyByPrincipal() KeyFunc {
+	return KeyByUserID(func(r *http.Request) string {
+		if p, ok := PrincipalFromContext(r.Context()); ok {
+			return p.Subject
+		}
+		return ""
+	})
+}
+
+// FirstKey 依次尝试多个提取器，返回第一个适用的 key，
+// 例如认证之后的 FirstKey(KeyByAPIKey(APIKeyHeader), KeyByPrincipal(), KeyByIP(nil))。
+func FirstKey(funcs ...KeyFunc) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		for _, f := range funcs {
+			if key, ok := f(r); ok {
+				return key, true
+			}
+		}
+		return "", false
+	}
+}
+
+// redactKey 返回可以写入日志的限流 key: 保留类型前缀，其余部分替换为摘要前缀，
+// 自定义 KeyFunc 返回的凭证也不会以明文出现在日志中。
+func redactKey(key string) string {
+	kind, _, _ := strings.Cut(key, ":")
+	sum := sha256.Sum256([]byte(key))
+	return kind + ":" + hex.EncodeToString(sum[:6])
+}
+
+// ── 中间件 ──────────────────────────────────────────
+
+// RateLimiter 是限流中间件，组合了限流算法（Limiter）和 key 提取（KeyFunc）。
+type RateLimiter struct {
+	limiter Limiter
+	key     KeyFunc
+}
+
+// RateLimiterOption 配置 RateLimiter。
+type RateLimiterOption func(*RateLimiter)
+
+// WithLimiter 替换限流算法，例如 NewTokenBucket 或基于共享存储的实现。
+func WithLimiter(l Limiter) RateLimiterOption {
+	return func(rl *RateLimiter) { rl.limiter = l }
+}
+
+// WithKeyFunc 替换限流 key 的提取方式。
+func WithKeyFunc(k KeyFunc) RateLimiterOption {
+	return func(rl *RateLimiter) { rl.key = k }
+}
+
+// NewRateLimiter 创建限流器，默认每个客户端 IP 在任意 window 内最多 limit 次请求
+// （滑动窗口日志 + 内存存储，不信任 X-Forwarded-For）。
+func NewRateLimiter(limit int, window time.Duration, opts ...RateLimiterOption) *RateLimiter {
+	rl := &RateLimiter{
+		limiter: NewSlidingWindowLog(NewMemoryRateLimitStore(window), limit, window),
+		key:     KeyByIP(nil),
+	}
+	for _, opt := range opts {
+		opt(rl)
+	}
+	return rl
+}
+
+// Middleware 返回限流中间件，按 IETF draft-ietf-httpapi-ratelimit-headers 设置
+// RateLimit-Limit / RateLimit-Remaining / RateLimit-Reset，拒绝时附带 Retry-After。
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := rl.key(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		d, err := rl.limiter.Allow(r.Context(), key)
+		if err != nil {
+			// 限流存储故障时放行（fail-open），避免限流组件拖垮整个服务。
+			slog.ErrorContext(r.Context(), "rate limit store error",
+				"key", redactKey(key), "request_id", RequestIDFromContext(r.Context()), "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(max(d.Remaining, 0)))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+
+		if !d.Allowed {
+			h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(d.RetryAfter), 1)))
+			WriteError(w, r, NewAppError(ErrRateLimited, "rate limit exceeded", nil))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,205 @@
+package restful
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock 是可手动推进的时钟。
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestTokenBucket(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	store := NewMemoryRateLimitStore(time.Minute)
+	store.now = clock.Now
+	bucket := NewTokenBucket(store, 2, 2*time.Second) // 1 token/s，突发 2
+	bucket.now = clock.Now
+	ctx := context.Background()
+
+	for i := range 2 {
+		d, _ := bucket.Allow(ctx, "k")
+		if !d.Allowed {
+			t.Fatalf("request %d: expected allowed within burst", i+1)
+		}
+	}
+	d, _ := bucket.Allow(ctx, "k")
+	if d.Allowed || d.Remaining != 0 || d.RetryAfter != time.Second {
+		t.Fatalf("over burst: got %+v, want rejected with RetryAfter=1s", d)
+	}
+
+	clock.Advance(time.Second)
+	if d, _ = bucket.Allow(ctx, "k"); !d.Allowed {
+		t.Fatalf("after refill: expected allowed, got %+v", d)
+	}
+	if d, _ = bucket.Allow(ctx, "other"); !d.Allowed || d.Remaining != 1 {
+		t.Fatalf("other key should have its own bucket, got %+v", d)
+	}
+}
+
+func TestSlidingWindowLog(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	store := NewMemoryRateLimitStore(time.Minute)
+	store.now = clock.Now
+	window := NewSlidingWindowLog(store, 2, 10*time.Second)
+	window.now = clock.Now
+	ctx := context.Background()
+
+	d, _ := window.Allow(ctx, "k")
+	if d.Reset != 10*time.Second {
+		t.Fatalf("first request: Reset = %v, want 10s", d.Reset)
+	}
+	clock.Advance(4 * time.Second)
+	d, _ = window.Allow(ctx, "k")
+	// Reset 与 TokenBucket 一致，是恢复到满额的时间: 由最新的一次请求决定，而不是最早的。
+	if !d.Allowed || d.Remaining != 0 || d.Reset != 10*time.Second {
+		t.Fatalf("second request: got %+v, want Reset 10s", d)
+	}
+
+	clock.Advance(4 * time.Second)
+	d, _ = window.Allow(ctx, "k")
+	if d.Allowed || d.RetryAfter != 2*time.Second || d.Reset != 6*time.Second {
+		t.Fatalf("third request: got %+v, want rejected until first hit leaves the window (2s), full at 6s", d)
+	}
+
+	clock.Advance(2 * time.Second)
+	if d, _ = window.Allow(ctx, "k"); !d.Allowed {
+		t.Fatalf("after first hit expired: got %+v, want allowed", d)
+	}
+}
+
+func TestMemoryRateLimitStoreEvictsIdleKeys(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	store := NewMemoryRateLimitStore(time.Minute)
+	store.now = clock.Now
+	ctx := context.Background()
+	noop := func(*RateLimitState) {}
+
+	for _, key := range []string{"a", "b", "c"} {
+		_ = store.Update(ctx, key, noop)
+	}
+	clock.Advance(30 * time.Second)
+	_ = store.Update(ctx, "a", noop)
+
+	clock.Advance(45 * time.Second)
+	_ = store.Update(ctx, "d", noop) // 触发清理: b、c 空闲超过 1 分钟
+
+	if got := store.Len(); got != 2 {
+		t.Fatalf("Len = %d, want 2 (a and d)", got)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		want   string
+	}{
+		{"direct client strips port", "203.0.113.7:54321", "", "203.0.113.7"},
+		{"untrusted peer cannot spoof XFF", "203.0.113.7:54321", "1.2.3.4", "203.0.113.7"},
+		{"trusted proxy forwards client", "10.0.0.1:80", "198.51.100.9", "198.51.100.9"},
+		{"skip chained trusted proxies", "10.0.0.1:80", "198.51.100.9, 10.0.0.2", "198.51.100.9"},
+		{"spoofed left-most entry ignored", "10.0.0.1:80", "1.2.3.4, 198.51.100.9", "198.51.100.9"},
+		{"ipv6 remote", "[2001:db8::1]:443", "", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if got := ClientIP(req, trusted); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterMiddlewareHeaders(t *testing.T) {
+	rl := NewRateLimiter(2, time.Minute)
+	handler := rl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// 同一 IP 不同端口视为同一客户端。
+	send("192.0.2.1:1000")
+	rec := send("192.0.2.1:1001")
+	if rec.Header().Get("RateLimit-Limit") != "2" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("headers = %v", rec.Header())
+	}
+	rec = send("192.0.2.1:1002")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+}
+
+func TestRateLimiterAPIKeyAfterAuthentication(t *testing.T) {
+	keys := NewMemoryAPIKeyStore()
+	keys.Add("key-1", Principal{Subject: "svc-a"})
+	rl := NewRateLimiter(2, time.Minute, WithKeyFunc(FirstKey(KeyByAPIKey(APIKeyHeader), KeyByIP(nil))))
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	// 认证之前的限流器看不到已验证的 key，随机伪造的 key 不会得到独立配额。
+	before := rl.Middleware(ok)
+	for i := range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1000"
+		req.Header.Set(APIKeyHeader, fmt.Sprintf("fake-%d", i))
+		rec := httptest.NewRecorder()
+		before.ServeHTTP(rec, req)
+		want := http.StatusOK
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if rec.Code != want {
+			t.Fatalf("fake key %d: status = %d, want %d", i, rec.Code, want)
+		}
+	}
+
+	// 认证之后，合法 key 按自己的摘要计数，与 IP 的配额无关。
+	after := Chain(Authenticate(APIKeyAuthenticator(keys)), rl.Middleware)(ok)
+	for i := range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1000"
+		req.Header.Set(APIKeyHeader, "key-1")
+		rec := httptest.NewRecorder()
+		after.ServeHTTP(rec, req)
+		want := http.StatusOK
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if rec.Code != want {
+			t.Fatalf("valid key %d: status = %d, want %d", i, rec.Code, want)
+		}
+	}
+}
+
+func TestRedactKey(t *testing.T) {
+	got := redactKey("key:secret-api-key")
+	if !strings.HasPrefix(got, "key:") || strings.Contains(got, "secret") {
+		t.Errorf("redactKey = %q", got)
+	}
+}
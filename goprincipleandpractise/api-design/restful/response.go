@@ -2,7 +2,13 @@ package restful
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/status"
 )
 
 // Response 是标准成功响应信封。
@@ -13,11 +19,13 @@ type Response[T any] struct {
 }
 
 // Meta 包含分页元数据。
+// 游标分页时 Page 为 0，客户端应使用 NextCursor 翻页。
 type Meta struct {
-	Total  int `json:"total"`
-	Page   int `json:"page"`
-	Limit  int `json:"limit"`
-	Offset int `json:"offset,omitempty"`
+	Total      int    `json:"total"`
+	Page       int    `json:"page"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // ErrorResponse 是标准错误响应信封。
@@ -27,10 +35,10 @@ type ErrorResponse struct {
 
 // ErrorBody 包含错误详情。
 type ErrorBody struct {
-	Code    ErrCode           `json:"code"`
-	Message string            `json:"message"`
-	Detail  string            `json:"detail,omitempty"`
-	Fields  map[string]string `json:"fields,omitempty"` // 字段级校验错误
+	Code    ErrCode          `json:"code"`
+	Message string           `json:"message"`
+	Detail  string           `json:"detail,omitempty"`
+	Fields  ValidationErrors `json:"fields,omitempty"` // 字段级校验错误
 }
 
 // writeJSON 将 v 序列化为 JSON 写入 w，设置 Content-Type 和状态码。
@@ -41,37 +49,126 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 }
 
 // WriteSuccess 写入标准成功响应。
-func WriteSuccess[T any](w http.ResponseWriter, status int, data T) {
-	writeJSON(w, status, Response[T]{Data: data})
+// 请求经过版本中间件时，data 按协商出的版本转换，见 Versions.Middleware；
+// 经过 Codecs.Middleware 时，信封按 Accept 编码为 CBOR、MessagePack 等格式。
+func WriteSuccess[T any](w http.ResponseWriter, r *http.Request, status int, data T) {
+	writeSuccess(w, r, status, data, nil)
 }
 
 // WriteSuccessWithMeta 写入带分页的成功响应。
-func WriteSuccessWithMeta[T any](w http.ResponseWriter, data T, meta Meta) {
-	writeJSON(w, http.StatusOK, Response[T]{Data: data, Meta: &meta})
+func WriteSuccessWithMeta[T any](w http.ResponseWriter, r *http.Request, data T, meta Meta) {
+	writeSuccess(w, r, http.StatusOK, data, &meta)
+}
+
+func writeSuccess(w http.ResponseWriter, r *http.Request, status int, data any, meta *Meta) {
+	v, versioned := versionFrom(r)
+	body := Response[any]{Data: v.represent(data), Meta: meta}
+	switch codec := codecFor(r); {
+	case versioned && v.byAccept:
+		w.Header().Set("Content-Type", v.MediaType()+"; charset=utf-8")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	case codec != nil:
+		w.Header().Set("Content-Type", codec.MediaType())
+		w.WriteHeader(status)
+		_ = codec.Encode(w, body)
+	default:
+		writeJSON(w, status, body)
+	}
 }
 
 // WriteError 写入标准错误响应。
-func WriteError(w http.ResponseWriter, appErr *AppError) {
-	resp := ErrorResponse{
-		Error: ErrorBody{
-			Code:    appErr.Code,
-			Message: appErr.Message,
-			Detail:  appErr.Detail,
-		},
+// 请求经过 Localize 时，message 使用协商出的语言；detail 是调试信息，不做翻译。
+// 响应体格式（ErrorResponse 信封或 RFC 9457 Problem）见 DefaultErrorFormat。
+//
+// err 通常是 *AppError；gRPC 状态错误（status.Error、st.Err()）按 apierror 映射错误码，
+// BadRequest 写入 fields，RetryInfo 写入 Retry-After；其他错误一律返回 500，不暴露内部信息。
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var appErr *AppError
+	var fields ValidationErrors
+	if !errors.As(err, &appErr) {
+		if st, ok := status.FromError(err); ok {
+			var retryAfter time.Duration
+			appErr, fields, retryAfter = statusError(st)
+			if retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			}
+		} else {
+			appErr = NewAppError(ErrInternalError, ErrServerFailure.Message, err)
+		}
 	}
-	writeJSON(w, appErr.Code.HTTPStatusCode(), resp)
+
+	loc, localized := prepareErrorResponse(w, r)
+	if localized && len(fields) > 0 {
+		fields = loc.translateFields(fields)
+	}
+	message := loc.catalog.ErrorMessage(loc.lang, appErr.Code, appErr.Message)
+	writeErrorBody(w, r, appErr.Code, message, appErr.Detail, fields)
+}
+
+// WriteValidationError 写入字段级校验错误响应，每个字段同时带规则名和本地化消息。
+func WriteValidationError(w http.ResponseWriter, r *http.Request, fields ValidationErrors) {
+	loc, localized := prepareErrorResponse(w, r)
+	if localized {
+		fields = loc.translateFields(fields)
+	}
+	message := loc.catalog.ErrorMessage(loc.lang, ErrValidationFailed, "request validation failed")
+	writeErrorBody(w, r, ErrValidationFailed, message, "", fields)
 }
 
-// WriteValidationError 写入字段级校验错误响应。
-func WriteValidationError(w http.ResponseWriter, fields map[string]string) {
-	resp := ErrorResponse{
+// translateFields 返回 fields 按 loc 重新生成消息后的副本。
+func (loc locale) translateFields(fields ValidationErrors) ValidationErrors {
+	translated := make(ValidationErrors, len(fields))
+	for path, fe := range fields {
+		if fe.Rule != "" {
+			fe.Message = loc.catalog.RuleMessage(loc.lang, path, fe.Rule, fe.Param, fe.kind)
+		}
+		translated[path] = fe
+	}
+	return translated
+}
+
+// newErrorBody 构造内嵌在成功响应中的 ErrorBody（例如批量接口每一项的结果），
+// 消息本地化规则与 WriteError / WriteValidationError 相同。
+func newErrorBody(r *http.Request, appErr *AppError, fields ValidationErrors) *ErrorBody {
+	loc, localized := localeFrom(r)
+	if localized && len(fields) > 0 {
+		fields = loc.translateFields(fields)
+	}
+	return &ErrorBody{
+		Code:    appErr.Code,
+		Message: loc.catalog.ErrorMessage(loc.lang, appErr.Code, appErr.Message),
+		Detail:  appErr.Detail,
+		Fields:  fields,
+	}
+}
+
+// prepareErrorResponse 设置错误响应共有的头部，返回请求的语言设置。
+func prepareErrorResponse(w http.ResponseWriter, r *http.Request) (locale, bool) {
+	loc, localized := localeFrom(r)
+	if localized {
+		w.Header().Set("Content-Language", loc.lang)
+	}
+	// 错误响应的格式取决于 Accept。
+	w.Header().Add("Vary", "Accept")
+	return loc, localized
+}
+
+func writeErrorBody(w http.ResponseWriter, r *http.Request, code ErrCode, message, detail string, fields ValidationErrors) {
+	status := code.HTTPStatusCode()
+	observeErrCode(r, code)
+	if errorFormatFor(r) == ErrorFormatProblem {
+		writeProblem(w, r, status, code, message, detail, fields)
+		return
+	}
+	writeJSON(w, status, ErrorResponse{
 		Error: ErrorBody{
-			Code:    ErrValidationFailed,
-			Message: "request validation failed",
+			Code:    code,
+			Message: message,
+			Detail:  detail,
 			Fields:  fields,
 		},
-	}
-	writeJSON(w, http.StatusUnprocessableEntity, resp)
+	})
 }
 
 // WriteNoContent 写入 204 无内容响应。
@@ -0,0 +1,205 @@
+package restful
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ── 健康检查 ────────────────────────────────────────
+
+// Health 是实例的就绪状态，驱动 /readyz。零值为未就绪。
+//
+// 存活（/livez）只表示进程没有卡死，失败会导致重启；就绪（/readyz）表示可以接收流量，
+// 失败只会让负载均衡暂时摘除实例。关闭期间应当就绪失败而存活成功。
+type Health struct {
+	ready atomic.Bool
+}
+
+// SetReady 设置就绪状态。
+func (h *Health) SetReady(ready bool) { h.ready.Store(ready) }
+
+// Ready 报告是否就绪。
+func (h *Health) Ready() bool { return h.ready.Load() }
+
+func (h *Health) serveLive(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h *Health) serveReady(w http.ResponseWriter, r *http.Request) {
+	if !h.Ready() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// ── 生命周期 ────────────────────────────────────────
+
+// RunConfig 配置 Run。零值字段使用括号中的默认值。
+type RunConfig struct {
+	Addr     string       // 监听地址（":8080"）
+	Listener net.Listener // 非 nil 时忽略 Addr，例如测试中监听 127.0.0.1:0
+
+	ReadHeaderTimeout time.Duration // 读取请求头的超时，防御 Slowloris（5s）
+	ReadTimeout       time.Duration // 读取整个请求的超时（15s）
+	WriteTimeout      time.Duration // 从读完请求头到写完响应的超时（30s）
+	IdleTimeout       time.Duration // keep-alive 连接的空闲超时（60s）
+	MaxHeaderBytes    int           // 请求头大小上限（64 KiB）
+
+	// ReadinessDelay 是 /readyz 开始失败之后、停止接收新连接之前的等待时间（0），
+	// 应不短于负载均衡探测间隔，否则仍有请求被发往正在关闭的实例。
+	ReadinessDelay time.Duration
+	// DrainTimeout 是等待进行中请求完成的上限（25s），超时后强制关闭连接。
+	// 与 ReadinessDelay 之和应小于编排系统的终止宽限期（Kubernetes 默认 30s）。
+	DrainTimeout time.Duration
+	Signals      []os.Signal // 触发优雅关闭的信号（SIGINT、SIGTERM）
+
+	// OpenStore 在开始监听之前调用，返回的存储通过 WithUserStore 传给 NewServer。
+	OpenStore func(ctx context.Context) (UserStore, error)
+	// CloseStore 在所有请求结束（或 DrainTimeout 到期）后调用。
+	CloseStore func(ctx context.Context) error
+
+	Options []ServerOption // 透传给 NewServer
+	Logger  *slog.Logger   // 生命周期日志（slog.Default()）
+}
+
+func (c *RunConfig) setDefaults() {
+	if c.Addr == "" {
+		c.Addr = ":8080"
+	}
+	if c.ReadHeaderTimeout == 0 {
+		c.ReadHeaderTimeout = 5 * time.Second
+	}
+	if c.ReadTimeout == 0 {
+		c.ReadTimeout = 15 * time.Second
+	}
+	if c.WriteTimeout == 0 {
+		c.WriteTimeout = 30 * time.Second
+	}
+	if c.IdleTimeout == 0 {
+		c.IdleTimeout = 60 * time.Second
+	}
+	if c.MaxHeaderBytes == 0 {
+		c.MaxHeaderBytes = 64 << 10
+	}
+	if c.DrainTimeout == 0 {
+		c.DrainTimeout = 25 * time.Second
+	}
+	if len(c.Signals) == 0 {
+		c.Signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	if c.Logger == nil {
+		c.Logger = slog.Default()
+	}
+}
+
+// Run 启动 HTTP 服务并阻塞，直到 ctx 取消或收到 cfg.Signals 中的信号，然后优雅关闭:
+//
+//  1. /readyz 开始返回 503，等待 ReadinessDelay 让负载均衡摘除实例
+//  2. http.Server.Shutdown 停止接收新连接，等待进行中的请求，最多 DrainTimeout
+//  3. 调用 CloseStore
+//
+// 优雅关闭成功时返回 nil；监听失败、存储初始化失败或排空超时时返回错误。
+func Run(ctx context.Context, cfg RunConfig) error {
+	cfg.setDefaults()
+	logger := cfg.Logger
+
+	ctx, stop := signal.NotifyContext(ctx, cfg.Signals...)
+	defer stop()
+
+	opts := cfg.Options
+	if cfg.OpenStore != nil {
+		store, err := cfg.OpenStore(ctx)
+		if err != nil {
+			return fmt.Errorf("open store: %w", err)
+		}
+		opts = append(opts[:len(opts):len(opts)], WithUserStore(store))
+	}
+	health := &Health{}
+	opts = append(opts[:len(opts):len(opts)], WithHealth(health))
+
+	ln := cfg.Listener
+	if ln == nil {
+		var err error
+		if ln, err = net.Listen("tcp", cfg.Addr); err != nil {
+			return errors.Join(err, closeStore(cfg))
+		}
+	}
+
+	handler := NewServer(opts...)
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		// 请求 context 不继承 ctx: 收到信号后进行中的请求应当跑完，而不是立即被取消。
+		BaseContext: func(net.Listener) context.Context { return context.WithoutCancel(ctx) },
+	}
+
+	if rt, ok := handler.(*Router); ok {
+		for _, f := range rt.onShutdown {
+			srv.RegisterOnShutdown(f)
+		}
+	}
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(ln) }()
+	health.SetReady(true)
+	logger.Info("server started", slog.String("addr", ln.Addr().String()))
+
+	select {
+	case err := <-serveErr:
+		return errors.Join(err, closeStore(cfg))
+	case <-ctx.Done():
+	}
+	stop() // 再次收到信号时按默认行为立即退出
+
+	health.SetReady(false)
+	logger.Info("shutting down", slog.Duration("readiness_delay", cfg.ReadinessDelay), slog.Duration("drain_timeout", cfg.DrainTimeout))
+	time.Sleep(cfg.ReadinessDelay)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.DrainTimeout)
+	defer cancel()
+	var errs []error
+	if err := srv.Shutdown(drainCtx); err != nil {
+		errs = append(errs, fmt.Errorf("drain: %w", err))
+		_ = srv.Close()
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, err)
+	}
+	errs = append(errs, closeStore(cfg))
+
+	err := errors.Join(errs...)
+	if err != nil {
+		logger.Error("shutdown failed", slog.Any("error", err))
+	} else {
+		logger.Info("server stopped")
+	}
+	return err
+}
+
+// closeStore 调用 CloseStore，给它一个独立的超时，不受已取消的 ctx 影响。
+func closeStore(cfg RunConfig) error {
+	if cfg.CloseStore == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cfg.CloseStore(ctx); err != nil {
+		return fmt.Errorf("close store: %w", err)
+	}
+	return nil
+}
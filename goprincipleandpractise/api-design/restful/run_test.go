@@ -0,0 +1,220 @@
+package restful
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// blockingStore 的 Create 阻塞到 release 关闭，用来制造进行中的请求。
+type blockingStore struct {
+	*InMemoryUserStore
+	entered chan struct{}
+	release chan struct{}
+	done    atomic.Bool
+}
+
+func newBlockingStore() *blockingStore {
+	return &blockingStore{
+		InMemoryUserStore: NewInMemoryUserStore(),
+		entered:           make(chan struct{}),
+		release:           make(chan struct{}),
+	}
+}
+
+func (s *blockingStore) Create(ctx context.Context, u User) (User, error) {
+	close(s.entered)
+	<-s.release
+	defer s.done.Store(true)
+	return s.InMemoryUserStore.Create(ctx, u)
+}
+
+func listenLocal(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+func getStatus(url string) int {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode
+}
+
+func waitStatus(t *testing.T, url string, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for getStatus(url) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("GET %s never returned %d", url, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+var quietLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestRunGracefulShutdownOnSIGTERM(t *testing.T) {
+	store := newBlockingStore()
+	var closedAfterDrain atomic.Bool
+	closed := make(chan struct{})
+	ln := listenLocal(t)
+	base := "http://" + ln.Addr().String()
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- Run(context.Background(), RunConfig{
+			Listener:       ln,
+			ReadinessDelay: 300 * time.Millisecond,
+			OpenStore:      func(context.Context) (UserStore, error) { return store, nil },
+			CloseStore: func(context.Context) error {
+				closedAfterDrain.Store(store.done.Load())
+				close(closed)
+				return nil
+			},
+			Options: []ServerOption{WithLogger(quietLogger)},
+			Logger:  quietLogger,
+		})
+	}()
+	waitStatus(t, base+"/readyz", http.StatusOK)
+
+	// 一个进行中的写请求。
+	created := make(chan int, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodPost, base+"/api/v1/users",
+			strings.NewReader(`{"name":"Alice","email":"alice@example.com","age":30}`))
+		req.Header.Set("Authorization", "Bearer demo-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			created <- 0
+			return
+		}
+		resp.Body.Close()
+		created <- resp.StatusCode
+	}()
+	<-store.entered
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		t.Skipf("cannot deliver SIGTERM on this platform: %v", err)
+	}
+
+	// 就绪先失败，存活保持成功。
+	waitStatus(t, base+"/readyz", http.StatusServiceUnavailable)
+	if got := getStatus(base + "/livez"); got != http.StatusOK {
+		t.Errorf("/livez during shutdown = %d, want 200", got)
+	}
+
+	select {
+	case <-closed:
+		t.Fatal("store closed while a request was still in flight")
+	case err := <-runErr:
+		t.Fatalf("Run returned before draining: %v", err)
+	case <-time.After(400 * time.Millisecond):
+	}
+	close(store.release)
+
+	if code := <-created; code != http.StatusCreated {
+		t.Errorf("in-flight request: status %d, want 201", code)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !closedAfterDrain.Load() {
+		t.Error("CloseStore ran before the in-flight request finished")
+	}
+}
+
+func TestRunDrainTimeout(t *testing.T) {
+	store := newBlockingStore()
+	defer close(store.release)
+	var closed atomic.Bool
+	ln := listenLocal(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- Run(ctx, RunConfig{
+			Listener:     ln,
+			DrainTimeout: 100 * time.Millisecond,
+			OpenStore:    func(context.Context) (UserStore, error) { return store, nil },
+			CloseStore:   func(context.Context) error { closed.Store(true); return nil },
+			Options:      []ServerOption{WithLogger(quietLogger)},
+			Logger:       quietLogger,
+		})
+	}()
+	base := "http://" + ln.Addr().String()
+	waitStatus(t, base+"/readyz", http.StatusOK)
+
+	go func() {
+		req, _ := http.NewRequest(http.MethodPost, base+"/api/v1/users", strings.NewReader(`{"name":"Bob","email":"bob@example.com","age":30}`))
+		req.Header.Set("Authorization", "Bearer demo-token")
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-store.entered
+	cancel()
+
+	err := <-runErr
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run = %v, want drain deadline error", err)
+	}
+	if !closed.Load() {
+		t.Error("CloseStore not called after drain timeout")
+	}
+}
+
+func TestRunOpenStoreError(t *testing.T) {
+	boom := errors.New("db unreachable")
+	err := Run(context.Background(), RunConfig{
+		Addr:      "127.0.0.1:0",
+		OpenStore: func(context.Context) (UserStore, error) { return nil, boom },
+		Logger:    quietLogger,
+	})
+	if !errors.Is(err, boom) {
+		t.Errorf("Run = %v, want %v", err, boom)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	h := &Health{}
+	srv := NewServer(WithHealth(h))
+	for _, tt := range []struct {
+		ready bool
+		path  string
+		want  int
+	}{
+		{false, "/readyz", http.StatusServiceUnavailable},
+		{false, "/livez", http.StatusOK},
+		{false, "/healthz", http.StatusOK},
+		{true, "/readyz", http.StatusOK},
+	} {
+		h.SetReady(tt.ready)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("ready=%v GET %s = %d, want %d", tt.ready, tt.path, rec.Code, tt.want)
+		}
+	}
+}
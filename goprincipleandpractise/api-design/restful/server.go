@@ -1,9 +1,170 @@
 package restful
 
 import (
+	"log/slog"
 	"net/http"
+	"time"
 )
 
+// ServerOption 配置 NewServer 的可选项。
+type ServerOption func(*serverConfig)
+
+type serverConfig struct {
+	store       UserStore
+	idempotency IdempotencyStore
+	limiter     *RateLimiter
+	authLimiter *RateLimiter
+	handlerOpts []UserHandlerOption
+	catalog     *Catalog
+	errorFormat ErrorFormat
+	logger      *slog.Logger
+	metrics     *Metrics
+	metricsPath bool
+	authn       []Authenticator
+	cors        *CORSPolicy
+	health      *Health
+	versions    *Versions
+	compress    *CompressConfig
+	codecs      *Codecs
+	events      *EventBus
+	eventsCfg   EventsConfig
+}
+
+// WithUserStore 替换默认的内存存储，例如传入 SQLUserStore 使数据在重启后保留。
+func WithUserStore(store UserStore) ServerOption {
+	return func(c *serverConfig) {
+		c.store = store
+	}
+}
+
+// WithIdempotencyStore 替换默认的内存幂等存储，多实例部署时应使用共享存储。
+func WithIdempotencyStore(store IdempotencyStore) ServerOption {
+	return func(c *serverConfig) {
+		c.idempotency = store
+	}
+}
+
+// WithRateLimiter 替换默认的限流器（每个客户端 IP 100 req/min）。
+// 它在认证之前运行，只能按 IP 等不依赖凭证的 key 限流。
+func WithRateLimiter(rl *RateLimiter) ServerOption {
+	return func(c *serverConfig) {
+		c.limiter = rl
+	}
+}
+
+// WithAuthenticatedRateLimiter 在 Authenticate 之后再加一层按调用方限流，默认不启用。
+// rl 的 KeyFunc 应使用 KeyByPrincipal、KeyByAPIKey 等读取已验证身份的提取器。
+func WithAuthenticatedRateLimiter(rl *RateLimiter) ServerOption {
+	return func(c *serverConfig) {
+		c.authLimiter = rl
+	}
+}
+
+// WithUserHandlerOptions 透传 UserHandler 的可选项，例如 WithRequireIfMatch(true)。
+func WithUserHandlerOptions(opts ...UserHandlerOption) ServerOption {
+	return func(c *serverConfig) {
+		c.handlerOpts = append(c.handlerOpts, opts...)
+	}
+}
+
+// WithCatalog 替换默认的中英文消息目录。
+func WithCatalog(c *Catalog) ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.catalog = c
+	}
+}
+
+// WithErrorFormat 设置错误响应的默认格式，例如 ErrorFormatProblem。
+// 客户端仍可通过 Accept 选择格式，见 DefaultErrorFormat。
+func WithErrorFormat(f ErrorFormat) ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.errorFormat = f
+	}
+}
+
+// WithLogger 设置访问日志和 panic 日志使用的 logger，默认为 slog.Default()。
+func WithLogger(logger *slog.Logger) ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.logger = logger
+	}
+}
+
+// WithMetrics 使用调用方提供的指标收集器，例如需要自定义直方图桶时。
+// 指标默认不对外暴露，m 本身是 http.Handler，通常挂到只在内网监听的端口上:
+//
+//	m := restful.NewMetrics()
+//	go http.ListenAndServe("127.0.0.1:9090", m)
+//	srv := restful.NewServer(restful.WithMetrics(m))
+func WithMetrics(m *Metrics) ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.metrics = m
+	}
+}
+
+// WithMetricsEndpoint 在 API 同一个 mux 上挂载 GET /metrics，且不要求认证。
+// 指标会暴露路由、错误分布和流量，只应在该端口不对公网开放（例如由网关屏蔽 /metrics）时使用。
+func WithMetricsEndpoint() ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.metricsPath = true
+	}
+}
+
+// WithAuthenticators 设置认证方式，按顺序尝试，例如 JWTVerifier 和 APIKeyAuthenticator。
+// 默认只接受 demo token（Bearer demo-token，拥有 users:read 和 users:write）。
+func WithAuthenticators(authn ...Authenticator) ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.authn = append(cfg.authn, authn...)
+	}
+}
+
+// WithCORS 替换默认的 CORS 策略（任意来源、不带凭证），例如只允许管理后台的来源并携带 Cookie。
+func WithCORS(p *CORSPolicy) ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.cors = p
+	}
+}
+
+// WithHealth 让 /readyz 反映 h 的状态，Run 用它在关闭期间让就绪探针失败。
+// 默认始终就绪。
+func WithHealth(h *Health) ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.health = h
+	}
+}
+
+// WithVersions 替换默认的版本集合，例如标记 v1 弃用:
+//
+//	WithVersions(DefaultVersions().Deprecate("v1", deprecatedAt, sunset, "v2"))
+func WithVersions(vs *Versions) ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.versions = vs
+	}
+}
+
+// WithCompression 配置响应压缩，默认为 CompressConfig 的零值（gzip、deflate，阈值 1 KiB）。
+func WithCompression(cc CompressConfig) ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.compress = &cc
+	}
+}
+
+// WithCodecs 替换按 Accept 选择的响应编解码器，默认为 DefaultCodecs。
+// 只保留 JSON 时传 NewCodecs()。
+func WithCodecs(cs *Codecs) ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.codecs = cs
+	}
+}
+
+// WithEvents 替换默认的事件总线并配置 GET /api/v1/users/events。
+// 多个实例共享同一个 UserStore 时，需要换成跨进程的总线才能看到其他实例的写入。
+func WithEvents(bus *EventBus, ec EventsConfig) ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.events = bus
+		cfg.eventsCfg = ec
+	}
+}
+
 // NewServer 创建并配置 HTTP 服务器，演示 Go 1.22+ 路由语法。
 //
 // 路由设计要点:
@@ -14,48 +175,240 @@ import (
 //
 // 中间件链顺序:
 //
-//	Recovery → CORS → Logging → RateLimit → Auth → Handler
-func NewServer() http.Handler {
-	mux := http.NewServeMux()
-	store := NewInMemoryUserStore()
-	handler := NewUserHandler(store)
-	limiter := NewRateLimiter(100, 60_000_000_000) // 100 req/min
+//	Localize → ErrorFormat → RequestID → Metrics → Compress → Codecs → Recovery → CORS → Logging → Version → RateLimit → Authenticate → RequireScopes → [Idempotency] → Handler
+//
+// Localize 和 ErrorFormat 放在最外层，这样 Recovery 写出的 500 也使用协商出的语言和格式；
+// Metrics 在 Recovery 之外，panic 产生的 500 同样会被计入；
+// Compress 在 Recovery 之外，Recovery 写出的 500 同样经过编码协商。
+// OPTIONS 预检只经过 Recovery 及其外层和 Logging，然后由 CORSPolicy.Preflight 应答。
+func NewServer(opts ...ServerOption) http.Handler {
+	cfg := serverConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.store == nil {
+		cfg.store = NewInMemoryUserStore()
+	}
+	if cfg.idempotency == nil {
+		cfg.idempotency = NewMemoryIdempotencyStore(24*time.Hour, 10_000)
+	}
+	if cfg.limiter == nil {
+		cfg.limiter = NewRateLimiter(100, time.Minute) // 每个客户端 IP 100 req/min
+	}
+	if cfg.logger == nil {
+		cfg.logger = slog.Default()
+	}
+	if cfg.metrics == nil {
+		cfg.metrics = NewMetrics()
+	}
+	if cfg.catalog == nil {
+		cfg.catalog = DefaultCatalog()
+	}
+	if cfg.health == nil {
+		cfg.health = &Health{}
+		cfg.health.SetReady(true)
+	}
+	if cfg.versions == nil {
+		cfg.versions = DefaultVersions()
+	}
+	if cfg.cors == nil {
+		cfg.cors = defaultCORS
+	}
+	if cfg.compress == nil {
+		cfg.compress = &CompressConfig{}
+	}
+	if cfg.codecs == nil {
+		cfg.codecs = DefaultCodecs()
+	}
+	if cfg.events == nil {
+		cfg.events = NewEventBus(DefaultEventLogSize)
+	}
+	if len(cfg.authn) == 0 {
+		cfg.authn = []Authenticator{StaticTokens(map[string]Principal{
+			"demo-token": {Subject: "demo", Scopes: []string{ScopeUsersRead, ScopeUsersWrite}, Method: AuthMethodToken},
+		})}
+	}
+
+	rt := NewRouter()
+	// 写入经由 PublishingStore 发布到事件总线；关闭服务时结束所有事件流。
+	handler := NewUserHandler(PublishingStore(cfg.store, cfg.events), cfg.handlerOpts...)
+	rt.RegisterOnShutdown(cfg.events.Close)
+
+	// 所有路由共用的外层中间件
+	base := Chain(Localize(cfg.catalog), DefaultErrorFormat(cfg.errorFormat), RequestID,
+		cfg.metrics.Middleware, Compress(*cfg.compress), cfg.codecs.Middleware, RecoveryWith(cfg.logger))
+
+	// 公开路由（不需要认证）。版本中间件在 Logging 之后: 406 会被记录，
+	// 限流和认证产生的错误响应也带 Deprecation/Sunset 头。
+	publicFor := func(version string) Middleware {
+		return Chain(base, cfg.cors.Middleware, LoggingWith(cfg.logger, nil),
+			cfg.versions.Middleware(version), cfg.limiter.Middleware)
+	}
+	public := publicFor("v1")
 
-	// 公开路由（不需要认证）
-	public := Chain(Recovery, CORS, Logging, limiter.Middleware)
+	// 受保护路由（需要认证和 users:write）
+	// 按调用方的限流必须在 Authenticate 之后: 之前只能看到未经校验的凭证。
+	authenticated := Chain(public, Authenticate(cfg.authn...))
+	if cfg.authLimiter != nil {
+		authenticated = Chain(authenticated, cfg.authLimiter.Middleware)
+	}
+	protected := Chain(authenticated, RequireScopes(ScopeUsersWrite))
+	writeScopes := []string{ScopeUsersWrite}
 
-	// 受保护路由（需要认证）
-	protected := Chain(Recovery, CORS, Logging, limiter.Middleware, Auth(nil))
+	// 受保护且支持 Idempotency-Key 的 POST 路由
+	idempotent := Chain(protected, Idempotency(cfg.idempotency))
 
 	// ── v1 路由 ─────────────────────────────────────────
 	// GET    /api/v1/users       → 列表
 	// POST   /api/v1/users       → 创建
 	// GET    /api/v1/users/{id}  → 详情
-	// PUT    /api/v1/users/{id}  → 更新
+	// PUT    /api/v1/users/{id}  → 全量替换
+	// PATCH  /api/v1/users/{id}  → 部分更新（merge-patch / json-patch）
 	// DELETE /api/v1/users/{id}  → 删除
+	// POST   /api/v1/users:batch → 批量创建/更新/删除（AIP-136 风格的自定义方法）
+	// GET    /api/v1/users/events → 变更事件流（SSE）
 
-	mux.Handle("GET /api/v1/users",
-		public(http.HandlerFunc(handler.ListUsers)))
-	mux.Handle("POST /api/v1/users",
-		protected(http.HandlerFunc(handler.CreateUser)))
-	mux.Handle("GET /api/v1/users/{id}",
-		public(http.HandlerFunc(handler.GetUser)))
-	mux.Handle("PUT /api/v1/users/{id}",
-		protected(http.HandlerFunc(handler.UpdateUser)))
-	mux.Handle("DELETE /api/v1/users/{id}",
-		protected(http.HandlerFunc(handler.DeleteUser)))
-
-	// ── v2 路由（示例：版本共存）───────────────────────────
-	// v2 可能返回不同的响应格式或增加字段
-	mux.Handle("GET /api/v2/users",
-		public(http.HandlerFunc(handler.ListUsers)))
-	mux.Handle("GET /api/v2/users/{id}",
-		public(http.HandlerFunc(handler.GetUser)))
+	rt.Handle(Route{
+		Method: http.MethodGet, Pattern: "/api/v1/users",
+		OperationID: "listUsers", Summary: "List users with pagination, sorting and filtering",
+		Params: listUsersParams, Status: http.StatusOK, Response: Response[[]User]{},
+		Handler: public(http.HandlerFunc(handler.ListUsers)),
+	})
+	rt.Handle(Route{
+		Method: http.MethodPost, Pattern: "/api/v1/users",
+		OperationID: "createUser", Summary: "Create a user", Auth: true, Scopes: writeScopes,
+		Params:  []Param{idempotencyKeyParam},
+		Request: map[string]any{"application/json": CreateUserRequest{}},
+		Status:  http.StatusCreated, Response: Response[User]{},
+		Handler: idempotent(http.HandlerFunc(handler.CreateUser)),
+	})
+	rt.Handle(Route{
+		Method: http.MethodGet, Pattern: "/api/v1/users/events",
+		OperationID: "watchUsers", Summary: "Stream user changes as server-sent events",
+		Params: []Param{lastEventIDParam}, Status: http.StatusOK,
+		Response: User{}, ContentType: "text/event-stream",
+		Handler: public(UserEvents(cfg.events, cfg.eventsCfg)),
+	})
+	rt.Handle(Route{
+		Method: http.MethodGet, Pattern: "/api/v1/users/{id}",
+		OperationID: "getUser", Summary: "Get a user",
+		Params: []Param{ifNoneMatchParam}, Status: http.StatusOK, Response: Response[User]{},
+		Handler: public(http.HandlerFunc(handler.GetUser)),
+	})
+	rt.Handle(Route{
+		Method: http.MethodPut, Pattern: "/api/v1/users/{id}",
+		OperationID: "replaceUser", Summary: "Replace a user", Auth: true, Scopes: writeScopes,
+		Params:  []Param{ifMatchParam},
+		Request: map[string]any{"application/json": UpdateUserRequest{}},
+		Status:  http.StatusOK, Response: Response[User]{},
+		Handler: protected(http.HandlerFunc(handler.UpdateUser)),
+	})
+	rt.Handle(Route{
+		Method: http.MethodPatch, Pattern: "/api/v1/users/{id}",
+		OperationID: "patchUser", Summary: "Partially update a user", Auth: true, Scopes: writeScopes,
+		Params: []Param{ifMatchParam},
+		Request: map[string]any{
+			MediaTypeMergePatch: UpdateUserRequest{},
+			MediaTypeJSONPatch:  []JSONPatchOp{},
+		},
+		Status: http.StatusOK, Response: Response[User]{},
+		Handler: protected(http.HandlerFunc(handler.PatchUser)),
+	})
+	rt.Handle(Route{
+		Method: http.MethodDelete, Pattern: "/api/v1/users/{id}",
+		OperationID: "deleteUser", Summary: "Delete a user", Auth: true, Scopes: writeScopes,
+		Params: []Param{ifMatchParam}, Status: http.StatusNoContent,
+		Handler: protected(http.HandlerFunc(handler.DeleteUser)),
+	})
+
+	rt.Handle(Route{
+		Method: http.MethodPost, Pattern: "/api/v1/users:batch",
+		OperationID: "batchUsers", Summary: "Create, update and delete users in one request", Auth: true, Scopes: writeScopes,
+		Params:  []Param{idempotencyKeyParam},
+		Request: map[string]any{"application/json": BatchRequest{}},
+		Status:  http.StatusMultiStatus, Response: Response[[]BatchResult]{},
+		Handler: idempotent(http.HandlerFunc(handler.BatchUsers)),
+	})
+
+	// ── v2 路由 ─────────────────────────────────────────
+	// 处理器与 v1 相同，差异只在线上格式: v2 的 email 收在 contact 下，见 DefaultVersions。
+	// 任一版本的 URL 都可以用 Accept: application/vnd.gonotes.v2+json 显式选择版本。
+	publicV2 := publicFor("v2")
+	rt.Handle(Route{
+		Method: http.MethodGet, Pattern: "/api/v2/users",
+		OperationID: "listUsersV2", Summary: "List users (v2)",
+		Params: listUsersParams, Status: http.StatusOK, Response: Response[[]UserV2]{},
+		Handler: publicV2(http.HandlerFunc(handler.ListUsers)),
+	})
+	rt.Handle(Route{
+		Method: http.MethodGet, Pattern: "/api/v2/users/{id}",
+		OperationID: "getUserV2", Summary: "Get a user (v2)",
+		Params: []Param{ifNoneMatchParam}, Status: http.StatusOK, Response: Response[UserV2]{},
+		Handler: publicV2(http.HandlerFunc(handler.GetUser)),
+	})
 
 	// ── 健康检查 ────────────────────────────────────────
-	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
-		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	// 探针不经过中间件链: 不限流、不记访问日志。/healthz 是 /livez 的别名，保留给老的探针配置。
+	rt.Handle(Route{
+		Method: http.MethodGet, Pattern: "/livez",
+		OperationID: "livez", Summary: "Liveness probe",
+		Status: http.StatusOK, Response: map[string]string{},
+		Handler: http.HandlerFunc(cfg.health.serveLive),
 	})
+	rt.Handle(Route{
+		Method: http.MethodGet, Pattern: "/readyz",
+		OperationID: "readyz", Summary: "Readiness probe; 503 while starting or shutting down",
+		Status: http.StatusOK, Response: map[string]string{},
+		Handler: http.HandlerFunc(cfg.health.serveReady),
+	})
+	rt.Handle(Route{
+		Method: http.MethodGet, Pattern: "/healthz",
+		OperationID: "healthz", Summary: "Liveness probe (alias of /livez)",
+		Status: http.StatusOK, Response: map[string]string{},
+		Handler: http.HandlerFunc(cfg.health.serveLive),
+	})
+
+	// ── CORS 预检 ───────────────────────────────────────
+	// 每个路径注册一个 OPTIONS 路由，Access-Control-Allow-Methods 就是该路径上实际注册的方法。
+	// 预检不经过限流和认证: 浏览器发送预检时不会带凭证。
+	var patterns []string
+	methods := make(map[string][]string)
+	for _, route := range rt.Routes() {
+		if methods[route.Pattern] == nil {
+			patterns = append(patterns, route.Pattern)
+		}
+		methods[route.Pattern] = append(methods[route.Pattern], route.Method)
+	}
+	preflight := Chain(base, LoggingWith(cfg.logger, nil))
+	for _, pattern := range patterns {
+		rt.mux.Handle(http.MethodOptions+" "+pattern, preflight(cfg.cors.Preflight(methods[pattern]...)))
+	}
+
+	// ── API 文档 ────────────────────────────────────────
+	// 文档本身不计入注册表，直接挂到底层 mux。
+	rt.mux.Handle("GET /openapi.json", rt.ServeOpenAPI(OpenAPIInfo{Title: "go-notes users API", Version: "1.0.0"}))
 
-	return mux
+	// ── 指标 ────────────────────────────────────────────
+	// 指标端点需显式开启（WithMetricsEndpoint），不经过中间件链，避免抓取请求本身污染指标。
+	if cfg.metricsPath {
+		rt.mux.Handle("GET /metrics", cfg.metrics)
+	}
+
+	return rt
 }
+
+// 路由共用的参数声明。
+var (
+	listUsersParams = []Param{
+		{Name: "limit", In: "query", Schema: 0, Description: "page size, 1-100, default 20"},
+		{Name: "offset", In: "query", Schema: 0, Description: "offset pagination; mutually exclusive with cursor"},
+		{Name: "cursor", In: "query", Schema: "", Description: "opaque cursor from meta.next_cursor"},
+		{Name: "sort", In: "query", Schema: "", Description: "comma-separated fields, '-' prefix for descending, e.g. created_at,-name"},
+		{Name: "email", In: "query", Schema: "", Description: "exact email match"},
+		{Name: "name_prefix", In: "query", Schema: "", Description: "case-sensitive name prefix"},
+	}
+	idempotencyKeyParam = Param{Name: "Idempotency-Key", In: "header", Schema: "", Description: "makes retries safe; replays the first response"}
+	ifMatchParam        = Param{Name: "If-Match", In: "header", Schema: "", Description: "ETag from a previous response; 412 on mismatch"}
+	ifNoneMatchParam    = Param{Name: "If-None-Match", In: "header", Schema: "", Description: "ETag from a previous response; 304 if unchanged"}
+	lastEventIDParam    = Param{Name: "Last-Event-ID", In: "header", Schema: "", Description: "id of the last event received; missed events are replayed"}
+)
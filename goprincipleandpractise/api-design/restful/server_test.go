@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestHealthz(t *testing.T) {
@@ -70,7 +71,7 @@ func TestUserCRUD(t *testing.T) {
 	}
 
 	// ── Update ───────────────────────────────────
-	updateBody := `{"name":"Alice Updated"}`
+	updateBody := `{"name":"Alice Updated","email":"alice@example.com","age":31}`
 	req = httptest.NewRequest(http.MethodPut, "/api/v1/users/"+userID, strings.NewReader(updateBody))
 	req.Header.Set("Authorization", auth)
 	rec = httptest.NewRecorder()
@@ -243,19 +244,59 @@ func TestIdempotency(t *testing.T) {
 	}
 }
 
+func TestAuthenticatedRateLimiter(t *testing.T) {
+	srv := NewServer(WithAuthenticatedRateLimiter(NewRateLimiter(1, time.Minute, WithKeyFunc(KeyByPrincipal()))))
+	post := func(auth, email string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(`{"name":"Limited User","email":"`+email+`"}`))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// 认证失败的请求在限流之前被拒绝，不消耗调用方的配额。
+	if got := post("Bearer forged", "a@example.com"); got != http.StatusUnauthorized {
+		t.Fatalf("forged token: status = %d, want 401", got)
+	}
+	if got := post("Bearer demo-token", "b@example.com"); got != http.StatusCreated {
+		t.Fatalf("first request: status = %d, want 201", got)
+	}
+	if got := post("Bearer demo-token", "c@example.com"); got != http.StatusTooManyRequests {
+		t.Fatalf("second request: status = %d, want 429", got)
+	}
+}
+
 func TestCORS(t *testing.T) {
 	srv := NewServer()
 
-	// CORS headers are set on normal requests too (not just OPTIONS preflight).
+	// 实际请求带 Origin 时返回 Allow-Origin，默认策略为任意来源。
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	req.Header.Set("Origin", "https://app.example.com")
 	rec := httptest.NewRecorder()
 	srv.ServeHTTP(rec, req)
 
 	if rec.Header().Get("Access-Control-Allow-Origin") != "*" {
 		t.Error("missing CORS Allow-Origin header")
 	}
-	if rec.Header().Get("Access-Control-Allow-Methods") == "" {
-		t.Error("missing CORS Allow-Methods header")
+	if !strings.Contains(rec.Header().Get("Access-Control-Expose-Headers"), "ETag") {
+		t.Error("ETag not exposed")
+	}
+
+	// 预检不经过认证，Allow-Methods 是该路径上注册的方法。
+	req = httptest.NewRequest(http.MethodOptions, "/api/v1/users/usr_1", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+	req.Header.Set("Access-Control-Request-Headers", "authorization, if-match")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight: expected 204, got %d; body: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "GET, PUT, PATCH, DELETE" {
+		t.Errorf("Allow-Methods = %q", got)
 	}
 }
 
@@ -0,0 +1,366 @@
+package restful
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// userMigrations 是按顺序执行的 schema 迁移，下标 +1 即版本号。
+// 已发布的迁移不可修改，变更 schema 只能在末尾追加新条目。
+var userMigrations = []string{
+	// v1: 用户表。seq 作为自增主键保证插入顺序，id 是对外暴露的业务主键。
+	`CREATE TABLE users (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		id         TEXT    UNIQUE,
+		name       TEXT    NOT NULL,
+		email      TEXT    NOT NULL,
+		age        INTEGER NOT NULL DEFAULT 0,
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL
+	)`,
+	// v2: email 唯一约束，冲突时映射为 ErrConflict。
+	`CREATE UNIQUE INDEX users_email_uq ON users (email)`,
+	// v3: 乐观并发控制的版本号，已有数据从 1 开始。
+	`ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+}
+
+// SQLUserStore 是基于 database/sql 的 UserStore 实现。
+//
+// SQL 使用 ? 占位符，已在 modernc.org/sqlite 驱动下测试。
+// 时间以 UTC Unix 纳秒存储，避免不同驱动对 DATETIME 的解析差异。
+type SQLUserStore struct {
+	db *sql.DB
+	tx *sql.Tx // 非 nil 时所有语句在该事务中执行，见 InTx
+}
+
+// querier 是 *sql.DB 和 *sql.Tx 共有的方法。
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// conn 返回执行语句的连接: 事务中为 tx，否则为 db。
+func (s *SQLUserStore) conn() querier {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+// NewSQLUserStore 创建 SQLUserStore 并执行尚未应用的 schema 迁移。
+// db 的生命周期由调用方管理。
+func NewSQLUserStore(ctx context.Context, db *sql.DB) (*SQLUserStore, error) {
+	s := &SQLUserStore{db: db}
+	if err := s.migrate(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// migrate 用 schema_migrations 表记录已应用的版本，每条迁移在独立事务中执行。
+func (s *SQLUserStore) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for i := current; i < len(userMigrations); i++ {
+		version := i + 1
+		if err := s.applyMigration(ctx, version, userMigrations[i]); err != nil {
+			return fmt.Errorf("apply migration v%d: %w", version, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLUserStore) applyMigration(ctx context.Context, version int, stmt string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, stmt); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const userColumns = `id, name, email, age, version, created_at, updated_at`
+
+// List 将过滤、排序和分页全部下推到 SQL: 游标翻译为 keyset 条件，
+// Total 用同样的过滤条件单独 COUNT。
+func (s *SQLUserStore) List(ctx context.Context, q ListQuery) (UserPage, error) {
+	var (
+		where []string
+		args  []any
+	)
+	if q.Email != "" {
+		where = append(where, "email = ?")
+		args = append(args, q.Email)
+	}
+	if q.NamePrefix != "" {
+		// 不用 LIKE: SQLite 的 LIKE 对 ASCII 不区分大小写，且需转义 % 和 _。
+		where = append(where, "substr(name, 1, ?) = ?")
+		args = append(args, utf8.RuneCountInString(q.NamePrefix), q.NamePrefix)
+	}
+
+	filter := ""
+	if len(where) > 0 {
+		filter = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var page UserPage
+	if err := s.conn().QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM users`+filter, args...).Scan(&page.Total); err != nil {
+		return UserPage{}, fmt.Errorf("count users: %w", err)
+	}
+
+	keys := q.sortKeys()
+	if q.After != nil {
+		cond, condArgs := keysetCondition(keys, q.After)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	order := make([]string, len(keys))
+	for i, k := range keys {
+		order[i] = userSortFields[k.Field].column
+		if k.Desc {
+			order[i] += " DESC"
+		}
+	}
+	query += " ORDER BY " + strings.Join(order, ", ")
+	// 多取一条用于判断 HasMore；SQLite 中 LIMIT -1 表示不限制。
+	limit := -1
+	if q.Limit > 0 {
+		limit = q.Limit + 1
+	}
+	query += " LIMIT ? OFFSET ?"
+	args = append(args, limit, q.Offset)
+
+	rows, err := s.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return UserPage{}, fmt.Errorf("list users: %w", err)
+	}
+	defer rows.Close()
+
+	page.Users = make([]User, 0)
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return UserPage{}, fmt.Errorf("list users: %w", err)
+		}
+		page.Users = append(page.Users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return UserPage{}, fmt.Errorf("list users: %w", err)
+	}
+	if q.Limit > 0 && len(page.Users) > q.Limit {
+		page.Users = page.Users[:q.Limit]
+		page.HasMore = true
+	}
+	return page, nil
+}
+
+// keysetCondition 将游标展开为 (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...，
+// 降序字段使用 <。这样翻页不依赖 OFFSET，数据插入删除时也不会跳过或重复。
+func keysetCondition(keys []SortKey, after []any) (string, []any) {
+	var (
+		ors  []string
+		args []any
+	)
+	for i, k := range keys {
+		var ands []string
+		for j := range i {
+			ands = append(ands, userSortFields[keys[j].Field].column+" = ?")
+			args = append(args, after[j])
+		}
+		op := " > ?"
+		if k.Desc {
+			op = " < ?"
+		}
+		ands = append(ands, userSortFields[k.Field].column+op)
+		args = append(args, after[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+func (s *SQLUserStore) Get(ctx context.Context, id string) (User, error) {
+	row := s.conn().QueryRowContext(ctx,
+		`SELECT `+userColumns+` FROM users WHERE id = ?`, id)
+	u, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("get user %s: %w", id, err)
+	}
+	return u, nil
+}
+
+// Create 在事务中插入用户，再根据自增 seq 回填 id，
+// 使 id 格式与 InMemoryUserStore 保持一致。已在 InTx 中时直接使用外层事务。
+func (s *SQLUserStore) Create(ctx context.Context, user User) (User, error) {
+	if s.tx != nil {
+		return insertUser(ctx, s.tx, user)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return User{}, fmt.Errorf("create user: %w", err)
+	}
+	defer tx.Rollback()
+
+	user, err = insertUser(ctx, tx, user)
+	if err != nil {
+		return User{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return User{}, fmt.Errorf("create user: %w", err)
+	}
+	return user, nil
+}
+
+func insertUser(ctx context.Context, tx *sql.Tx, user User) (User, error) {
+	now := time.Now().UTC()
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO users (name, email, age, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		user.Name, user.Email, user.Age, now.UnixNano(), now.UnixNano())
+	if err != nil {
+		return User{}, mapSQLError("create user", err)
+	}
+	seq, err := res.LastInsertId()
+	if err != nil {
+		return User{}, fmt.Errorf("create user: %w", err)
+	}
+
+	user.ID = idFromSeq(int(seq))
+	user.Version = 1
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET id = ? WHERE seq = ?`, user.ID, seq); err != nil {
+		return User{}, fmt.Errorf("create user: %w", err)
+	}
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	return user, nil
+}
+
+// InTx 在一个数据库事务中执行 fn，fn 返回错误时回滚。已在事务中时直接复用外层事务。
+func (s *SQLUserStore) InTx(ctx context.Context, fn func(tx UserStore) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(&SQLUserStore{db: s.db, tx: tx}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// Update 全量替换可变字段，id 和 created_at 保持不变。
+// 期望版本作为 WHERE 条件，比较与写入在同一条语句中完成。
+func (s *SQLUserStore) Update(ctx context.Context, id string, user User) (User, error) {
+	now := time.Now().UTC()
+	res, err := s.conn().ExecContext(ctx,
+		`UPDATE users SET name = ?, email = ?, age = ?, version = version + 1, updated_at = ?
+		WHERE id = ? AND (? = 0 OR version = ?)`,
+		user.Name, user.Email, user.Age, now.UnixNano(), id, user.Version, user.Version)
+	if err != nil {
+		return User{}, mapSQLError("update user "+id, err)
+	}
+	if err := s.checkAffected(ctx, res, id); err != nil {
+		return User{}, fmt.Errorf("update user %s: %w", id, err)
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *SQLUserStore) Delete(ctx context.Context, id string, version int64) error {
+	res, err := s.conn().ExecContext(ctx,
+		`DELETE FROM users WHERE id = ? AND (? = 0 OR version = ?)`, id, version, version)
+	if err != nil {
+		return fmt.Errorf("delete user %s: %w", id, err)
+	}
+	if err := s.checkAffected(ctx, res, id); err != nil {
+		return fmt.Errorf("delete user %s: %w", id, err)
+	}
+	return nil
+}
+
+// checkAffected 在条件写入未命中任何行时区分"不存在"和"版本不匹配"。
+func (s *SQLUserStore) checkAffected(ctx context.Context, res sql.Result, id string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	var exists bool
+	if err := s.conn().QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrStaleVersion
+	}
+	return ErrUserNotFound
+}
+
+// rowScanner 同时适配 *sql.Row 和 *sql.Rows。
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (User, error) {
+	var (
+		u                    User
+		createdAt, updatedAt int64
+	)
+	if err := row.Scan(&u.ID, &u.Name, &u.Email, &u.Age, &u.Version, &createdAt, &updatedAt); err != nil {
+		return User{}, err
+	}
+	u.CreatedAt = time.Unix(0, createdAt).UTC()
+	u.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	return u, nil
+}
+
+// mapSQLError 将唯一约束冲突映射为 ErrEmailTaken，其余错误附加操作上下文后返回。
+// 驱动错误通过 Code() 暴露 SQLite 扩展错误码。
+func mapSQLError(op string, err error) error {
+	var coder interface{ Code() int }
+	if errors.As(err, &coder) && coder.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return ErrEmailTaken
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
@@ -0,0 +1,186 @@
+package restful
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestSQLStore(t *testing.T) *SQLUserStore {
+	t.Helper()
+	store, err := NewSQLUserStore(context.Background(), openTestDB(t))
+	if err != nil {
+		t.Fatalf("NewSQLUserStore: %v", err)
+	}
+	return store
+}
+
+// userStoreFactories 列出所有 UserStore 实现，契约测试对每个实现运行同一组断言。
+var userStoreFactories = map[string]func(t *testing.T) UserStore{
+	"memory": func(t *testing.T) UserStore { return NewInMemoryUserStore() },
+	"sql":    func(t *testing.T) UserStore { return newTestSQLStore(t) },
+}
+
+func TestUserStoreContract(t *testing.T) {
+	for name, newStore := range userStoreFactories {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+
+			alice, err := store.Create(ctx, User{Name: "Alice", Email: "alice@example.com", Age: 30})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if alice.ID == "" || alice.CreatedAt.IsZero() {
+				t.Fatalf("Create: missing ID or CreatedAt: %+v", alice)
+			}
+
+			if _, err := store.Create(ctx, User{Name: "Alice2", Email: "alice@example.com"}); !errors.Is(err, ErrEmailTaken) {
+				t.Fatalf("Create duplicate email: err = %v, want ErrEmailTaken", err)
+			}
+
+			bob, err := store.Create(ctx, User{Name: "Bob", Email: "bob@example.com"})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if _, err := store.Update(ctx, bob.ID, User{Name: "Bob", Email: "alice@example.com"}); !errors.Is(err, ErrEmailTaken) {
+				t.Fatalf("Update to duplicate email: err = %v, want ErrEmailTaken", err)
+			}
+
+			got, err := store.Get(ctx, alice.ID)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if got.Name != "Alice" || got.Age != 30 || !got.CreatedAt.Equal(alice.CreatedAt) {
+				t.Fatalf("Get: got %+v, want %+v", got, alice)
+			}
+
+			updated, err := store.Update(ctx, alice.ID, User{Name: "Alice Updated", Email: "alice@example.com"})
+			if err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			if updated.Name != "Alice Updated" || updated.Age != 0 || !updated.CreatedAt.Equal(alice.CreatedAt) {
+				t.Fatalf("Update: expected full replacement keeping CreatedAt, got %+v", updated)
+			}
+			if alice.Version != 1 || updated.Version != 2 {
+				t.Fatalf("Update: version %d → %d, want 1 → 2", alice.Version, updated.Version)
+			}
+			stale := User{Name: "Stale", Email: "alice@example.com", Version: alice.Version}
+			if _, err := store.Update(ctx, alice.ID, stale); !errors.Is(err, ErrStaleVersion) {
+				t.Fatalf("Update with stale version: err = %v, want ErrStaleVersion", err)
+			}
+			if err := store.Delete(ctx, alice.ID, alice.Version); !errors.Is(err, ErrStaleVersion) {
+				t.Fatalf("Delete with stale version: err = %v, want ErrStaleVersion", err)
+			}
+
+			page, err := store.List(ctx, ListQuery{})
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if len(page.Users) != 2 || page.Total != 2 {
+				t.Fatalf("List: got %d users (total %d), want 2", len(page.Users), page.Total)
+			}
+
+			if err := store.Delete(ctx, alice.ID, 0); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if err := store.Delete(ctx, alice.ID, 0); !errors.Is(err, ErrUserNotFound) {
+				t.Fatalf("Delete twice: err = %v, want ErrUserNotFound", err)
+			}
+			if _, err := store.Get(ctx, alice.ID); !errors.Is(err, ErrUserNotFound) {
+				t.Fatalf("Get deleted: err = %v, want ErrUserNotFound", err)
+			}
+			if _, err := store.Update(ctx, "nonexistent", User{Name: "X"}); !errors.Is(err, ErrUserNotFound) {
+				t.Fatalf("Update missing: err = %v, want ErrUserNotFound", err)
+			}
+		})
+	}
+}
+
+func TestSQLUserStoreMigrationsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	for i := range 2 {
+		if _, err := NewSQLUserStore(ctx, db); err != nil {
+			t.Fatalf("NewSQLUserStore #%d: %v", i+1, err)
+		}
+	}
+
+	var version int
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		t.Fatalf("read version: %v", err)
+	}
+	if version != len(userMigrations) {
+		t.Fatalf("schema version = %d, want %d", version, len(userMigrations))
+	}
+}
+
+func TestSQLUserStoreContextCanceled(t *testing.T) {
+	store := newTestSQLStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := store.List(ctx, ListQuery{}); err == nil {
+		t.Fatal("List with canceled context: expected error, got nil")
+	}
+}
+
+// TestSQLUserStorePersistsAcrossServers 模拟服务重启：新的 NewServer 仍能读到之前创建的用户。
+func TestSQLUserStorePersistsAcrossServers(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	store, err := NewSQLUserStore(ctx, db)
+	if err != nil {
+		t.Fatalf("NewSQLUserStore: %v", err)
+	}
+	srv := NewServer(WithUserStore(store))
+
+	body := `{"name":"Alice","email":"alice@example.com"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer demo-token")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d; body: %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer demo-token")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate create: expected 409, got %d; body: %s", rec.Code, rec.Body.String())
+	}
+
+	restarted, err := NewSQLUserStore(ctx, db)
+	if err != nil {
+		t.Fatalf("NewSQLUserStore after restart: %v", err)
+	}
+	srv = NewServer(WithUserStore(restarted))
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/users/"+idFromSeq(1), nil)
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get after restart: expected 200, got %d; body: %s", rec.Code, rec.Body.String())
+	}
+}
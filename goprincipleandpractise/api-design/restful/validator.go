@@ -1,16 +1,53 @@
 package restful
 
 import (
+	"cmp"
 	"fmt"
 	"net/mail"
+	"net/url"
 	"reflect"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
+// FieldError 是单个字段的校验错误。
+// Rule 是机器可读的规则名，Message 是面向用户的消息（Validate 生成英文，响应时按请求语言重新渲染）。
+type FieldError struct {
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+	kind    reflect.Kind
+}
+
+// ValidationErrors 是字段路径→校验错误的映射。
+type ValidationErrors map[string]FieldError
+
 // Validate 基于 struct tag `validate` 校验结构体字段。
-// 支持规则: required, email, min=N, max=N
-// 返回字段名→错误消息的映射，空 map 表示全部通过。
+// 返回字段路径→校验错误的映射，空 map 表示全部通过。每个字段只报第一个错误。
+//
+// 内置规则:
+//
+//	required          非零值；指针字段只要求非 nil
+//	min=N, max=N      字符串按字符数、集合按元素个数、数值按取值（支持 int/uint/float）
+//	len=N             同上，要求恰好等于 N
+//	oneof=a b c       取值必须是空格分隔的候选之一（字符串或整数）
+//	email, url, uuid  格式校验
+//	regexp=PATTERN    必须匹配正则；必须是最后一条规则，参数可以包含逗号
+//	eqfield=F         与同一结构体的字段 F 相等
+//	gtfield=F         大于同一结构体的字段 F（数值或 time.Time）
+//	dive              之后的规则作用于 slice/array/map 的每个元素，可以嵌套
+//
+// 格式类规则（email、url、uuid、regexp、oneof）跳过空字符串，由 required 负责检查空值。
+// 结构体字段（含指针）会自动递归校验，错误以 address.city、items[0].name 这样的路径报告；
+// 集合中的结构体需要 dive 才会递归。
+//
+// 规则按类型解析一次并缓存。tag 中出现未知规则或非法参数属于编程错误，Validate 会 panic，
+// 可以在启动时用 CompileRules 提前发现（Router.Handle 会对请求体类型自动检查）。
 //
 // 示例用法:
 //
@@ -19,129 +56,497 @@ import (
 //	    Email string `json:"email" validate:"required,email"`
 //	    Age   int    `json:"age"   validate:"min=0,max=150"`
 //	}
-func Validate(v any) map[string]string {
-	errs := make(map[string]string)
+func Validate(v any) ValidationErrors {
+	errs := make(ValidationErrors)
 	val := reflect.ValueOf(v)
-	typ := val.Type()
 
 	// 支持指针
 	if val.Kind() == reflect.Ptr {
 		if val.IsNil() {
-			errs["_"] = "input must not be nil"
+			errs["_"] = FieldError{Message: "input must not be nil"}
 			return errs
 		}
 		val = val.Elem()
-		typ = val.Type()
 	}
 
 	if val.Kind() != reflect.Struct {
-		errs["_"] = "input must be a struct"
+		errs["_"] = FieldError{Message: "input must be a struct"}
 		return errs
 	}
 
-	for i := range val.NumField() {
-		field := typ.Field(i)
-		tag := field.Tag.Get("validate")
-		if tag == "" || tag == "-" {
+	tr := rulesFor(val.Type())
+	if tr.err != nil {
+		panic(tr.err)
+	}
+	validateStruct(val, tr, "", errs)
+	return errs
+}
+
+// CompileRules 解析并缓存 v 的类型（及其嵌套类型）上的 validate 规则，
+// 返回遇到的第一个未知规则或非法参数。v 不是结构体时没有可检查的规则，返回 nil。
+func CompileRules(v any) error {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	return rulesFor(t).err
+}
+
+// ── 规则注册 ────────────────────────────────────────
+
+// FieldContext 是传给校验规则的上下文。
+type FieldContext struct {
+	Value  reflect.Value // 字段值，指针已解引用
+	Param  string        // 规则参数，例如 min=2 中的 "2"
+	Parent reflect.Value // 字段所在的结构体，用于跨字段规则
+}
+
+// RuleFunc 是一条校验规则，返回 false 表示校验失败。
+type RuleFunc func(fc FieldContext) bool
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]RuleFunc{
+		"required": ruleRequired,
+		"min":      ruleMin,
+		"max":      ruleMax,
+		"len":      ruleLen,
+		"oneof":    ruleOneOf,
+		"email":    ruleEmail,
+		"url":      ruleURL,
+		"uuid":     ruleUUID,
+		"regexp":   ruleRegexp,
+		"eqfield":  ruleEqField,
+		"gtfield":  ruleGtField,
+	}
+)
+
+// RegisterRule 注册自定义规则，同名时覆盖已有规则（包括内置规则）。
+// 自定义规则对空值同样生效，需要时自行跳过零值。
+//
+//	RegisterRule("even", func(fc FieldContext) bool { return fc.Value.Int()%2 == 0 })
+func RegisterRule(name string, fn RuleFunc) {
+	if name == "" || name == "dive" || strings.ContainsAny(name, ",=") || fn == nil {
+		panic(fmt.Sprintf("validate: invalid rule registration %q", name))
+	}
+	rulesMu.Lock()
+	rules[name] = fn
+	rulesMu.Unlock()
+	// 已缓存的类型可能引用了旧规则或因规则未注册而编译失败。
+	ruleCache.Clear()
+}
+
+func lookupRule(name string) (RuleFunc, bool) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	fn, ok := rules[name]
+	return fn, ok
+}
+
+// ── 规则解析与缓存 ──────────────────────────────────
+
+// typeRules 是一个结构体类型解析后的规则。
+type typeRules struct {
+	fields []fieldRules
+	err    error
+}
+
+type fieldRules struct {
+	index int
+	name  string // JSON 字段名
+	chain *ruleChain
+}
+
+// ruleChain 是作用于一个值的规则；dive 非 nil 时，其中的规则作用于集合元素。
+type ruleChain struct {
+	rules []boundRule
+	dive  *ruleChain
+}
+
+type boundRule struct {
+	name  string
+	param string
+	fn    RuleFunc
+}
+
+var ruleCache sync.Map // reflect.Type → *typeRules
+
+func rulesFor(t reflect.Type) *typeRules {
+	if tr, ok := ruleCache.Load(t); ok {
+		return tr.(*typeRules)
+	}
+	tr := compileType(t, make(map[reflect.Type]*typeRules))
+	actual, _ := ruleCache.LoadOrStore(t, tr)
+	return actual.(*typeRules)
+}
+
+// compileType 解析 t 的规则，并检查所有会被递归校验的嵌套类型，使错误在顶层类型上就能发现。
+// seen 用于处理递归类型。
+func compileType(t reflect.Type, seen map[reflect.Type]*typeRules) *typeRules {
+	if tr, ok := seen[t]; ok {
+		return tr
+	}
+	tr := &typeRules{}
+	seen[t] = tr
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("validate")
+		if !f.IsExported() || tag == "-" {
 			continue
 		}
 
-		fieldVal := val.Field(i)
-		fieldName := jsonFieldName(field)
-		rules := strings.Split(tag, ",")
+		chain, err := parseChain(tag, f.Type, t)
+		if err != nil {
+			tr.err = fmt.Errorf("validate: %s.%s: %w", t.Name(), f.Name, err)
+			return tr
+		}
+		tr.fields = append(tr.fields, fieldRules{index: i, name: jsonFieldName(f), chain: chain})
 
-		for _, rule := range rules {
-			if errMsg := applyRule(rule, fieldVal, fieldName); errMsg != "" {
-				errs[fieldName] = errMsg
-				break // 每个字段只报第一个错误
+		// 嵌套结构体（字段本身或 dive 之后的元素）会被递归校验，一并检查。
+		typ := f.Type
+		for c := chain; ; c = c.dive {
+			if st := indirectType(typ); st.Kind() == reflect.Struct {
+				if nested := compileType(st, seen); nested.err != nil && tr.err == nil {
+					tr.err = nested.err
+				}
+			}
+			if c.dive == nil {
+				break
 			}
+			typ = indirectType(typ).Elem()
+		}
+		if tr.err != nil {
+			return tr
 		}
 	}
+	return tr
+}
 
-	return errs
+func parseChain(tag string, t reflect.Type, parent reflect.Type) (*ruleChain, error) {
+	chain := &ruleChain{}
+	cur := chain
+	for _, tok := range splitRules(tag) {
+		name, param, _ := strings.Cut(tok, "=")
+		if name == "dive" {
+			switch indirectType(t).Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+			default:
+				return nil, fmt.Errorf("dive on non-collection type %s", t)
+			}
+			t = indirectType(t).Elem()
+			cur.dive = &ruleChain{}
+			cur = cur.dive
+			continue
+		}
+
+		fn, ok := lookupRule(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		if err := checkRuleParam(name, param, indirectType(t), parent); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", tok, err)
+		}
+		cur.rules = append(cur.rules, boundRule{name: name, param: param, fn: fn})
+	}
+	return chain, nil
 }
 
-func jsonFieldName(f reflect.StructField) string {
-	jsonTag := f.Tag.Get("json")
-	if jsonTag == "" || jsonTag == "-" {
-		return f.Name
+// splitRules 按逗号拆分 tag。regexp 的参数取 tag 剩余全部内容，因此可以包含逗号。
+func splitRules(tag string) []string {
+	var toks []string
+	for tag != "" {
+		if strings.HasPrefix(tag, "regexp=") {
+			return append(toks, tag)
+		}
+		var tok string
+		tok, tag, _ = strings.Cut(tag, ",")
+		if tok != "" {
+			toks = append(toks, tok)
+		}
 	}
-	name, _, _ := strings.Cut(jsonTag, ",")
-	return name
+	return toks
 }
 
-func applyRule(rule string, val reflect.Value, name string) string {
-	switch {
-	case rule == "required":
-		return checkRequired(val, name)
-	case rule == "email":
-		return checkEmail(val, name)
-	case strings.HasPrefix(rule, "min="):
-		return checkMin(rule, val, name)
-	case strings.HasPrefix(rule, "max="):
-		return checkMax(rule, val, name)
-	default:
-		return ""
+// checkRuleParam 在解析阶段检查内置规则的参数和适用类型，避免把配置错误拖到请求时才暴露。
+func checkRuleParam(name, param string, t reflect.Type, parent reflect.Type) error {
+	switch name {
+	case "min", "max", "len":
+		switch {
+		case isNumberKind(t.Kind()):
+			if _, err := strconv.ParseFloat(param, 64); err != nil {
+				return fmt.Errorf("invalid number %q", param)
+			}
+		case t.Kind() == reflect.String || hasLength(t.Kind()):
+			if _, err := strconv.Atoi(param); err != nil {
+				return fmt.Errorf("invalid length %q", param)
+			}
+		default:
+			return fmt.Errorf("unsupported type %s", t)
+		}
+	case "oneof":
+		if param == "" {
+			return fmt.Errorf("no candidates")
+		}
+		if t.Kind() != reflect.String && !isIntKind(t.Kind()) && !isUintKind(t.Kind()) {
+			return fmt.Errorf("unsupported type %s", t)
+		}
+	case "email", "url", "uuid":
+		if t.Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", t)
+		}
+	case "regexp":
+		if t.Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", t)
+		}
+		if _, err := compileRegexp(param); err != nil {
+			return err
+		}
+	case "eqfield", "gtfield":
+		if _, ok := parent.FieldByName(param); !ok {
+			return fmt.Errorf("no field %q in %s", param, parent)
+		}
 	}
+	return nil
 }
 
-func checkRequired(val reflect.Value, name string) string {
-	if val.IsZero() {
-		return fmt.Sprintf("%s is required", name)
+// ── 校验执行 ────────────────────────────────────────
+
+func validateStruct(val reflect.Value, tr *typeRules, prefix string, errs ValidationErrors) {
+	for _, f := range tr.fields {
+		applyChain(val.Field(f.index), f.chain, val, prefix+f.name, errs)
 	}
-	return ""
 }
 
-func checkEmail(val reflect.Value, name string) string {
-	if val.Kind() != reflect.String {
-		return ""
+func applyChain(val reflect.Value, c *ruleChain, parent reflect.Value, path string, errs ValidationErrors) {
+	v := val
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			break
+		}
+		v = v.Elem()
 	}
-	s := val.String()
-	if s == "" {
-		return "" // required 规则负责检查空值
+	isNil := (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil()
+
+	for _, r := range c.rules {
+		fc := FieldContext{Value: v, Param: r.param, Parent: parent}
+		if r.name == "required" {
+			fc.Value = val // 指针字段只要求非 nil
+		} else if isNil {
+			continue
+		}
+		if !r.fn(fc) {
+			errs[path] = FieldError{
+				Rule:    r.name,
+				Param:   r.param,
+				Message: builtinCatalog.RuleMessage(DefaultLanguage, path, r.name, r.param, v.Kind()),
+				kind:    v.Kind(),
+			}
+			return
+		}
+	}
+	if isNil {
+		return
+	}
+
+	if c.dive != nil {
+		switch v.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := range v.Len() {
+				applyChain(v.Index(i), c.dive, parent, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		case reflect.Map:
+			iter := v.MapRange()
+			for iter.Next() {
+				applyChain(iter.Value(), c.dive, parent, fmt.Sprintf("%s[%v]", path, iter.Key()), errs)
+			}
+		}
 	}
-	if _, err := mail.ParseAddress(s); err != nil {
-		return fmt.Sprintf("%s must be a valid email address", name)
+
+	if v.Kind() == reflect.Struct {
+		validateStruct(v, rulesFor(v.Type()), path+".", errs)
 	}
-	return ""
 }
 
-func checkMin(rule string, val reflect.Value, name string) string {
-	minStr := strings.TrimPrefix(rule, "min=")
-	minVal, err := strconv.ParseInt(minStr, 10, 64)
-	if err != nil {
-		return ""
+// ── 内置规则 ────────────────────────────────────────
+
+func ruleRequired(fc FieldContext) bool {
+	return fc.Value.IsValid() && !fc.Value.IsZero()
+}
+
+func ruleMin(fc FieldContext) bool {
+	return compareToParam(fc.Value, fc.Param) >= 0
+}
+
+func ruleMax(fc FieldContext) bool {
+	return compareToParam(fc.Value, fc.Param) <= 0
+}
+
+func ruleLen(fc FieldContext) bool {
+	return compareToParam(fc.Value, fc.Param) == 0
+}
+
+// compareToParam 比较 v 的"大小"与参数: 字符串比较字符数，集合比较元素个数，数值比较取值。
+func compareToParam(v reflect.Value, param string) int {
+	switch k := v.Kind(); {
+	case k == reflect.String:
+		n, _ := strconv.Atoi(param)
+		return cmp.Compare(int64(utf8.RuneCountInString(v.String())), int64(n))
+	case hasLength(k):
+		n, _ := strconv.Atoi(param)
+		return cmp.Compare(int64(v.Len()), int64(n))
+	case isIntKind(k):
+		n, _ := strconv.ParseFloat(param, 64)
+		return cmp.Compare(float64(v.Int()), n)
+	case isUintKind(k):
+		n, _ := strconv.ParseFloat(param, 64)
+		return cmp.Compare(float64(v.Uint()), n)
+	default:
+		n, _ := strconv.ParseFloat(param, 64)
+		return cmp.Compare(v.Float(), n)
 	}
+}
 
-	switch val.Kind() {
-	case reflect.String:
-		if int64(len(val.String())) < minVal {
-			return fmt.Sprintf("%s must be at least %d characters", name, minVal)
-		}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if val.Int() < minVal {
-			return fmt.Sprintf("%s must be at least %d", name, minVal)
+func ruleOneOf(fc FieldContext) bool {
+	var s string
+	switch k := fc.Value.Kind(); {
+	case k == reflect.String:
+		s = fc.Value.String()
+		if s == "" {
+			return true
 		}
+	case isIntKind(k):
+		s = strconv.FormatInt(fc.Value.Int(), 10)
+	default:
+		s = strconv.FormatUint(fc.Value.Uint(), 10)
 	}
-	return ""
+	return slices.Contains(strings.Fields(fc.Param), s)
 }
 
-func checkMax(rule string, val reflect.Value, name string) string {
-	maxStr := strings.TrimPrefix(rule, "max=")
-	maxVal, err := strconv.ParseInt(maxStr, 10, 64)
+func ruleEmail(fc FieldContext) bool {
+	s := fc.Value.String()
+	if s == "" {
+		return true
+	}
+	_, err := mail.ParseAddress(s)
+	return err == nil
+}
+
+func ruleURL(fc FieldContext) bool {
+	s := fc.Value.String()
+	if s == "" {
+		return true
+	}
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+var uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func ruleUUID(fc FieldContext) bool {
+	s := fc.Value.String()
+	return s == "" || uuidRe.MatchString(s)
+}
+
+var regexpCache sync.Map // pattern → *regexp.Regexp
+
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexpCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
 	if err != nil {
-		return ""
+		return nil, err
 	}
+	regexpCache.Store(pattern, re)
+	return re, nil
+}
 
-	switch val.Kind() {
-	case reflect.String:
-		if int64(len(val.String())) > maxVal {
-			return fmt.Sprintf("%s must be at most %d characters", name, maxVal)
-		}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if val.Int() > maxVal {
-			return fmt.Sprintf("%s must be at most %d", name, maxVal)
-		}
+func ruleRegexp(fc FieldContext) bool {
+	s := fc.Value.String()
+	if s == "" {
+		return true
+	}
+	re, err := compileRegexp(fc.Param)
+	return err == nil && re.MatchString(s)
+}
+
+func ruleEqField(fc FieldContext) bool {
+	other := fc.Parent.FieldByName(fc.Param)
+	for other.Kind() == reflect.Pointer && !other.IsNil() {
+		other = other.Elem()
+	}
+	return other.IsValid() && other.Type() == fc.Value.Type() && other.Equal(fc.Value)
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+func ruleGtField(fc FieldContext) bool {
+	other := fc.Parent.FieldByName(fc.Param)
+	for other.Kind() == reflect.Pointer && !other.IsNil() {
+		other = other.Elem()
+	}
+	if !other.IsValid() || other.Kind() == reflect.Pointer {
+		return false
+	}
+	v := fc.Value
+	switch {
+	case v.Type() == timeType && other.Type() == timeType:
+		return v.Interface().(time.Time).After(other.Interface().(time.Time))
+	case isIntKind(v.Kind()) && isIntKind(other.Kind()):
+		return v.Int() > other.Int()
+	case isUintKind(v.Kind()) && isUintKind(other.Kind()):
+		return v.Uint() > other.Uint()
+	case isNumberKind(v.Kind()) && isNumberKind(other.Kind()):
+		return toFloat(v) > toFloat(other)
+	default:
+		return false
+	}
+}
+
+// ── 辅助函数 ────────────────────────────────────────
+
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func isIntKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUintKind(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uintptr
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	return isIntKind(k) || isUintKind(k) || k == reflect.Float32 || k == reflect.Float64
+}
+
+func hasLength(k reflect.Kind) bool {
+	return k == reflect.Slice || k == reflect.Array || k == reflect.Map
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch {
+	case isIntKind(v.Kind()):
+		return float64(v.Int())
+	case isUintKind(v.Kind()):
+		return float64(v.Uint())
+	default:
+		return v.Float()
 	}
-	return ""
 }
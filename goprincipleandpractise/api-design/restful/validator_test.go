@@ -0,0 +1,219 @@
+package restful
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testAddress struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip"  validate:"len=5,regexp=^[0-9]+$"`
+}
+
+type testOrder struct {
+	ID       string             `json:"id"       validate:"required,uuid"`
+	Status   string             `json:"status"   validate:"oneof=pending paid shipped"`
+	Priority int                `json:"priority" validate:"oneof=1 2 3"`
+	Callback string             `json:"callback" validate:"url"`
+	Code     string             `json:"code"     validate:"regexp=^[A-Z]{2,3}$"`
+	Price    float64            `json:"price"    validate:"min=0.01,max=9999.99"`
+	Quantity uint               `json:"quantity" validate:"min=1,max=10"`
+	Tags     []string           `json:"tags"     validate:"max=3,dive,required,min=2"`
+	Items    []testItem         `json:"items"    validate:"required,dive"`
+	Labels   map[string]float32 `json:"labels"   validate:"dive,min=0"`
+	Address  *testAddress       `json:"address"`
+	Password string             `json:"password" validate:"min=8"`
+	Confirm  string             `json:"confirm"  validate:"eqfield=Password"`
+	StartsAt time.Time          `json:"starts_at"`
+	EndsAt   time.Time          `json:"ends_at"  validate:"gtfield=StartsAt"`
+}
+
+type testItem struct {
+	SKU string `json:"sku" validate:"required,len=4"`
+}
+
+func validOrder() testOrder {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return testOrder{
+		ID:       "0f8fad5b-d9cb-469f-a165-70867728950e",
+		Status:   "paid",
+		Priority: 2,
+		Callback: "https://example.com/hook",
+		Code:     "CNY",
+		Price:    9.5,
+		Quantity: 3,
+		Tags:     []string{"go", "api"},
+		Items:    []testItem{{SKU: "A001"}},
+		Labels:   map[string]float32{"weight": 1.5},
+		Address:  &testAddress{City: "Beijing", Zip: "10000"},
+		Password: "s3cret-pass",
+		Confirm:  "s3cret-pass",
+		StartsAt: start,
+		EndsAt:   start.Add(time.Hour),
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(o *testOrder)
+		field  string // 期望出错的字段路径，空表示全部通过
+		msg    string // 期望消息包含的片段
+	}{
+		{"valid", func(o *testOrder) {}, "", ""},
+		{"empty optional formats", func(o *testOrder) {
+			o.Status, o.Callback, o.Code, o.Address = "", "", "", nil
+		}, "", ""},
+		{"uuid", func(o *testOrder) { o.ID = "not-a-uuid" }, "id", "valid UUID"},
+		{"oneof string", func(o *testOrder) { o.Status = "lost" }, "status", "one of [pending paid shipped]"},
+		{"oneof int", func(o *testOrder) { o.Priority = 5 }, "priority", "one of"},
+		{"url relative", func(o *testOrder) { o.Callback = "/hook" }, "callback", "valid URL"},
+		{"regexp", func(o *testOrder) { o.Code = "cny" }, "code", "must match"},
+		{"float min", func(o *testOrder) { o.Price = 0 }, "price", "at least 0.01"},
+		{"float max", func(o *testOrder) { o.Price = 10000 }, "price", "at most 9999.99"},
+		{"uint min", func(o *testOrder) { o.Quantity = 0 }, "quantity", "at least 1"},
+		{"uint max", func(o *testOrder) { o.Quantity = 11 }, "quantity", "at most 10"},
+		{"collection max", func(o *testOrder) { o.Tags = []string{"aa", "bb", "cc", "dd"} }, "tags", "at most 3 items"},
+		{"dive slice", func(o *testOrder) { o.Tags = []string{"go", "x"} }, "tags[1]", "at least 2 characters"},
+		{"dive required", func(o *testOrder) { o.Tags = []string{""} }, "tags[0]", "is required"},
+		{"dive struct", func(o *testOrder) { o.Items = []testItem{{SKU: "A001"}, {SKU: "B2"}} }, "items[1].sku", "exactly 4 characters"},
+		{"required slice", func(o *testOrder) { o.Items = nil }, "items", "is required"},
+		{"dive map", func(o *testOrder) { o.Labels = map[string]float32{"weight": -1} }, "labels[weight]", "at least 0"},
+		{"nested pointer struct", func(o *testOrder) { o.Address.City = "" }, "address.city", "is required"},
+		{"nested len", func(o *testOrder) { o.Address.Zip = "123" }, "address.zip", "exactly 5 characters"},
+		{"eqfield", func(o *testOrder) { o.Confirm = "other" }, "confirm", "must equal Password"},
+		{"gtfield time", func(o *testOrder) { o.EndsAt = o.StartsAt }, "ends_at", "greater than StartsAt"},
+		{"min counts characters", func(o *testOrder) { o.Password, o.Confirm = "密码密码密码密", "密码密码密码密" }, "password", "at least 8 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := validOrder()
+			tt.mutate(&o)
+			errs := Validate(o)
+			if tt.field == "" {
+				if len(errs) != 0 {
+					t.Fatalf("expected no errors, got %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 {
+				t.Fatalf("expected exactly one error on %q, got %v", tt.field, errs)
+			}
+			if fe, ok := errs[tt.field]; !ok || !strings.Contains(fe.Message, tt.msg) {
+				t.Errorf("errs[%q] = %q, want it to contain %q (all: %v)", tt.field, fe.Message, tt.msg, errs)
+			}
+		})
+	}
+}
+
+func TestValidatePointerRequired(t *testing.T) {
+	type req struct {
+		Age *int `json:"age" validate:"required,min=18"`
+	}
+	zero, adult := 0, 30
+	if errs := Validate(req{}); errs["age"].Rule != "required" {
+		t.Errorf("nil pointer: expected required error, got %v", errs)
+	}
+	// 指针非 nil 即满足 required，后续规则作用于指向的值。
+	if errs := Validate(req{Age: &zero}); errs["age"].Rule != "min" {
+		t.Errorf("pointer to 0: expected min error, got %v", errs)
+	}
+	if errs := Validate(req{Age: &adult}); len(errs) != 0 {
+		t.Errorf("pointer to 30: expected no errors, got %v", errs)
+	}
+}
+
+func TestRegisterRule(t *testing.T) {
+	type req struct {
+		N int `json:"n" validate:"even_test"`
+	}
+	if err := CompileRules(req{}); err == nil || !strings.Contains(err.Error(), `unknown rule "even_test"`) {
+		t.Fatalf("before registration: err = %v, want unknown rule", err)
+	}
+
+	RegisterRule("even_test", func(fc FieldContext) bool { return fc.Value.Int()%2 == 0 })
+	// 注册表是全局的: 测试结束后移除规则并清空缓存，-count=N 重复运行时 "before registration" 依然成立。
+	t.Cleanup(func() {
+		rulesMu.Lock()
+		delete(rules, "even_test")
+		rulesMu.Unlock()
+		ruleCache.Clear()
+	})
+	if err := CompileRules(req{}); err != nil {
+		t.Fatalf("after registration: %v", err)
+	}
+	if errs := Validate(req{N: 3}); errs["n"] != (FieldError{Rule: "even_test", Message: "n failed even_test validation", kind: reflect.Int}) {
+		t.Errorf("errs = %v", errs)
+	}
+	if errs := Validate(req{N: 4}); len(errs) != 0 {
+		t.Errorf("errs = %v", errs)
+	}
+}
+
+func TestInvalidRulesReported(t *testing.T) {
+	tests := []struct {
+		name  string
+		input any
+		want  string
+	}{
+		{"unknown rule", struct {
+			A string `validate:"required,emial"`
+		}{}, `unknown rule "emial"`},
+		{"bad number", struct {
+			A int `validate:"min=abc"`
+		}{}, "invalid number"},
+		{"bad regexp", struct {
+			A string `validate:"regexp=("`
+		}{}, "missing closing )"},
+		{"format on int", struct {
+			A int `validate:"email"`
+		}{}, "unsupported type"},
+		{"missing eqfield target", struct {
+			A string `validate:"eqfield=B"`
+		}{}, `no field "B"`},
+		{"dive on scalar", struct {
+			A string `validate:"dive,required"`
+		}{}, "dive on non-collection"},
+		{"nested struct", struct {
+			Inner struct {
+				B string `validate:"nope"`
+			}
+		}{}, `unknown rule "nope"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CompileRules(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("CompileRules err = %v, want %q", err, tt.want)
+			}
+			defer func() {
+				if recover() == nil {
+					t.Error("Validate should panic on invalid rules")
+				}
+			}()
+			Validate(tt.input)
+		})
+	}
+}
+
+func TestValidateCachesRulesPerType(t *testing.T) {
+	typ := reflect.TypeFor[testOrder]()
+	Validate(validOrder())
+	first := rulesFor(typ)
+	Validate(validOrder())
+	if rulesFor(typ) != first {
+		t.Error("rules should be compiled once per type and reused")
+	}
+}
+
+func BenchmarkValidate(b *testing.B) {
+	o := validOrder()
+	b.ReportAllocs()
+	for b.Loop() {
+		Validate(o)
+	}
+}
@@ -0,0 +1,233 @@
+package restful
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// vendorMediaTypePrefix 是版本化媒体类型的前缀，完整形式为 application/vnd.gonotes.v2+json。
+const vendorMediaTypePrefix = "application/vnd.gonotes."
+
+// APIVersion 描述一个 API 版本: 名称、弃用时间表，以及从内部类型到该版本线上格式的转换。
+// 处理器只产出内部类型（User），每个版本的差异集中在转换函数里。
+type APIVersion struct {
+	Name        string    // 与 URL 中的版本段一致，例如 "v2"
+	Deprecation time.Time // 非零时响应带 Deprecation 头（RFC 9745）
+	Sunset      time.Time // 非零时响应带 Sunset 头（RFC 8594）
+	Successor   string    // 继任版本名，用于 Link: <...>; rel="successor-version"
+
+	transforms map[reflect.Type]func(any) any
+}
+
+// MediaType 返回该版本的媒体类型。
+func (v *APIVersion) MediaType() string {
+	return vendorMediaTypePrefix + v.Name + "+json"
+}
+
+// RegisterTransform 为版本 v 注册类型 T 的线上格式。切片 []T 按元素转换，无需单独注册。
+func RegisterTransform[T, R any](v *APIVersion, fn func(T) R) {
+	if v.transforms == nil {
+		v.transforms = make(map[reflect.Type]func(any) any)
+	}
+	v.transforms[reflect.TypeFor[T]()] = func(x any) any { return fn(x.(T)) }
+}
+
+// represent 把 data 转换为版本 v 的线上格式，没有注册转换的类型原样返回。
+func (v *APIVersion) represent(data any) any {
+	if v == nil || len(v.transforms) == 0 || data == nil {
+		return data
+	}
+	t := reflect.TypeOf(data)
+	if fn, ok := v.transforms[t]; ok {
+		return fn(data)
+	}
+	if t.Kind() == reflect.Slice {
+		if fn, ok := v.transforms[t.Elem()]; ok {
+			rv := reflect.ValueOf(data)
+			out := make([]any, rv.Len())
+			for i := range out {
+				out[i] = fn(rv.Index(i).Interface())
+			}
+			return out
+		}
+	}
+	return data
+}
+
+// Versions 是服务支持的版本集合。
+type Versions struct {
+	versions map[string]*APIVersion
+}
+
+// NewVersions 创建版本集合。
+func NewVersions(versions ...*APIVersion) *Versions {
+	vs := &Versions{versions: make(map[string]*APIVersion, len(versions))}
+	for _, v := range versions {
+		vs.versions[v.Name] = v
+	}
+	return vs
+}
+
+// Get 返回名为 name 的版本。
+func (vs *Versions) Get(name string) (*APIVersion, bool) {
+	v, ok := vs.versions[name]
+	return v, ok
+}
+
+// Deprecate 标记版本 name 已弃用，并指定下线时间和继任版本，返回 vs 以便链式调用。
+func (vs *Versions) Deprecate(name string, at, sunset time.Time, successor string) *Versions {
+	if v, ok := vs.versions[name]; ok {
+		v.Deprecation, v.Sunset, v.Successor = at, sunset, successor
+	}
+	return vs
+}
+
+// DefaultVersions 返回本服务的版本: v1 是扁平的 User；v2 把联系方式收进 contact 对象。
+func DefaultVersions() *Versions {
+	v2 := &APIVersion{Name: "v2"}
+	RegisterTransform(v2, newUserV2)
+	return NewVersions(&APIVersion{Name: "v1"}, v2)
+}
+
+// UserV2 是 v2 的用户表示。
+type UserV2 struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Contact   Contact   `json:"contact"`
+	Age       int       `json:"age,omitempty"`
+	Version   int64     `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Contact 是 v2 中的联系方式。
+type Contact struct {
+	Email string `json:"email"`
+}
+
+func newUserV2(u User) UserV2 {
+	return UserV2{
+		ID: u.ID, Name: u.Name, Contact: Contact{Email: u.Email}, Age: u.Age,
+		Version: u.Version, CreatedAt: u.CreatedAt, UpdatedAt: u.UpdatedAt,
+	}
+}
+
+// ── 版本协商 ────────────────────────────────────────
+
+type versionKey struct{}
+
+// negotiatedVersion 是请求最终使用的版本，byAccept 表示由 Accept 显式选择。
+type negotiatedVersion struct {
+	*APIVersion
+	byAccept bool
+}
+
+// Middleware 返回版本中间件，urlVersion 是路由 URL 中的版本。
+//
+// 版本选择:
+//   - Accept 中出现 application/vnd.gonotes.* 时按 Accept 协商，响应 Content-Type 为该媒体类型；
+//     请求的版本都不存在时返回 406
+//   - 否则使用 URL 中的版本
+//
+// 已弃用的版本在所有响应（包括错误）上带 Deprecation、Sunset 和 successor-version 链接。
+//
+// 改变了线上格式的版本，其表示的 ETag 带 ;<version> 后缀（见 etagSuffix）；
+// If-Match 比较的是资源版本，在交给处理器之前去掉该后缀。
+func (vs *Versions) Middleware(urlVersion string) Middleware {
+	def, ok := vs.versions[urlVersion]
+	if !ok {
+		panic("restful: unknown API version " + urlVersion)
+	}
+	suffixes := make([]string, 0, len(vs.versions))
+	for _, v := range vs.versions {
+		if s := v.etagSuffix(); s != "" {
+			suffixes = append(suffixes, s)
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept")
+			if v := r.Header.Get("If-Match"); v != "" {
+				for _, s := range suffixes {
+					v = strings.ReplaceAll(v, s+`"`, `"`)
+				}
+				r.Header.Set("If-Match", v)
+			}
+			chosen := negotiatedVersion{APIVersion: def}
+			if accept := r.Header.Get("Accept"); strings.Contains(accept, vendorMediaTypePrefix) {
+				v, ok := vs.fromAccept(accept)
+				if !ok {
+					WriteError(w, r, NewAppError(ErrNotAcceptable, "requested API version is not supported", nil).
+						WithDetail("supported: "+strings.Join(vs.mediaTypes(), ", ")))
+					return
+				}
+				chosen = negotiatedVersion{APIVersion: v, byAccept: true}
+			}
+			setDeprecationHeaders(w.Header(), r, chosen.APIVersion)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), versionKey{}, chosen)))
+		})
+	}
+}
+
+func (vs *Versions) mediaTypes() []string {
+	types := make([]string, 0, len(vs.versions))
+	for _, v := range vs.versions {
+		types = append(types, v.MediaType())
+	}
+	slices.Sort(types)
+	return types
+}
+
+func (vs *Versions) fromAccept(accept string) (*APIVersion, bool) {
+	mt := negotiate(accept, vs.mediaTypes()...)
+	if mt == "" {
+		return nil, false
+	}
+	v, ok := vs.versions[strings.TrimSuffix(strings.TrimPrefix(mt, vendorMediaTypePrefix), "+json")]
+	return v, ok
+}
+
+func setDeprecationHeaders(h http.Header, r *http.Request, v *APIVersion) {
+	if !v.Deprecation.IsZero() {
+		h.Set("Deprecation", "@"+strconv.FormatInt(v.Deprecation.Unix(), 10))
+	}
+	if !v.Sunset.IsZero() {
+		h.Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if v.Successor != "" {
+		prefix := "/api/" + v.Name + "/"
+		if rest, ok := strings.CutPrefix(r.URL.Path, prefix); ok {
+			h.Add("Link", "</api/"+v.Successor+"/"+rest+`>; rel="successor-version"`)
+		}
+	}
+}
+
+// etagSuffix 返回版本 v 在 ETag 中的后缀。注册了转换的版本线上格式与内部类型不同，
+// 后缀为 ";<name>"（"v3" → "v3;v2"）；没有转换的版本与内部类型逐字节相同，不加后缀。
+func (v *APIVersion) etagSuffix() string {
+	if v == nil || len(v.transforms) == 0 {
+		return ""
+	}
+	return ";" + v.Name
+}
+
+// VersionFromContext 返回请求协商出的 API 版本名，未经过版本中间件时返回空串。
+func VersionFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(versionKey{}).(negotiatedVersion); ok {
+		return v.Name
+	}
+	return ""
+}
+
+func versionFrom(r *http.Request) (negotiatedVersion, bool) {
+	if r == nil {
+		return negotiatedVersion{}, false
+	}
+	v, ok := r.Context().Value(versionKey{}).(negotiatedVersion)
+	return v, ok
+}
@@ -0,0 +1,181 @@
+package restful
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newVersionedServer 返回只有一个固定用户的服务器，时间戳固定，便于逐字节比较线上格式。
+func newVersionedServer(opts ...ServerOption) http.Handler {
+	store := NewInMemoryUserStore()
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	store.users["usr_000001"] = User{
+		ID: "usr_000001", Name: "Alice", Email: "alice@example.com", Age: 30,
+		Version: 1, CreatedAt: at, UpdatedAt: at,
+	}
+	store.seq = 1
+	return NewServer(append([]ServerOption{WithUserStore(store)}, opts...)...)
+}
+
+// 两个版本的线上格式。改动这些字符串就是改动公开契约。
+const (
+	wireUserV1 = `{"id":"usr_000001","name":"Alice","email":"alice@example.com","age":30,"version":1,"created_at":"2025-01-02T03:04:05Z","updated_at":"2025-01-02T03:04:05Z"}`
+	wireUserV2 = `{"id":"usr_000001","name":"Alice","contact":{"email":"alice@example.com"},"age":30,"version":1,"created_at":"2025-01-02T03:04:05Z","updated_at":"2025-01-02T03:04:05Z"}`
+)
+
+func TestVersionWireFormats(t *testing.T) {
+	srv := newVersionedServer()
+
+	tests := []struct {
+		name            string
+		path            string
+		accept          string
+		wantBody        string
+		wantContentType string
+	}{
+		{"v1 by URL", "/api/v1/users/usr_000001", "", `{"data":` + wireUserV1 + `}`, "application/json"},
+		{"v2 by URL", "/api/v2/users/usr_000001", "", `{"data":` + wireUserV2 + `}`, "application/json"},
+		{"v2 by Accept on v1 URL", "/api/v1/users/usr_000001", "application/vnd.gonotes.v2+json", `{"data":` + wireUserV2 + `}`, "application/vnd.gonotes.v2+json"},
+		{"v1 by Accept on v2 URL", "/api/v2/users/usr_000001", "application/vnd.gonotes.v1+json", `{"data":` + wireUserV1 + `}`, "application/vnd.gonotes.v1+json"},
+		{"Accept q-values pick v2", "/api/v1/users/usr_000001", "application/vnd.gonotes.v1+json;q=0.5, application/vnd.gonotes.v2+json", `{"data":` + wireUserV2 + `}`, "application/vnd.gonotes.v2+json"},
+		{"v1 list", "/api/v1/users", "", `{"data":[` + wireUserV1 + `],"meta":{"total":1,"page":1,"limit":20}}`, "application/json"},
+		{"v2 list", "/api/v2/users", "", `{"data":[` + wireUserV2 + `],"meta":{"total":1,"page":1,"limit":20}}`, "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d; body: %s", rec.Code, rec.Body.String())
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.wantBody {
+				t.Errorf("body:\ngot  %s\nwant %s", got, tt.wantBody)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.wantContentType) {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.wantContentType)
+			}
+			if !strings.Contains(strings.Join(rec.Header().Values("Vary"), ","), "Accept") {
+				t.Errorf("Vary = %q, want Accept", rec.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestVersionETags(t *testing.T) {
+	srv := newVersionedServer()
+	do := func(method, path, accept, header, etag string) *httptest.ResponseRecorder {
+		var body *strings.Reader
+		if method == http.MethodPut {
+			body = strings.NewReader(`{"name":"Alice","email":"alice@example.com","age":31}`)
+		} else {
+			body = strings.NewReader("")
+		}
+		req := httptest.NewRequest(method, path, body)
+		req.Header.Set("Authorization", "Bearer demo-token")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if header != "" {
+			req.Header.Set(header, etag)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		accept      string
+		ifNoneMatch string
+		wantCode    int
+		wantETag    string
+	}{
+		{"v1", "/api/v1/users/usr_000001", "", "", http.StatusOK, `"v1"`},
+		{"v2 by URL", "/api/v2/users/usr_000001", "", "", http.StatusOK, `"v1;v2"`},
+		{"v2 by Accept", "/api/v1/users/usr_000001", "application/vnd.gonotes.v2+json", "", http.StatusOK, `"v1;v2"`},
+		{"v2 with v1 ETag", "/api/v2/users/usr_000001", "", `"v1"`, http.StatusOK, `"v1;v2"`},
+		{"v2 with v2 ETag", "/api/v2/users/usr_000001", "", `"v1;v2"`, http.StatusNotModified, `"v1;v2"`},
+		{"v1 with v2 ETag", "/api/v1/users/usr_000001", "", `"v1;v2"`, http.StatusOK, `"v1"`},
+		{"v2 cbor", "/api/v2/users/usr_000001", MediaTypeCBOR, "", http.StatusOK, `"v1;v2-cbor"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(http.MethodGet, tt.path, tt.accept, "If-None-Match", tt.ifNoneMatch)
+			if rec.Code != tt.wantCode || rec.Header().Get("ETag") != tt.wantETag {
+				t.Errorf("%d ETag %s, want %d %s", rec.Code, rec.Header().Get("ETag"), tt.wantCode, tt.wantETag)
+			}
+		})
+	}
+
+	// If-Match 比较的是资源版本: v2 表示的 ETag 可以用于条件写入。
+	if rec := do(http.MethodPut, "/api/v1/users/usr_000001", "application/vnd.gonotes.v2+json", "If-Match", `"v1;v2"`); rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"v2;v2"` {
+		t.Errorf("PUT with v2 If-Match: %d ETag %s, want 200 \"v2;v2\"", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestVersionNotAcceptable(t *testing.T) {
+	srv := newVersionedServer()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/usr_000001", nil)
+	req.Header.Set("Accept", "application/vnd.gonotes.v9+json")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotAcceptable {
+		t.Fatalf("status = %d, want 406", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"code":"not_acceptable"`) || !strings.Contains(rec.Body.String(), "application/vnd.gonotes.v2+json") {
+		t.Errorf("body = %s", rec.Body.String())
+	}
+}
+
+func TestVersionDeprecationHeaders(t *testing.T) {
+	deprecated := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv := newVersionedServer(WithVersions(DefaultVersions().Deprecate("v1", deprecated, sunset, "v2")))
+
+	for _, tt := range []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/api/v1/users/usr_000001", http.StatusOK},
+		{"/api/v1/users/missing", http.StatusNotFound}, // 错误响应同样带弃用头
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.wantStatus {
+			t.Fatalf("%s: status = %d", tt.path, rec.Code)
+		}
+		h := rec.Header()
+		if h.Get("Deprecation") != "@1748736000" || h.Get("Sunset") != "Thu, 01 Jan 2026 00:00:00 GMT" {
+			t.Errorf("%s: Deprecation=%q Sunset=%q", tt.path, h.Get("Deprecation"), h.Get("Sunset"))
+		}
+		want := `</api/v2/` + strings.TrimPrefix(tt.path, "/api/v1/") + `>; rel="successor-version"`
+		if h.Get("Link") != want {
+			t.Errorf("%s: Link = %q, want %q", tt.path, h.Get("Link"), want)
+		}
+	}
+
+	// 继任版本不带弃用头。
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/users/usr_000001", nil))
+	if rec.Header().Get("Deprecation") != "" || rec.Header().Get("Sunset") != "" {
+		t.Errorf("v2 carries deprecation headers: %v", rec.Header())
+	}
+
+	// 分页 Link 与 successor-version 共存。
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/users?limit=1", nil))
+	links := strings.Join(rec.Header().Values("Link"), ", ")
+	if !strings.Contains(links, `rel="successor-version"`) || !strings.Contains(links, `rel="first"`) {
+		t.Errorf("Link = %q", links)
+	}
+}